@@ -0,0 +1,343 @@
+package assets
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const manifestName = "manifest.json"
+
+type archiveRequest struct {
+	IDs      []string `json:"ids"`
+	Name     string   `json:"name,omitempty"` // archive name without extension, "assets" when empty
+	Manifest bool     `json:"manifest,omitempty"`
+}
+
+// archiveEntry is an asset and where it goes in the archive.
+type archiveEntry struct {
+	name  string
+	asset *entities.Asset
+	dir   bool
+	// fsys holds the file of the entry, nil for directories of filesystems
+	fsys libfs.LibFS
+}
+
+type manifestEntry struct {
+	Path        string              `json:"path"`
+	ID          string              `json:"id"`
+	Label       *string             `json:"label,omitempty"`
+	Description *string             `json:"description,omitempty"`
+	NodeKind    entities.NodeKind   `json:"node_kind"`
+	Tags        []string            `json:"tags,omitempty"`
+	Properties  entities.Properties `json:"properties,omitempty"`
+}
+
+// Already compressed files are stored as they are
+var storedExtensions = map[string]bool{
+	".zip": true, ".3mf": true, ".7z": true, ".rar": true, ".gz": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+}
+
+func archive(c echo.Context) error {
+	id := c.Param("id")
+	asset, err := database.GetAsset(id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to get asset", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	base := topName(&asset)
+	return writeArchive(c, strings.TrimSuffix(base, filepath.Ext(base)), []string{id}, c.QueryParam("manifest") != "")
+}
+
+func archiveSelection(c echo.Context) error {
+	var req archiveRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(req.IDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "no assets selected")
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "assets"
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid name")
+	}
+	return writeArchive(c, name, req.IDs, req.Manifest)
+}
+
+// writeArchive streams a zip of the assets and their subtrees. The archive is
+// built while it's sent, once the response started errors only cut it short.
+func writeArchive(c echo.Context, name string, ids []string, withManifest bool) error {
+	ctx := c.Request().Context()
+	entries, err := collectEntries(ids)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to collect archive entries", zap.Strings("asset_ids", ids), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	entries, opened, err := resolveEntries(ctx, entries)
+	defer func() {
+		for _, f := range opened {
+			libfs.Close(f)
+		}
+	}()
+	if err != nil {
+		logger.GetLogger().Error("failed to resolve archive entries", zap.Strings("asset_ids", ids), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "application/zip")
+	w.Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	manifest := make([]manifestEntry, 0, len(entries))
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil
+		}
+
+		if err := writeEntry(zw, e); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				logger.GetLogger().Warn("skipping missing file in archive", zap.String("asset_id", e.asset.ID), zap.String("path", e.name))
+				continue
+			}
+			logger.GetLogger().Error("failed to write archive entry", zap.String("asset_id", e.asset.ID), zap.String("path", e.name), zap.Error(err))
+			return nil
+		}
+		manifest = append(manifest, newManifestEntry(e))
+	}
+
+	if withManifest {
+		mw, err := zw.CreateHeader(&zip.FileHeader{Name: manifestName, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			logger.GetLogger().Error("failed to write archive manifest", zap.Error(err))
+			return nil
+		}
+		enc := json.NewEncoder(mw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(manifest); err != nil {
+			logger.GetLogger().Error("failed to write archive manifest", zap.Error(err))
+			return nil
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		logger.GetLogger().Error("failed to finish archive", zap.Error(err))
+	}
+	return nil
+}
+
+// collectEntries lists what goes into the archive. Subtrees only follow their
+// own filesystem, bundles go in as the archive they are and generated assets
+// are left out. Selections inside another selection are only added once.
+func collectEntries(ids []string) ([]archiveEntry, error) {
+	subtrees := make([][]*entities.Asset, 0, len(ids))
+	contains := make([]map[string]bool, 0, len(ids))
+	for _, id := range ids {
+		rows, err := database.GetSubtree(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		set := make(map[string]bool, len(rows))
+		for _, r := range rows {
+			set[r.ID] = true
+		}
+		subtrees = append(subtrees, rows)
+		contains = append(contains, set)
+	}
+
+	var entries []archiveEntry
+	names := make(map[string]bool)
+	seen := make(map[string]bool)
+	for i, id := range ids {
+		if seen[id] || insideOther(ids, i, contains) {
+			continue
+		}
+		seen[id] = true
+
+		var top *entities.Asset
+		for _, r := range subtrees[i] {
+			if r.ID == id {
+				top = r
+			}
+		}
+		prefix := uniqueName(topName(top), names)
+		entries = append(entries, archiveEntry{name: prefix, asset: top})
+		// Directories inside bundles are bundled assets like their files, only
+		// the files have nothing under them
+		if top.NodeKind != entities.NodeKindRoot && top.NodeKind != entities.NodeKindDir && top.NodeKind != entities.NodeKindBundled {
+			continue
+		}
+
+		for _, r := range subtrees[i] {
+			if r.ID == id || r.Path == nil || r.FSName != top.FSName || r.Root != top.Root {
+				continue
+			}
+			rel, err := filepath.Rel(utils.VoZ(top.Path), *r.Path)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, archiveEntry{name: path.Join(prefix, filepath.ToSlash(rel)), asset: r})
+		}
+	}
+	return entries, nil
+}
+
+// insideOther reports whether the i-th selection is in another one's subtree,
+// the same asset selected twice isn't.
+func insideOther(ids []string, i int, contains []map[string]bool) bool {
+	for j, set := range contains {
+		if ids[j] != ids[i] && set[ids[i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// topName names a selected asset in the archive, roots of filesystems have
+// no file name of their own.
+func topName(a *entities.Asset) string {
+	if p := utils.VoZ(a.Path); p != "" && p != "." {
+		return filepath.Base(p)
+	}
+	if a.Label != nil && *a.Label != "" {
+		return *a.Label
+	}
+	return a.FSName
+}
+
+// uniqueName suffixes names already taken, keeping their extension.
+func uniqueName(name string, taken map[string]bool) string {
+	rtn := name
+	ext := filepath.Ext(name)
+	for i := 2; taken[rtn] || rtn == manifestName; i++ {
+		rtn = strings.TrimSuffix(name, ext) + " (" + strconv.Itoa(i) + ")" + ext
+	}
+	taken[rtn] = true
+	return rtn
+}
+
+// resolveEntries opens the filesystems of the entries and checks their files
+// before anything is sent, so only files vanishing meanwhile can cut the
+// archive short. Directories inside bundles are told apart from files here,
+// missing files are left out. The opened filesystems are returned to be
+// closed once the archive is written.
+func resolveEntries(ctx context.Context, entries []archiveEntry) ([]archiveEntry, map[string]libfs.LibFS, error) {
+	opened := make(map[string]libfs.LibFS)
+	rtn := make([]archiveEntry, 0, len(entries))
+	for _, e := range entries {
+		a := e.asset
+		if a.NodeKind == entities.NodeKindRoot || a.NodeKind == entities.NodeKindDir {
+			e.dir = true
+			rtn = append(rtn, e)
+			continue
+		}
+
+		key := a.FSName + "/" + a.Root
+		f, ok := opened[key]
+		if !ok {
+			if a.FSKind == "bundle" && a.ParentID != nil && a.Parent == nil {
+				if err := database.LoadParents(a, 10); err != nil {
+					return nil, opened, err
+				}
+			}
+			var err error
+			if f, err = libfs.GetAssetFS(ctx, *a); err != nil {
+				return nil, opened, err
+			}
+			opened[key] = f
+		}
+
+		info, err := fs.Stat(f, utils.VoZ(a.Path))
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.GetLogger().Warn("skipping missing file in archive", zap.String("asset_id", a.ID), zap.String("path", e.name))
+			continue
+		}
+		if err != nil {
+			return nil, opened, err
+		}
+		e.dir, e.fsys = info.IsDir(), f
+		rtn = append(rtn, e)
+	}
+	return rtn, opened, nil
+}
+
+func writeEntry(zw *zip.Writer, e archiveEntry) error {
+	a := e.asset
+	if e.dir {
+		_, err := zw.CreateHeader(&zip.FileHeader{Name: e.name + "/", Method: zip.Store, Modified: a.UpdatedAt})
+		return err
+	}
+
+	file, err := e.fsys.Open(utils.VoZ(a.Path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	method := zip.Deflate
+	if storedExtensions[strings.ToLower(filepath.Ext(e.name))] {
+		method = zip.Store
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: method, Modified: info.ModTime()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func newManifestEntry(e archiveEntry) manifestEntry {
+	tags := make([]string, 0, len(e.asset.Tags))
+	for _, t := range e.asset.Tags {
+		tags = append(tags, t.Value)
+	}
+	return manifestEntry{
+		Path:        e.name,
+		ID:          e.asset.ID,
+		Label:       e.asset.Label,
+		Description: e.asset.Description,
+		NodeKind:    e.asset.NodeKind,
+		Tags:        tags,
+		Properties:  e.asset.Properties,
+	}
+}
@@ -2,16 +2,17 @@ package assets
 
 import (
 	"errors"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
 
 	"go.uber.org/zap"
 
 	"github.com/eduardooliveira/stLib/core/data/database"
-	"github.com/eduardooliveira/stLib/core/downloader/tools"
 	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/logger"
-	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/processing"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
@@ -33,8 +34,13 @@ func create(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, errors.New("no files provided"))
 	}
 
-	// Determine parent asset
-	var parentAsset *entities.Asset
+	// Entities carry no form tags, multipart uploads name the parent explicitly
+	if parentID := c.FormValue("parent_id"); asset.ParentID == nil && parentID != "" {
+		asset.ParentID = &parentID
+	}
+
+	// Determine parent asset, root assets go to the default filesystem
+	f, dir := libfs.GetDefaultFS(), "."
 	if asset.ParentID != nil {
 		parent, err := database.GetAsset(*asset.ParentID, false)
 		if err != nil {
@@ -43,48 +49,44 @@ func create(c echo.Context) error {
 			}
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
-		parentAsset = &parent
-	} else {
-		// Creating root asset - use library path
-		parentAsset = nil
-	}
 
-	// Save uploaded files
-	for _, fileHeader := range files {
-		src, err := fileHeader.Open()
+		f, dir, err = contentFS(c.Request().Context(), &parent)
 		if err != nil {
-			logger.GetLogger().Error("failed to open uploaded file", zap.String("filename", fileHeader.Filename), zap.Error(err))
-			continue
-		}
-
-		var targetPath string
-		if parentAsset != nil && parentAsset.Path != nil {
-			targetPath = filepath.Join(runtime.Cfg.Library.Path, *parentAsset.Path, fileHeader.Filename)
-		} else {
-			targetPath = filepath.Join(runtime.Cfg.Library.Path, fileHeader.Filename)
+			logger.GetLogger().Error("failed to get parent filesystem", zap.String("asset_id", parent.ID), zap.String("fs_name", parent.FSName), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
+		defer libfs.Close(f)
+	}
+	if !f.Writable() {
+		return echo.NewHTTPError(http.StatusForbidden, "file system "+f.GetName()+" is read only")
+	}
 
-		if err := tools.SaveFile(targetPath, src); err != nil {
-			src.Close()
-			logger.GetLogger().Error("failed to save file", zap.String("path", targetPath), zap.Error(err))
+	// Save uploaded files
+	changes := make([]libfs.Change, 0, len(files))
+	for _, fileHeader := range files {
+		assetPath := filepath.Join(dir, fileHeader.Filename)
+		if err := saveUpload(f, assetPath, fileHeader); err != nil {
+			logger.GetLogger().Error("failed to save file", zap.String("fs", f.GetName()), zap.String("path", assetPath), zap.Error(err))
 			continue
 		}
-		src.Close()
-
-		// Create asset entity
-		var assetPath string
-		if parentAsset != nil && parentAsset.Path != nil {
-			assetPath = filepath.Join(*parentAsset.Path, fileHeader.Filename)
-		} else {
-			assetPath = fileHeader.Filename
-		}
+		changes = append(changes, libfs.Change{Op: libfs.ChangeCreate, Path: assetPath})
+	}
 
-		newAsset := entities.NewAsset("default", runtime.Cfg.Library.Path, assetPath, false, parentAsset)
-		if err := database.InsertAsset(newAsset); err != nil {
-			logger.GetLogger().Error("failed to insert asset", zap.String("filename", fileHeader.Filename), zap.Error(err))
-			continue
-		}
+	// Discover the new files like a scan would, this also queues their processing
+	if err := processing.ApplyChanges(f, changes); err != nil {
+		logger.GetLogger().Error("failed to discover uploaded files", zap.String("fs", f.GetName()), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	return c.NoContent(http.StatusCreated)
 }
+
+func saveUpload(f libfs.LibFS, name string, fileHeader *multipart.FileHeader) error {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = libfs.WriteFile(f, name, src)
+	return err
+}
@@ -3,14 +3,14 @@ package assets
 import (
 	"errors"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"go.uber.org/zap"
 
 	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/logger"
-	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/trash"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
@@ -30,15 +30,29 @@ func delete(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	// Delete file from filesystem if it exists
+	if asset.NodeKind == entities.NodeKindRoot {
+		return echo.NewHTTPError(http.StatusBadRequest, "cannot delete the root of a filesystem")
+	}
+
+	// Move the files and the asset rows into the trash
 	if asset.Path != nil {
-		filePath := filepath.Join(runtime.Cfg.Library.Path, *asset.Path)
-		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-			logger.GetLogger().Warn("failed to remove file", zap.String("path", filePath), zap.Error(err))
+		f, err := assetFS(c.Request().Context(), &asset)
+		if err != nil {
+			logger.GetLogger().Error("failed to get asset filesystem", zap.String("asset_id", id), zap.String("fs_name", asset.FSName), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		defer libfs.Close(f)
+		if !f.Writable() {
+			return echo.NewHTTPError(http.StatusForbidden, "file system "+f.GetName()+" is read only")
+		}
+		if _, err := trash.Put(f, *asset.Path); err != nil {
+			logger.GetLogger().Error("failed to trash asset", zap.String("fs", f.GetName()), zap.String("path", *asset.Path), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
+		return c.NoContent(http.StatusOK)
 	}
 
-	// Delete asset from database (cascade will handle nested assets)
+	// Assets without files have nothing to restore
 	if err := database.DeleteAsset(id); err != nil {
 		logger.GetLogger().Error("failed to delete asset", zap.String("asset_id", id), zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
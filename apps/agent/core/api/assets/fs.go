@@ -0,0 +1,40 @@
+package assets
+
+import (
+	"context"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/utils"
+)
+
+// assetFS resolves the filesystem an asset lives in, bundles and their
+// contents need the parent chain for that. libfs.Close releases both.
+func assetFS(ctx context.Context, asset *entities.Asset) (libfs.LibFS, error) {
+	if asset.FSKind == "bundle" && asset.ParentID != nil && asset.Parent == nil {
+		if err := database.LoadParents(asset, 10); err != nil {
+			return nil, err
+		}
+	}
+	return libfs.GetAssetFS(ctx, *asset)
+}
+
+// contentFS resolves the filesystem and directory new files under an asset go
+// to, the contents of a bundle live in the bundle itself.
+func contentFS(ctx context.Context, asset *entities.Asset) (libfs.LibFS, string, error) {
+	if asset.NodeKind == entities.NodeKindBundle {
+		if asset.ParentID != nil && asset.Parent == nil {
+			if err := database.LoadParents(asset, 10); err != nil {
+				return nil, "", err
+			}
+		}
+		bf, err := libfs.OpenBundleFS(ctx, *asset)
+		return bf, ".", err
+	}
+	f, err := assetFS(ctx, asset)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, utils.VoZ(asset.Path), nil
+}
@@ -0,0 +1,90 @@
+package assets
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func history(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.New("missing asset id"))
+	}
+
+	asset, err := database.GetAsset(id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to get asset", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	v, err := versionedFS(c, asset)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := v.History(*asset.Path)
+	if err != nil {
+		logger.GetLogger().Error("failed to get asset history", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// getRevisionFile serves the content of the asset as it was at rev.
+func getRevisionFile(c echo.Context, asset entities.Asset, rev string) error {
+	v, err := versionedFS(c, asset)
+	if err != nil {
+		return err
+	}
+
+	f, err := v.OpenRevision(*asset.Path, rev)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to open asset revision", zap.String("asset_id", asset.ID), zap.String("rev", rev), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	file, err := libfs.Seekable(f)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer file.Close()
+
+	return serveContent(c, file, filepath.Base(*asset.Path))
+}
+
+func versionedFS(c echo.Context, asset entities.Asset) (libfs.Versioned, error) {
+	if asset.Path == nil || asset.NodeKind == entities.NodeKindBundled {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "asset has no history")
+	}
+
+	f, err := libfs.GetAssetFS(c.Request().Context(), asset)
+	if err != nil {
+		logger.GetLogger().Error("failed to get asset filesystem", zap.String("asset_id", asset.ID), zap.String("fs_name", asset.FSName), zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	// Versioned filesystems are registered ones, bundles are released here
+	v, ok := f.(libfs.Versioned)
+	if !ok {
+		libfs.Close(f)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "filesystem "+asset.FSName+" keeps no history")
+	}
+	return v, nil
+}
@@ -0,0 +1,72 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/processing/renderers"
+	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLayerSize = 1024
+	maxLayerSize     = 2048
+)
+
+// layer serves a top view of a layer of a G-code asset, numbered from 1.
+func layer(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.New("missing asset id"))
+	}
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil || n < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid layer")
+	}
+	size := defaultLayerSize
+	if s := c.QueryParam("size"); s != "" {
+		size, err = strconv.Atoi(s)
+		if err != nil || size < 64 || size > maxLayerSize {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid size, must be between 64 and %d", maxLayerSize))
+		}
+	}
+
+	asset, err := database.GetAsset(id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to get asset", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if strings.ToLower(utils.VoZ(asset.Extension)) != ".gcode" {
+		return echo.NewHTTPError(http.StatusBadRequest, "asset is not gcode")
+	}
+
+	f, err := renderers.Layer(c.Request().Context(), &asset, n, size)
+	if err != nil {
+		if errors.Is(err, renderers.ErrNoLayer) || errors.Is(err, renderers.ErrNoToolpath) || errors.Is(err, fs.ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to render layer", zap.String("asset_id", id), zap.Int("layer", n), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	file, err := libfs.Seekable(f)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer file.Close()
+
+	return serveContent(c, file, fmt.Sprintf("%s.layer-%d.png", utils.VoZ(asset.Label), n))
+}
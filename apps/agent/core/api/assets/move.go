@@ -0,0 +1,179 @@
+package assets
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/processing"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type relocateRequest struct {
+	ParentID string `json:"parent_id"`
+	Name     string `json:"name,omitempty"` // new file name, keeps the current one when empty
+}
+
+func move(c echo.Context) error {
+	return relocate(c, false)
+}
+
+func copyAsset(c echo.Context) error {
+	return relocate(c, true)
+}
+
+// relocate moves or copies an asset with its subtree under another directory
+// or bundle, in the same filesystem or another one.
+func relocate(c echo.Context, keep bool) error {
+	id := c.Param("id")
+	var req relocateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.ParentID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing parent_id")
+	}
+
+	asset, err := database.GetAsset(id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to get asset", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if asset.Path == nil || asset.NodeKind == entities.NodeKindRoot {
+		return echo.NewHTTPError(http.StatusBadRequest, "cannot move or copy the root of a filesystem")
+	}
+
+	parent, err := database.GetAsset(req.ParentID, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "parent asset not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	switch parent.NodeKind {
+	case entities.NodeKindRoot, entities.NodeKindDir, entities.NodeKindBundle:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "parent must be a directory or a bundle")
+	}
+	if in, err := database.InSubtree(asset.ID, parent.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	} else if in {
+		return echo.NewHTTPError(http.StatusBadRequest, "cannot move or copy an asset into itself")
+	}
+
+	name := req.Name
+	if name == "" {
+		name = filepath.Base(*asset.Path)
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid name")
+	}
+
+	ctx := c.Request().Context()
+	src, err := assetFS(ctx, &asset)
+	if err != nil {
+		logger.GetLogger().Error("failed to get asset filesystem", zap.String("asset_id", id), zap.String("fs_name", asset.FSName), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer libfs.Close(src)
+	dst, dir, err := contentFS(ctx, &parent)
+	if err != nil {
+		logger.GetLogger().Error("failed to get parent filesystem", zap.String("asset_id", parent.ID), zap.String("fs_name", parent.FSName), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer libfs.Close(dst)
+	if !dst.Writable() {
+		return echo.NewHTTPError(http.StatusForbidden, "file system "+dst.GetName()+" is read only")
+	}
+	if !keep && !src.Writable() {
+		return echo.NewHTTPError(http.StatusForbidden, "file system "+src.GetName()+" is read only")
+	}
+
+	newPath := filepath.Join(dir, name)
+	if _, err := fs.Stat(dst, newPath); err == nil {
+		return echo.NewHTTPError(http.StatusConflict, newPath+" already exists in "+dst.GetName())
+	}
+
+	if keep {
+		copied, err := copyTo(src, dst, asset, parent, newPath)
+		if err != nil {
+			logger.GetLogger().Error("failed to copy asset", zap.String("asset_id", id), zap.String("fs", dst.GetName()), zap.String("path", newPath), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		return c.JSON(http.StatusCreated, copied)
+	}
+
+	moved, err := moveTo(src, dst, asset, parent, newPath)
+	if err != nil {
+		logger.GetLogger().Error("failed to move asset", zap.String("asset_id", id), zap.String("fs", dst.GetName()), zap.String("path", newPath), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, moved)
+}
+
+// moveTo renames within a filesystem, or copies and removes across them, and
+// moves the rows so they keep their IDs and metadata.
+func moveTo(src, dst libfs.LibFS, asset, parent entities.Asset, newPath string) (*entities.Asset, error) {
+	oldPath := *asset.Path
+	sameFS := src.GetName() == dst.GetName() && src.GetRoot() == dst.GetRoot()
+
+	if sameFS {
+		if err := src.Rename(oldPath, newPath); err != nil {
+			return nil, err
+		}
+	} else if _, err := libfs.CopyTree(src, oldPath, dst, newPath); err != nil {
+		dst.Remove(newPath)
+		return nil, err
+	}
+
+	moved, err := database.MoveAssetTo(asset.ID, dst.GetName(), dst.GetRoot(), newPath, &parent.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameFS {
+		if err := src.Remove(oldPath); err != nil {
+			logger.GetLogger().Warn("failed to remove moved asset", zap.String("fs", src.GetName()), zap.String("path", oldPath), zap.Error(err))
+		}
+		applyChanges(src, libfs.Change{Op: libfs.ChangeRemove, Path: oldPath})
+	}
+	applyChanges(dst, libfs.Change{Op: libfs.ChangeCreate, Path: newPath})
+
+	rtn, err := database.GetAsset(moved.ID, false)
+	return &rtn, err
+}
+
+func copyTo(src, dst libfs.LibFS, asset, parent entities.Asset, newPath string) (*entities.Asset, error) {
+	if _, err := libfs.CopyTree(src, *asset.Path, dst, newPath); err != nil {
+		dst.Remove(newPath)
+		return nil, err
+	}
+
+	copied, err := database.CopyAsset(asset.ID, dst.GetName(), dst.GetRoot(), newPath, &parent.ID)
+	if err != nil {
+		return nil, err
+	}
+	applyChanges(dst, libfs.Change{Op: libfs.ChangeCreate, Path: newPath})
+
+	rtn, err := database.GetAsset(copied.ID, false)
+	return &rtn, err
+}
+
+// applyChanges refreshes what was written, the files and rows are in place
+// already so failures only leave it for the next scan.
+func applyChanges(f libfs.LibFS, changes ...libfs.Change) {
+	if err := processing.ApplyChanges(f, changes); err != nil {
+		logger.GetLogger().Warn("failed to apply changes", zap.String("fs", f.GetName()), zap.Error(err))
+	}
+}
@@ -12,8 +12,16 @@ func Register(e *echo.Group) {
 	group.GET("/search", search)
 	group.GET("/:id/file", getFile)
 	group.GET("/:id/nested", listNested)
+	group.GET("/:id/history", history)
+	group.GET("/:id/archive", archive)
+	group.GET("/:id/thumbnail", thumbnail)
+	group.GET("/:id/layers/:n", layer)
 	group.GET("/:id", get)
 	group.POST("", create)
+	group.POST("/archive", archiveSelection)
+	group.POST("/:id/move", move)
+	group.POST("/:id/copy", copyAsset)
+	group.POST("/:id/split", split)
 	group.PUT("/:id", update)
 	group.PATCH("/:id", update)
 	group.DELETE("/:id", delete)
@@ -1,6 +1,7 @@
 package assets
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -27,6 +28,15 @@ func search(c echo.Context) error {
 		}
 	}
 
+	var filters []database.PropertyFilter
+	for _, w := range c.QueryParams()["where"] {
+		f, err := parsePropertyFilter(w)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		filters = append(filters, f)
+	}
+
 	page := 0
 	if pageStr := c.QueryParam("page"); pageStr != "" {
 		var err error
@@ -46,7 +56,7 @@ func search(c echo.Context) error {
 		}
 	}
 
-	assets, totalPages, err := database.SearchAssetsPaginated(label, tags, page, perPage)
+	assets, totalPages, err := database.SearchAssetsPaginated(label, tags, filters, page, perPage)
 	if err != nil {
 		logger.GetLogger().Error("failed to search assets", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
@@ -59,3 +69,27 @@ func search(c echo.Context) error {
 		"per_page":    perPage,
 	})
 }
+
+// parsePropertyFilter reads a where parameter like geometry.size_z<200, values
+// are compared as numbers or booleans when they parse as one.
+func parsePropertyFilter(s string) (database.PropertyFilter, error) {
+	for _, op := range database.PropertyOps {
+		key, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || strings.ContainsAny(key, `"<>=!`) || strings.Contains(key, "..") {
+			return database.PropertyFilter{}, fmt.Errorf("invalid property filter %q", s)
+		}
+
+		f := database.PropertyFilter{Key: key, Op: op, Value: value}
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			f.Value = n
+		} else if value == "true" || value == "false" {
+			f.Value = value == "true"
+		}
+		return f, nil
+	}
+	return database.PropertyFilter{}, fmt.Errorf("invalid property filter %q, expected key, operator and value", s)
+}
@@ -0,0 +1,66 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/labstack/echo/v4"
+)
+
+// sniffLen is how much of a file is read to detect its MIME type.
+const sniffLen = 3 * 1024
+
+// serveFile streams the file of an asset, named after its label when it has
+// one.
+func serveFile(c echo.Context, asset entities.Asset, file libfs.SeekableFile, base string) error {
+	name := base
+	if asset.Label != nil {
+		name = *asset.Label
+		if asset.Extension != nil {
+			name += *asset.Extension
+		}
+	}
+	return serveContent(c, file, name)
+}
+
+// serveContent streams f through http.ServeContent, which takes care of Range
+// and the conditional headers, the MIME type is detected from its first bytes.
+func serveContent(c echo.Context, f libfs.SeekableFile, name string) error {
+	info, err := f.Stat()
+	if err != nil {
+		logger.GetLogger().Error("failed to stat file", zap.String("name", name), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	disposition := "inline"
+	if c.QueryParam("download") != "" {
+		disposition = "attachment"
+	}
+	h := c.Response().Header()
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	h.Set("Content-Type", mimetype.Detect(head[:n]).String())
+	// Size and modification time come from the file itself, stored hashes can
+	// lag behind until the next discovery
+	h.Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+
+	http.ServeContent(c.Response(), c.Request(), name, info.ModTime(), f)
+	return nil
+}
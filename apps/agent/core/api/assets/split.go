@@ -0,0 +1,189 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/mesh"
+	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// derivedTag marks the assets split out of another one, the "derived_from"
+// property says which.
+const derivedTag = "derived"
+
+type splitRequest struct {
+	Mode     string `json:"mode,omitempty"`      // auto, objects or components, auto when empty
+	ParentID string `json:"parent_id,omitempty"` // where the parts go, next to the source when empty
+}
+
+// split writes the objects or connected components of a model as STL files of
+// their own, each one an asset that is processed like an upload.
+func split(c echo.Context) error {
+	id := c.Param("id")
+	var req splitRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	asset, err := database.GetAsset(id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to get asset", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if asset.Path == nil || asset.Extension == nil || !mesh.Supported(*asset.Extension) {
+		return echo.NewHTTPError(http.StatusBadRequest, "asset is not a model")
+	}
+
+	parentID := req.ParentID
+	if parentID == "" {
+		parentID = utils.VoZ(asset.ParentID)
+	}
+	parent, err := database.GetAsset(parentID, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "parent asset not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	switch parent.NodeKind {
+	case entities.NodeKindRoot, entities.NodeKindDir, entities.NodeKindBundle:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "parent must be a directory or a bundle")
+	}
+
+	ctx := c.Request().Context()
+	dst, dir, err := contentFS(ctx, &parent)
+	if err != nil {
+		logger.GetLogger().Error("failed to get parent filesystem", zap.String("asset_id", parent.ID), zap.String("fs_name", parent.FSName), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer libfs.Close(dst)
+	if !dst.Writable() {
+		return echo.NewHTTPError(http.StatusForbidden, "file system "+dst.GetName()+" is read only")
+	}
+
+	if asset.FSKind == "bundle" && asset.ParentID != nil && asset.Parent == nil {
+		if err := database.LoadParents(&asset, 10); err != nil {
+			logger.GetLogger().Error("failed to load parents", zap.String("asset_id", id), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	model, err := mesh.Load(ctx, &asset)
+	if err != nil {
+		logger.GetLogger().Error("failed to load mesh", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	pieces, err := mesh.Split(model, req.Mode)
+	switch {
+	case errors.Is(err, mesh.ErrUnknownSplitMode):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, mesh.ErrNothingToSplit), errors.Is(err, mesh.ErrTooManyPieces):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	paths := partPaths(dir, entities.LabelForPath(*asset.Path), pieces)
+	for _, p := range paths {
+		if _, err := fs.Stat(dst, p); err == nil {
+			return echo.NewHTTPError(http.StatusConflict, p+" already exists in "+dst.GetName())
+		}
+	}
+
+	for i, piece := range pieces {
+		if err := writePart(dst, paths[i], piece); err != nil {
+			logger.GetLogger().Error("failed to write part", zap.String("fs", dst.GetName()), zap.String("path", paths[i]), zap.Error(err))
+			for _, p := range paths[:i+1] {
+				dst.Remove(p)
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	// The rows go in before discovery, which keeps their properties, so no
+	// processing job can miss where the parts came from
+	rtn := make([]*entities.Asset, 0, len(pieces))
+	changes := make([]libfs.Change, 0, len(pieces))
+	for i, piece := range pieces {
+		a := entities.NewAssetWithFS(dst, dst.GetName(), dst.GetRoot(), paths[i], false, &parent)
+		a.Parent = nil
+		a.Properties["derived_from"] = map[string]any{
+			"id":    asset.ID,
+			"label": utils.VoZ(asset.Label),
+			"part":  piece.Name,
+		}
+		a.Tags = []*entities.Tag{{Value: derivedTag}}
+		if err := database.InsertAsset(a); err != nil {
+			logger.GetLogger().Error("failed to save part", zap.String("fs", dst.GetName()), zap.String("path", paths[i]), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		rtn = append(rtn, a)
+		changes = append(changes, libfs.Change{Op: libfs.ChangeCreate, Path: paths[i]})
+	}
+	applyChanges(dst, changes...)
+
+	for i, a := range rtn {
+		if got, err := database.GetAsset(a.ID, false); err == nil {
+			rtn[i] = &got
+		}
+	}
+	return c.JSON(http.StatusCreated, rtn)
+}
+
+// partPaths names the parts after the source and the piece, numbering the
+// names pieces share.
+func partPaths(dir, base string, pieces []mesh.Piece) []string {
+	rtn := make([]string, len(pieces))
+	used := make(map[string]int)
+	for i, piece := range pieces {
+		name := base + "_" + safeName(piece.Name)
+		if used[name]++; used[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, used[name])
+		}
+		rtn[i] = filepath.Join(dir, name+".stl")
+	}
+	return rtn
+}
+
+// safeName turns an object name into something every filesystem takes.
+func safeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if strings.Trim(name, "._") == "" {
+		return "part"
+	}
+	return name
+}
+
+func writePart(f libfs.LibFS, name string, piece mesh.Piece) error {
+	w, err := f.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := mesh.WriteSTL(w, piece.Triangles); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
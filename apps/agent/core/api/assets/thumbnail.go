@@ -0,0 +1,74 @@
+package assets
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/thumbnails"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultThumbnailSize   = 256
+	defaultThumbnailFormat = "webp"
+)
+
+// thumbnail serves a resized variant of the image of an asset, see
+// thumbnails.Get for where it comes from.
+func thumbnail(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.New("missing asset id"))
+	}
+
+	size := defaultThumbnailSize
+	if s := c.QueryParam("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid size")
+		}
+		size = n
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = defaultThumbnailFormat
+	}
+
+	asset, err := database.GetAsset(id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to get asset", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	v, err := thumbnails.Get(c.Request().Context(), asset, size, format)
+	if err != nil {
+		switch {
+		case errors.Is(err, thumbnails.ErrUnsupportedFormat):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, thumbnails.ErrNoImage), errors.Is(err, fs.ErrNotExist):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		case errors.Is(err, thumbnails.ErrUnsupportedImage):
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+		}
+		logger.GetLogger().Error("failed to get thumbnail", zap.String("asset_id", id), zap.Int("size", size), zap.String("format", format), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	file, err := libfs.Seekable(v.File)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer file.Close()
+
+	return serveContent(c, file, v.Name)
+}
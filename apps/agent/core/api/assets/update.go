@@ -1,6 +1,8 @@
 package assets
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -8,6 +10,7 @@ import (
 
 	"github.com/eduardooliveira/stLib/core/data/database"
 	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/logger"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -76,5 +79,57 @@ func update(c echo.Context) error {
 		logger.GetLogger().Warn("failed to reload updated asset tags", zap.String("asset_id", id), zap.Error(err))
 	}
 
+	if err := writeMetadata(c.Request().Context(), existing); err != nil {
+		logger.GetLogger().Error("failed to write asset metadata", zap.String("asset_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
 	return c.JSON(http.StatusOK, existing)
 }
+
+type assetMetadata struct {
+	Label       *string             `json:"label,omitempty"`
+	Description *string             `json:"description,omitempty"`
+	Properties  entities.Properties `json:"properties,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+}
+
+// writeMetadata keeps the metadata in a sidecar next to the asset on versioned
+// filesystems, so changes to it end up in the history as well.
+func writeMetadata(ctx context.Context, asset entities.Asset) error {
+	if asset.Path == nil || asset.NodeKind == entities.NodeKindBundled || asset.NodeKind == entities.NodeKindRoot {
+		return nil
+	}
+
+	f, err := libfs.GetAssetFS(ctx, asset)
+	if err != nil {
+		return err
+	}
+	defer libfs.Close(f)
+	if _, ok := f.(libfs.Versioned); !ok || !f.Writable() {
+		return nil
+	}
+
+	meta := assetMetadata{
+		Label:       asset.Label,
+		Description: asset.Description,
+		Properties:  asset.Properties,
+	}
+	for _, t := range asset.Tags {
+		meta.Tags = append(meta.Tags, t.Value)
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w, err := f.Create(libfs.MetadataPath(*asset.Path))
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
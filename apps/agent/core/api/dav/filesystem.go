@@ -0,0 +1,298 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/processing"
+	"github.com/eduardooliveira/stLib/core/trash"
+)
+
+// davFS serves a LibFS as a webdav.FileSystem. Every change made through it
+// is applied to the asset tree, as if discovery had found it.
+type davFS struct {
+	fs  libfs.LibFS
+	log *zap.Logger
+}
+
+// fsName turns a webdav name, always slash rooted, into a LibFS one.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (d *davFS) changed(changes ...libfs.Change) {
+	if err := processing.ApplyChanges(d.fs, changes); err != nil {
+		d.log.Warn("failed to apply webdav changes", zap.Error(err))
+	}
+}
+
+func (d *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	info, err := fs.Stat(d.fs.GetFS(), fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info}, nil
+}
+
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name = fsName(name)
+	if !d.fs.Writable() {
+		return os.ErrPermission
+	}
+	if _, err := fs.Stat(d.fs.GetFS(), name); err == nil {
+		return os.ErrExist
+	}
+	if err := d.parentExists(name); err != nil {
+		return err
+	}
+	if err := d.fs.Mkdir(name); err != nil {
+		return err
+	}
+	d.changed(libfs.Change{Op: libfs.ChangeCreate, Path: name})
+	return nil
+}
+
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+	name = fsName(name)
+	if !d.fs.Writable() || name == "." {
+		return os.ErrPermission
+	}
+	// Trashing applies the removal itself
+	_, err := trash.Put(d.fs, name)
+	return err
+}
+
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = fsName(oldName), fsName(newName)
+	if !d.fs.Writable() || oldName == "." || newName == "." {
+		return os.ErrPermission
+	}
+	if err := d.fs.Rename(oldName, newName); err != nil {
+		return err
+	}
+	d.changed(libfs.Change{Op: libfs.ChangeMove, Path: newName, OldPath: oldName})
+	return nil
+}
+
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name = fsName(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		info, err := fs.Stat(d.fs.GetFS(), name)
+		if err != nil {
+			return nil, err
+		}
+		return &readFile{fs: d.fs, name: name, info: &fileInfo{FileInfo: info}}, nil
+	}
+
+	if !d.fs.Writable() || name == "." {
+		return nil, os.ErrPermission
+	}
+	info, err := fs.Stat(d.fs.GetFS(), name)
+	if err == nil && info.IsDir() {
+		return nil, os.ErrInvalid
+	}
+	exists := err == nil
+	if !exists && flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+	if err := d.parentExists(name); err != nil {
+		return nil, err
+	}
+
+	w, err := d.fs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	op := libfs.ChangeWrite
+	if !exists {
+		op = libfs.ChangeCreate
+	}
+	return &writeFile{d: d, name: name, op: op, w: w}, nil
+}
+
+// parentExists fails like os does when writing into a missing directory,
+// webdav clients expect a conflict rather than the parents being created.
+func (d *davFS) parentExists(name string) error {
+	info, err := fs.Stat(d.fs.GetFS(), path.Dir(name))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// fileInfo answers the content type from the extension, webdav would open and
+// sniff every file of a listing otherwise.
+type fileInfo struct {
+	fs.FileInfo
+}
+
+func (i *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(i.Name())); ctype != "" {
+		return ctype, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// readFile opens the file on the first Read or Seek, listings and stats don't
+// download anything from remote filesystems.
+type readFile struct {
+	fs      libfs.LibFS
+	name    string
+	info    fs.FileInfo
+	file    libfs.SeekableFile
+	entries []fs.FileInfo
+	listed  bool
+}
+
+func (f *readFile) open() error {
+	if f.file != nil {
+		return nil
+	}
+	if f.info.IsDir() {
+		return &fs.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	}
+	file, err := libfs.OpenSeekable(f.fs, f.name)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	return nil
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.file.Read(p)
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.file.Seek(offset, whence)
+}
+
+func (f *readFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	if !f.listed {
+		entries, err := fs.ReadDir(f.fs.GetFS(), f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = make([]fs.FileInfo, 0, len(entries))
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			f.entries = append(f.entries, &fileInfo{FileInfo: info})
+		}
+		f.listed = true
+	}
+
+	if count <= 0 {
+		rtn := f.entries
+		f.entries = nil
+		return rtn, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.entries) {
+		count = len(f.entries)
+	}
+	rtn := f.entries[:count]
+	f.entries = f.entries[count:]
+	return rtn, nil
+}
+
+func (f *readFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *readFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *readFile) Close() error {
+	if f.file != nil {
+		return f.file.Close()
+	}
+	return nil
+}
+
+// writeFile streams a PUT into the filesystem and applies the change once the
+// content is complete.
+type writeFile struct {
+	d       *davFS
+	name    string
+	op      libfs.ChangeOp
+	w       io.WriteCloser
+	written int64
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *writeFile) Close() error {
+	if err := f.w.Close(); err != nil {
+		return err
+	}
+	f.d.changed(libfs.Change{Op: f.op, Path: f.name})
+	return nil
+}
+
+// Stat is called before Close, remote filesystems only have the file once
+// the upload is done.
+func (f *writeFile) Stat() (fs.FileInfo, error) {
+	return &fileInfo{FileInfo: &writtenInfo{name: path.Base(f.name), size: f.written, modTime: time.Now()}}, nil
+}
+
+func (f *writeFile) Read(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *writeFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *writeFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+type writtenInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i *writtenInfo) Name() string       { return i.name }
+func (i *writtenInfo) Size() int64        { return i.size }
+func (i *writtenInfo) Mode() fs.FileMode  { return 0644 }
+func (i *writtenInfo) ModTime() time.Time { return i.modTime }
+func (i *writtenInfo) IsDir() bool        { return false }
+func (i *writtenInfo) Sys() any           { return nil }
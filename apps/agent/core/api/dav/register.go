@@ -0,0 +1,30 @@
+package dav
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+var group *echo.Group
+
+var methods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodPut,
+	http.MethodDelete,
+	"PROPFIND",
+	"PROPPATCH",
+	"MKCOL",
+	"COPY",
+	"MOVE",
+	"LOCK",
+	"UNLOCK",
+}
+
+func Register(e *echo.Group) {
+	group = e
+	group.Match(methods, "/:fs", serve)
+	group.Match(methods, "/:fs/*", serve)
+}
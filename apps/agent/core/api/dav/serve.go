@@ -0,0 +1,72 @@
+package dav
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+)
+
+var writeMethods = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodDelete: true,
+	"PROPPATCH":       true,
+	"MKCOL":           true,
+	"COPY":            true,
+	"MOVE":            true,
+}
+
+// Locks outlive requests, keep one lock system per filesystem
+var locks = struct {
+	sync.Mutex
+	byFS map[string]webdav.LockSystem
+}{
+	byFS: make(map[string]webdav.LockSystem),
+}
+
+func serve(c echo.Context) error {
+	name := c.Param("fs")
+	f, ok := libfs.GetFSs()[name]
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "file system not found")
+	}
+	if writeMethods[c.Request().Method] && !f.Writable() {
+		return echo.NewHTTPError(http.StatusForbidden, "file system is read only")
+	}
+
+	// The route is either /:fs or /:fs/*, what comes before is the group prefix
+	prefix := strings.TrimSuffix(strings.TrimSuffix(c.Path(), "/*"), "/:fs") + "/" + name
+
+	l := logger.GetLogger().With(zap.String("module", "dav"), zap.String("fs", name))
+	h := &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: &davFS{fs: f, log: l},
+		LockSystem: lockSystem(name),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				l.Warn("webdav request failed", zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.Error(err))
+			}
+		},
+	}
+	h.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+func lockSystem(name string) webdav.LockSystem {
+	locks.Lock()
+	defer locks.Unlock()
+	ls, ok := locks.byFS[name]
+	if !ok {
+		ls = webdav.NewMemLS()
+		locks.byFS[name] = ls
+	}
+	return ls
+}
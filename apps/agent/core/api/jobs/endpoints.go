@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/queue"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func index(c echo.Context) error {
+	filter := database.JobFilter{
+		State:   c.QueryParam("state"),
+		Kind:    c.QueryParam("kind"),
+		AssetID: c.QueryParam("asset_id"),
+	}
+
+	page := 0
+	if pageStr := c.QueryParam("page"); pageStr != "" {
+		var err error
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			page = 1
+		}
+		page-- // Convert to 0-based
+	}
+
+	perPage := 20
+	if perPageStr := c.QueryParam("per_page"); perPageStr != "" {
+		var err error
+		perPage, err = strconv.Atoi(perPageStr)
+		if err != nil || perPage < 1 {
+			perPage = 20
+		}
+	}
+
+	jobs, totalPages, err := database.GetJobsPaginated(filter, page, perPage)
+	if err != nil {
+		logger.GetLogger().Error("failed to get jobs", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"jobs":        jobs,
+		"total_pages": totalPages,
+		"page":        page + 1,
+		"per_page":    perPage,
+	})
+}
+
+func retry(c echo.Context) error {
+	return changeState(c, queue.Retry)
+}
+
+func cancel(c echo.Context) error {
+	return changeState(c, queue.Cancel)
+}
+
+func changeState(c echo.Context, change func(id string) (*entities.Job, error)) error {
+	id := c.Param("id")
+	if id == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.New("missing job id"))
+	}
+
+	job, err := change(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, database.ErrJobState) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		logger.GetLogger().Error("failed to update job", zap.String("job_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
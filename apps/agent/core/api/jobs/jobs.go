@@ -0,0 +1,14 @@
+package jobs
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+var group *echo.Group
+
+func Register(e *echo.Group) {
+	group = e
+	group.GET("", index)
+	group.POST("/:id/retry", retry)
+	group.POST("/:id/cancel", cancel)
+}
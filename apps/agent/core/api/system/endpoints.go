@@ -1,7 +1,6 @@
 package system
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -20,6 +19,7 @@ import (
 	"github.com/eduardooliveira/stLib/core/system"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/exp/maps"
+	"gorm.io/gorm"
 )
 
 type void struct{}
@@ -66,20 +66,66 @@ func saveSettings(c echo.Context) error {
 	if err := runtime.SaveConfig(cfg); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	if err := processing.RefreshRenders(); err != nil {
+		logger.GetLogger().Error("failed to refresh renders", zap.Error(err))
+	}
 	return c.JSON(http.StatusOK, cfg)
 
 }
 
 func runDiscovery(c echo.Context) error {
-	go func() {
-		err := processing.ScanFS(context.Background(), logger.GetLogger())
-		if err != nil {
-			logger.GetLogger().Error("discovery error", zap.Error(err))
-		}
-	}()
+	scope := processing.ScanScope{}
+	if err := c.Bind(&scope); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	scan, err := processing.StartScan(scope, logger.GetLogger())
+	if err != nil {
+		return scanError(err)
+	}
+	return c.JSON(http.StatusOK, scan)
+}
+
+// status reports the state of the background work that can need attention.
+func status(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{
+		"sync": processing.SyncStatuses(),
+	})
+}
+
+// syncFS syncs a filesystem with its remote right away, failed syncs are
+// reported in the returned status.
+func syncFS(c echo.Context) error {
+	status, err := processing.SyncNow(c.Request().Context(), c.Param("fs"), logger.GetLogger())
+	if errors.Is(err, processing.ErrFSNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if errors.Is(err, processing.ErrSyncNotSupported) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+func cancelDiscovery(c echo.Context) error {
+	if err := processing.CancelScan(c.Param("id")); err != nil {
+		return scanError(err)
+	}
 	return c.NoContent(http.StatusOK)
 }
 
+func scanError(err error) error {
+	switch {
+	case errors.Is(err, processing.ErrScanRunning):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, processing.ErrScanScope):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, processing.ErrScanNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	logger.GetLogger().Error("discovery error", zap.Error(err))
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
+
 func subscribe(c echo.Context) error {
 
 	session := c.Param("session")
@@ -106,3 +152,30 @@ func unSubscribe(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+func subscribeScan(c echo.Context) error {
+
+	session := c.Param("session")
+	if session == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.New("no session provided").Error())
+	}
+
+	err := events.Subscribe(session, "system.scan", system.GetScanEventPublisher())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func unSubscribeScan(c echo.Context) error {
+
+	session := c.Param("session")
+	if session == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.New("no session provided").Error())
+	}
+
+	events.UnSubscribe(session, "system.scan")
+
+	return c.NoContent(http.StatusOK)
+}
@@ -8,10 +8,16 @@ var group *echo.Group
 
 func Register(e *echo.Group) {
 	group = e
+	group.GET("", status)
 	group.GET("/paths", paths)
 	group.GET("/settings", settings)
 	group.POST("/settings", saveSettings)
 	group.GET("/discovery", runDiscovery)
+	group.POST("/discovery", runDiscovery)
+	group.DELETE("/discovery/:id", cancelDiscovery)
+	group.POST("/sync/:fs", syncFS)
 	group.GET("/events/subscribe/:session", subscribe)
 	group.GET("/events/unsubscribe/:session", unSubscribe)
+	group.GET("/scan/subscribe/:session", subscribeScan)
+	group.GET("/scan/unsubscribe/:session", unSubscribeScan)
 }
@@ -0,0 +1,59 @@
+package trash
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/trash"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func index(c echo.Context) error {
+	rtn, err := database.GetTrashEntries()
+	if err != nil {
+		logger.GetLogger().Error("failed to get trash entries", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, rtn)
+}
+
+func restore(c echo.Context) error {
+	id := c.Param("id")
+	e, err := trash.Restore(c.Request().Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		case errors.Is(err, trash.ErrRestoreConflict), errors.Is(err, trash.ErrNothingToRestore), errors.Is(err, trash.ErrFSNotFound):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.Is(err, trash.ErrReadOnly):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		}
+		logger.GetLogger().Error("failed to restore trash entry", zap.String("trash_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	asset, err := database.GetAsset(e.AssetID, false)
+	if err != nil {
+		logger.GetLogger().Warn("restored asset not found", zap.String("trash_id", id), zap.String("asset_id", e.AssetID), zap.Error(err))
+		return c.JSON(http.StatusOK, e)
+	}
+	return c.JSON(http.StatusOK, asset)
+}
+
+func purge(c echo.Context) error {
+	id := c.Param("id")
+	if err := trash.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		logger.GetLogger().Error("failed to delete trash entry", zap.String("trash_id", id), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusOK)
+}
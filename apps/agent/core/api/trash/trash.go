@@ -0,0 +1,11 @@
+package trash
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func Register(e *echo.Group) {
+	e.GET("", index)
+	e.POST("/:id/restore", restore)
+	e.DELETE("/:id", purge)
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"golang.org/x/sync/singleflight"
+)
+
+// Store keeps what is derived from assets, like thumbnails and G-code layers,
+// in a directory of the cache filesystem. Entries of an asset share a
+// directory and are named <key>.<version><ext>, the version says what they
+// were made from.
+type Store struct {
+	dir      string
+	inflight singleflight.Group
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Version names what entries are made from, like the size and modification
+// time of their source.
+func Version(parts ...any) string {
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = fmt.Sprint(p)
+	}
+	sum := md5.Sum([]byte(strings.Join(s, "-")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Get opens an entry of an asset, write makes it when it's missing. Concurrent
+// calls for the same entry wait for a single write, and once it's in the
+// entries of other versions are dropped.
+func (s *Store) Get(id, key, version, ext string, write func(w io.Writer) error) (fs.File, error) {
+	cacheFS, err := libfs.GetLibFS("cache")
+	if err != nil {
+		return nil, fmt.Errorf("error getting cache fs: %w", err)
+	}
+	dir := path.Join(s.dir, id)
+	name := path.Join(dir, key+"."+version+ext)
+
+	if _, err, _ := s.inflight.Do(name, func() (any, error) {
+		if _, err := fs.Stat(cacheFS.GetFS(), name); err == nil {
+			return nil, nil
+		}
+		if err := create(cacheFS, name, write); err != nil {
+			return nil, err
+		}
+		return nil, prune(cacheFS, dir, version)
+	}); err != nil {
+		return nil, err
+	}
+	return cacheFS.Open(name)
+}
+
+// Invalidate drops the entries of an asset.
+func (s *Store) Invalidate(id string) error {
+	cacheFS, err := libfs.GetLibFS("cache")
+	if err != nil {
+		return err
+	}
+	return cacheFS.Remove(path.Join(s.dir, id))
+}
+
+// create writes an entry aside and renames it so readers never see a partial
+// one.
+func create(cacheFS libfs.LibFS, name string, write func(w io.Writer) error) error {
+	temp := name + ".tmp"
+	w, err := cacheFS.Create(temp)
+	if err != nil {
+		return err
+	}
+	err = write(w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cacheFS.Remove(temp)
+		return err
+	}
+	return cacheFS.Rename(temp, name)
+}
+
+// prune removes the entries made from other versions.
+func prune(cacheFS libfs.LibFS, dir, version string) error {
+	entries, err := fs.ReadDir(cacheFS.GetFS(), dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		parts := strings.Split(e.Name(), ".")
+		if len(parts) == 3 && parts[1] != version {
+			if err := cacheFS.Remove(path.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
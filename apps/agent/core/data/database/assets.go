@@ -4,20 +4,35 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/eduardooliveira/stLib/core/entities"
 	"github.com/eduardooliveira/stLib/core/system"
+	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/google/uuid"
+	"golang.org/x/exp/maps"
 	"gorm.io/gorm"
 )
 
 const assetEvent = "system.state.asset.event"
 
 func initAssets() error {
-	return DB.AutoMigrate(&entities.Asset{})
+	if err := DB.AutoMigrate(&entities.Asset{}); err != nil {
+		return err
+	}
+	// IDs used to be the locators, existing assets keep them as their stable ID
+	return DB.Exec("UPDATE assets SET locator = id WHERE locator IS NULL OR locator = ''").Error
 }
 
+// SaveAsset stores an asset, one already known under the same locator keeps
+// its ID.
 func SaveAsset(a *entities.Asset) error {
-	if err := DB.Omit("NestedAssets").Save(a).Error; err != nil {
+	if err := adoptID(a); err != nil {
+		return err
+	}
+	if err := DB.Omit("NestedAssets", "Parent").Save(a).Error; err != nil {
 		return err
 	}
 	publishAssetEvent(a, "update")
@@ -25,6 +40,9 @@ func SaveAsset(a *entities.Asset) error {
 }
 
 func InsertAsset(a *entities.Asset) error {
+	if err := adoptID(a); err != nil {
+		return err
+	}
 	if err := DB.Omit("NestedAssets").Create(a).Error; err != nil {
 		return err
 	}
@@ -32,6 +50,25 @@ func InsertAsset(a *entities.Asset) error {
 	return nil
 }
 
+func adoptID(a *entities.Asset) error {
+	if a.Locator == "" {
+		return nil
+	}
+	var existing entities.Asset
+	err := DB.Select("id", "created_at").Where("locator = ?", a.Locator).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	a.ID = existing.ID
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = existing.CreatedAt
+	}
+	return nil
+}
+
 func GetAsset(id string, deep bool) (entities.Asset, error) {
 	var asset entities.Asset
 	q := DB.Where("ID = ?", id).Preload("Tags")
@@ -43,6 +80,12 @@ func GetAsset(id string, deep bool) (entities.Asset, error) {
 	return asset, q.First(&asset).Error
 }
 
+// GetAssetByLocator returns the asset currently at the locator's path.
+func GetAssetByLocator(locator string) (entities.Asset, error) {
+	var asset entities.Asset
+	return asset, DB.Where("locator = ?", locator).Preload("Tags").First(&asset).Error
+}
+
 func GetAssetRoots(deep bool) ([]*entities.Asset, error) {
 	var assets []*entities.Asset
 	var rootIDs []string
@@ -201,7 +244,7 @@ func LoadParents(a *entities.Asset, depth int, fields ...string) error {
 	return q.Find(a).Error
 }
 
-func SearchAssets(label string, tags []string) ([]*entities.Asset, error) {
+func SearchAssets(label string, tags []string, filters []PropertyFilter) ([]*entities.Asset, error) {
 	var assets []*entities.Asset
 	q := DB.Model(&entities.Asset{}).Preload("Tags")
 
@@ -215,11 +258,12 @@ func SearchAssets(label string, tags []string) ([]*entities.Asset, error) {
 			Where("tags.value IN ?", tags).
 			Group("assets.id")
 	}
+	q = applyPropertyFilters(q, filters)
 
 	return assets, q.Find(&assets).Error
 }
 
-func SearchAssetsPaginated(label string, tags []string, page, perPage int) ([]*entities.Asset, int, error) {
+func SearchAssetsPaginated(label string, tags []string, filters []PropertyFilter, page, perPage int) ([]*entities.Asset, int, error) {
 	var assets []*entities.Asset
 	var totalRows int64
 
@@ -235,6 +279,7 @@ func SearchAssetsPaginated(label string, tags []string, page, perPage int) ([]*e
 			Where("tags.value IN ?", tags).
 			Group("assets.id")
 	}
+	baseQuery = applyPropertyFilters(baseQuery, filters)
 
 	// Count total results
 	countQuery := baseQuery
@@ -257,8 +302,230 @@ func SearchAssetsPaginated(label string, tags []string, page, perPage int) ([]*e
 	return assets, totalPages, nil
 }
 
+// MoveAsset moves an asset and its subtree to a new path inside the same
+// filesystem, they keep their IDs and with them all metadata.
+func MoveAsset(id string, newPath string, newParentID *string) (*entities.Asset, error) {
+	var a entities.Asset
+	if err := DB.Where("id = ?", id).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return MoveAssetTo(id, a.FSName, a.Root, newPath, newParentID)
+}
+
+// MoveAssetTo moves an asset and its subtree to a path in another filesystem
+// or bundle, fsName and root being the ones of the target.
+func MoveAssetTo(id, fsName, root, newPath string, newParentID *string) (*entities.Asset, error) {
+	moved, err := relocateAsset(id, fsName, root, newPath, newParentID, false)
+	if err != nil {
+		return nil, err
+	}
+	publishAssetEvent(moved, "move")
+	return moved, nil
+}
+
+// CopyAsset duplicates the rows of an asset and its subtree under a new path,
+// with their metadata and tags. Generated assets aren't copied, processing
+// creates them again for the copy.
+func CopyAsset(id, fsName, root, newPath string, newParentID *string) (*entities.Asset, error) {
+	copied, err := relocateAsset(id, fsName, root, newPath, newParentID, true)
+	if err != nil {
+		return nil, err
+	}
+	publishAssetEvent(copied, "copy")
+	return copied, nil
+}
+
+func relocateAsset(id, fsName, root, newPath string, newParentID *string, keep bool) (*entities.Asset, error) {
+	var rtn entities.Asset
+	copies := make(map[string]string)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var a entities.Asset
+		if err := tx.Where("id = ?", id).First(&a).Error; err != nil {
+			return err
+		}
+
+		newID, err := relocateRow(tx, &a, fsName, root, newPath, newParentID, keep, copies)
+		if err != nil {
+			return err
+		}
+
+		if keep {
+			// Copies point at their own copies, or get a thumbnail once processed
+			newIDs := maps.Values(copies)
+			for oldID, newID := range copies {
+				if err := tx.Model(&entities.Asset{}).Where("id IN ? AND thumbnail = ?", newIDs, oldID).Update("thumbnail", newID).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&entities.Asset{}).Where("id IN ? AND thumbnail NOT IN ?", newIDs, newIDs).Update("thumbnail", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id = ?", newID).First(&rtn).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rtn, nil
+}
+
+// relocateRow points the asset at its new path and does the same for its
+// subtree. With keep set the rows are copied under new IDs instead, copies
+// maps the old IDs to them.
+func relocateRow(tx *gorm.DB, a *entities.Asset, fsName, root, newPath string, newParentID *string, keep bool, copies map[string]string) (string, error) {
+	oldID, oldFSName, oldRoot := a.ID, a.FSName, a.Root
+	oldPath := utils.VoZ(a.Path)
+
+	var children []*entities.Asset
+	if err := tx.Where("parent_id = ?", oldID).Find(&children).Error; err != nil {
+		return "", err
+	}
+
+	if a.Label == nil || *a.Label == entities.LabelForPath(oldPath) {
+		a.Label = utils.Ptr(entities.LabelForPath(newPath))
+	}
+	a.Locator = entities.LocatorForPath(fsName, root, newPath)
+	a.Path = utils.Ptr(newPath)
+	a.FSName = fsName
+	a.Root = root
+	a.ParentID = newParentID
+	a.Parent = nil
+	if fsName != oldFSName {
+		// Rediscovery in the new filesystem has to refresh what depends on it
+		a.ModTime, a.Hash = nil, nil
+	}
+
+	if keep {
+		a.ID = uuid.New().String()
+		a.CreatedAt = time.Time{}
+		if err := tx.Omit("NestedAssets", "Tags", "Parent").Create(a).Error; err != nil {
+			return "", err
+		}
+		if err := tx.Exec("INSERT INTO asset_tags (asset_id, tag_value) SELECT ?, tag_value FROM asset_tags WHERE asset_id = ?", a.ID, oldID).Error; err != nil {
+			return "", err
+		}
+		copies[oldID] = a.ID
+	} else if err := tx.Omit("NestedAssets", "Tags", "Parent").Save(a).Error; err != nil {
+		return "", err
+	}
+
+	for _, child := range children {
+		childPath := utils.VoZ(child.Path)
+		switch {
+		case child.Root == oldID:
+			// Bundled assets are keyed by their bundle, their path inside it doesn't change.
+			if _, err := relocateRow(tx, child, filepath.Base(newPath), a.ID, childPath, &a.ID, keep, copies); err != nil {
+				return "", err
+			}
+		case child.FSName == oldFSName && child.Root == oldRoot && (oldPath == "." || strings.HasPrefix(childPath, oldPath)):
+			rel, err := filepath.Rel(oldPath, childPath)
+			if err != nil {
+				return "", err
+			}
+			if _, err := relocateRow(tx, child, fsName, root, filepath.Join(newPath, rel), &a.ID, keep, copies); err != nil {
+				return "", err
+			}
+		}
+		// Generated assets (renders, extracted images) stay with their parent,
+		// copies get their own once processed
+	}
+
+	return a.ID, nil
+}
+
+// InSubtree reports whether an asset is the given root or below it.
+func InSubtree(rootID, id string) (bool, error) {
+	var count int64
+	err := DB.Raw("SELECT COUNT(*) FROM ("+fmt.Sprintf(subtreeIDs, "id = ?")+") WHERE id = ?", rootID, id).Scan(&count).Error
+	return count > 0, err
+}
+
+const subtreeIDs = `WITH RECURSIVE subtree(id) AS (
+	SELECT id FROM assets WHERE %s
+	UNION ALL
+	SELECT assets.id FROM assets JOIN subtree ON assets.parent_id = subtree.id
+) SELECT id FROM subtree`
+
+// GetSubtree returns an asset and everything under it with their tags.
+func GetSubtree(id string) ([]*entities.Asset, error) {
+	var rtn []*entities.Asset
+	return rtn, DB.Where("id IN ("+fmt.Sprintf(subtreeIDs, "id = ?")+")", id).Preload("Tags").Order("path").Find(&rtn).Error
+}
+
+// GetFilesWithSize returns the files and bundles of a filesystem with the given
+// size, the candidates a moved file may have come from.
+func GetFilesWithSize(fsName string, size int64) ([]*entities.Asset, error) {
+	var rtn []*entities.Asset
+	return rtn, DB.Where("fs_name = ? AND size = ? AND node_kind IN ?", fsName, size,
+		[]entities.NodeKind{entities.NodeKindFile, entities.NodeKindBundle, entities.NodeKindBundled}).Find(&rtn).Error
+}
+
+// GetDirsNamed returns the directories of a filesystem with the given base
+// name, the candidates a moved directory may have come from.
+func GetDirsNamed(fsName, name string) ([]*entities.Asset, error) {
+	var dirs []*entities.Asset
+	if err := DB.Where("fs_name = ? AND node_kind IN ? AND (path = ? OR path LIKE ?)", fsName,
+		[]entities.NodeKind{entities.NodeKindDir, entities.NodeKindRoot}, name, "%/"+name).Find(&dirs).Error; err != nil {
+		return nil, err
+	}
+	// LIKE treats _ and % in the name as wildcards
+	rtn := dirs[:0]
+	for _, d := range dirs {
+		if filepath.Base(utils.VoZ(d.Path)) == name {
+			rtn = append(rtn, d)
+		}
+	}
+	return rtn, nil
+}
+
+// GetChildNames returns the base names of the children an asset has in its own
+// filesystem, generated assets aren't included.
+func GetChildNames(parentID, fsName string) ([]string, error) {
+	var paths []string
+	if err := DB.Model(&entities.Asset{}).Where("parent_id = ? AND fs_name = ?", parentID, fsName).Pluck("path", &paths).Error; err != nil {
+		return nil, err
+	}
+	for i, p := range paths {
+		paths[i] = filepath.Base(p)
+	}
+	return paths, nil
+}
+
+// GetChildrenInFS lists the children of an asset stored in a filesystem, like
+// the images generated for it.
+func GetChildrenInFS(parentID, fsName string) ([]*entities.Asset, error) {
+	var rtn []*entities.Asset
+	err := DB.Where("parent_id = ? AND fs_name = ?", parentID, fsName).Find(&rtn).Error
+	return rtn, err
+}
+
+// GetOutdatedRenderParents lists the assets whose preset renders don't match
+// the fingerprints of the configured presets, because a preset changed, was
+// added or was removed.
+func GetOutdatedRenderParents(fsName string, fingerprints []string) ([]string, error) {
+	var rtn []string
+	err := DB.Model(&entities.Asset{}).
+		Where("fs_name = ? AND json_extract(properties, '$.render.fingerprint') IS NOT NULL", fsName).
+		Group("parent_id").
+		Having("SUM(json_extract(properties, '$.render.fingerprint') IN ?) != ? OR COUNT(*) != ?", fingerprints, len(fingerprints), len(fingerprints)).
+		Pluck("parent_id", &rtn).Error
+	return rtn, err
+}
+
 func DeleteAsset(id string) error {
-	return DB.Where("ID = ?", id).Delete(&entities.Asset{}).Error
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		// asset_tags rows aren't cascaded, clear them for the whole subtree first
+		if err := tx.Exec("DELETE FROM asset_tags WHERE asset_id IN ("+fmt.Sprintf(subtreeIDs, "id = ?")+")", id).Error; err != nil {
+			return err
+		}
+		return tx.Where("ID = ?", id).Delete(&entities.Asset{}).Error
+	}); err != nil {
+		return err
+	}
+	publishAssetEvent(&entities.Asset{ID: id}, "delete")
+	return nil
 }
 
 func UpdateAssetThumbnail(a *entities.Asset, thumbnailID string) error {
@@ -277,13 +544,123 @@ func UpdateAssetProperties(a *entities.Asset, properties entities.Properties) er
 	return nil
 }
 
+func CountAssetsInFS(fsName string) (int64, error) {
+	var count int64
+	err := DB.Model(&entities.Asset{}).Where("fs_name = ?", fsName).Count(&count).Error
+	return count, err
+}
+
+// RerootFS points the assets of a filesystem that was moved to another root at
+// it, so they keep their IDs and metadata. Assets whose path is already known
+// under the new root are left for the scan to drop.
+func RerootFS(fsName, root string) (int64, error) {
+	var rerooted int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var assets []*entities.Asset
+		err := tx.Where("fs_name = ? AND root <> ? AND node_kind <> ?", fsName, root, entities.NodeKindBundled).
+			Find(&assets).Error
+		if err != nil {
+			return err
+		}
+		for _, a := range assets {
+			locator := entities.LocatorForPath(fsName, root, utils.VoZ(a.Path))
+			var taken int64
+			if err := tx.Model(&entities.Asset{}).Where("locator = ?", locator).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				continue
+			}
+			err := tx.Model(&entities.Asset{ID: a.ID}).Updates(map[string]any{
+				"root":    root,
+				"locator": locator,
+			}).Error
+			if err != nil {
+				return err
+			}
+			rerooted++
+		}
+		return nil
+	})
+	return rerooted, err
+}
+
 func SetDirtyFS(fsName string) error {
 	return DB.Model(&entities.Asset{}).Where("fs_name = ?", fsName).Update("seen_on_scan", false).Error
 }
 
-func DeleteUnseenInFS(fsName string) error {
+func UpdateAssetFingerprint(a *entities.Asset) error {
+	return DB.Model(&entities.Asset{ID: a.ID}).Updates(map[string]any{
+		"size":         a.Size,
+		"mod_time":     a.ModTime,
+		"seen_on_scan": true,
+	}).Error
+}
+
+func MarkAssetSeen(id string) error {
+	return DB.Model(&entities.Asset{}).Where("id = ?", id).Update("seen_on_scan", true).Error
+}
+
+func DeleteUnseenInFS(fsName string) (int64, error) {
+	seen := false
+	var removed int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var unseen []string
+		if err := tx.Model(&entities.Asset{}).Where("fs_name = ? AND seen_on_scan = ?", fsName, seen).Pluck("id", &unseen).Error; err != nil {
+			return err
+		}
+		if err := trashUnseen(tx, unseen); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM asset_tags WHERE asset_id IN ("+fmt.Sprintf(subtreeIDs, "fs_name = ? AND seen_on_scan = ?")+")", fsName, seen).Error; err != nil {
+			return err
+		}
+		res := tx.Where("fs_name = ? AND seen_on_scan = ?", fsName, seen).Delete(&entities.Asset{})
+		removed = res.RowsAffected
+		return res.Error
+	})
+	return removed, err
+}
+
+// SetDirtySubtree marks the assets under id that belong to the filesystem itself
+// as unseen, bundle contents are only rediscovered with their bundle.
+func SetDirtySubtree(id, fsName string) error {
+	return DB.Exec("UPDATE assets SET seen_on_scan = ? WHERE fs_name = ? AND id IN ("+fmt.Sprintf(subtreeIDs, "id = ?")+")", false, fsName, id).Error
+}
+
+func DeleteUnseenInSubtree(id, fsName string) (int64, error) {
 	seen := false
-	return DB.Where("fs_name = ? AND seen_on_scan = ?", fsName, seen).Delete(&entities.Asset{}).Error
+	var removed int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Raw(fmt.Sprintf(subtreeIDs, "id = ?"), id).Scan(&ids).Error; err != nil {
+			return err
+		}
+		var unseen []string
+		if err := tx.Model(&entities.Asset{}).Where("id IN ? AND fs_name = ? AND seen_on_scan = ?", ids, fsName, seen).Pluck("id", &unseen).Error; err != nil {
+			return err
+		}
+		if len(unseen) == 0 {
+			return nil
+		}
+		if err := trashUnseen(tx, unseen); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM asset_tags WHERE asset_id IN ("+fmt.Sprintf(subtreeIDs, "id IN ?")+")", unseen).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id IN ?", unseen).Delete(&entities.Asset{})
+		removed = res.RowsAffected
+		return res.Error
+	})
+	return removed, err
+}
+
+// FindThumbnail returns the image listings show for an asset that has no
+// thumbnail of its own.
+func FindThumbnail(id string) (string, bool) {
+	id, ok := findThumbnailsForAssets([]string{id})[id]
+	return id, ok
 }
 
 func findThumbnailsForAssets(assetIDs []string) map[string]string {
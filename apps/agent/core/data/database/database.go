@@ -61,6 +61,14 @@ func InitDatabase(logger *zap.Logger) error {
 		return fmt.Errorf("failed to initialize assets: %w", err)
 	}
 
+	if err = initJobs(); err != nil {
+		return fmt.Errorf("failed to initialize jobs: %w", err)
+	}
+
+	if err = initTrash(); err != nil {
+		return fmt.Errorf("failed to initialize trash: %w", err)
+	}
+
 	// Check if migration is needed (old projects table exists)
 	var count int64
 	if err = DB.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='projects'").Scan(&count).Error; err == nil && count > 0 {
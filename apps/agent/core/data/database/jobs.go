@@ -0,0 +1,199 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+	"gorm.io/gorm"
+)
+
+var ErrJobState = errors.New("job is not in a state that allows this operation")
+
+type JobFilter struct {
+	State   string
+	Kind    string
+	AssetID string
+}
+
+func initJobs() error {
+	return DB.AutoMigrate(&entities.Job{})
+}
+
+// EnqueueJob adds a job unless the same work is already waiting for the asset,
+// the job then takes the ID of the waiting one.
+func EnqueueJob(j *entities.Job) (bool, error) {
+	created := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var pending entities.Job
+		err := tx.Select("id").
+			Where("kind = ? AND asset_id = ? AND state = ?", j.Kind, j.AssetID, entities.JobPending).
+			Take(&pending).Error
+		if err == nil {
+			j.ID = pending.ID
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		created = true
+		return tx.Create(j).Error
+	})
+	return created, err
+}
+
+// ClaimJob marks the next due pending job as running and returns it.
+func ClaimJob() (*entities.Job, error) {
+	var job entities.Job
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ? AND run_at <= ?", entities.JobPending, time.Now()).
+			Order("run_at ASC").
+			First(&job).Error; err != nil {
+			return err
+		}
+		job.State = entities.JobRunning
+		job.Attempts++
+		return tx.Model(&entities.Job{ID: job.ID}).Updates(map[string]any{
+			"state":    job.State,
+			"attempts": job.Attempts,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func GetJob(id string) (*entities.Job, error) {
+	var job entities.Job
+	return &job, DB.Where("id = ?", id).First(&job).Error
+}
+
+func CompleteJob(id string) error {
+	return DB.Model(&entities.Job{ID: id}).Updates(map[string]any{
+		"state":      entities.JobDone,
+		"last_error": nil,
+	}).Error
+}
+
+// FailJob records the error and schedules a retry after backoff, or marks the
+// job as failed once it ran out of attempts.
+func FailJob(j *entities.Job, jobErr error, backoff time.Duration) error {
+	msg := jobErr.Error()
+	updates := map[string]any{"last_error": msg}
+	if j.Attempts >= j.MaxAttempts {
+		updates["state"] = entities.JobFailed
+	} else {
+		updates["state"] = entities.JobPending
+		updates["run_at"] = time.Now().Add(backoff)
+	}
+	return DB.Model(&entities.Job{ID: j.ID}).Updates(updates).Error
+}
+
+// ResetRunningJobs puts jobs interrupted by a shutdown back in the queue.
+func ResetRunningJobs() (int64, error) {
+	res := DB.Model(&entities.Job{}).Where("state = ?", entities.JobRunning).Updates(map[string]any{
+		"state":  entities.JobPending,
+		"run_at": time.Now(),
+	})
+	return res.RowsAffected, res.Error
+}
+
+// PruneJobs removes finished jobs last updated before the given time.
+func PruneJobs(before time.Time) (int64, error) {
+	res := DB.Where("state = ? AND updated_at < ?", entities.JobDone, before).Delete(&entities.Job{})
+	return res.RowsAffected, res.Error
+}
+
+func RetryJob(id string) (*entities.Job, error) {
+	res := DB.Model(&entities.Job{}).
+		Where("id = ? AND state IN ?", id, []entities.JobState{entities.JobFailed, entities.JobCancelled, entities.JobDone}).
+		Updates(map[string]any{
+			"state":    entities.JobPending,
+			"attempts": 0,
+			"run_at":   time.Now(),
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := GetJob(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobState
+	}
+	return GetJob(id)
+}
+
+func CancelJob(id string) (*entities.Job, error) {
+	res := DB.Model(&entities.Job{}).
+		Where("id = ? AND state IN ?", id, []entities.JobState{entities.JobPending, entities.JobRunning, entities.JobFailed}).
+		Update("state", entities.JobCancelled)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := GetJob(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobState
+	}
+	return GetJob(id)
+}
+
+// ActiveJobs returns the jobs among ids that are pending or running, with only
+// their ID and kind.
+func ActiveJobs(ids []string) ([]*entities.Job, error) {
+	var rtn []*entities.Job
+	// Keeps well below the SQLite limit on query parameters
+	for chunk := range slices.Chunk(ids, 500) {
+		var jobs []*entities.Job
+		if err := DB.Select("id", "kind").
+			Where("id IN ? AND state IN ?", chunk, []entities.JobState{entities.JobPending, entities.JobRunning}).
+			Find(&jobs).Error; err != nil {
+			return nil, err
+		}
+		rtn = append(rtn, jobs...)
+	}
+	return rtn, nil
+}
+
+func CountJobs(filter JobFilter) (int64, error) {
+	var count int64
+	return count, jobsQuery(filter).Count(&count).Error
+}
+
+func GetJobsPaginated(filter JobFilter, page, perPage int) ([]*entities.Job, int, error) {
+	var jobs []*entities.Job
+	var totalRows int64
+
+	if err := jobsQuery(filter).Count(&totalRows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	totalPages := int(math.Ceil(float64(totalRows) / float64(perPage)))
+
+	err := jobsQuery(filter).
+		Order("created_at DESC").
+		Offset(page * perPage).
+		Limit(perPage).
+		Find(&jobs).Error
+
+	return jobs, totalPages, err
+}
+
+func jobsQuery(filter JobFilter) *gorm.DB {
+	q := DB.Model(&entities.Job{})
+	if filter.State != "" {
+		q = q.Where("state = ?", filter.State)
+	}
+	if filter.Kind != "" {
+		q = q.Where("kind = ?", filter.Kind)
+	}
+	if filter.AssetID != "" {
+		q = q.Where("asset_id = ?", filter.AssetID)
+	}
+	return q
+}
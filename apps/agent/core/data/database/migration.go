@@ -55,6 +55,7 @@ func MigrateToUnifiedAssetModel() error {
 
 		rootAsset := &entities.Asset{
 			ID:          newID,
+			Locator:     newID,
 			Label:       &project.Name,
 			Description: &project.Description,
 			Path:        &projectPath,
@@ -122,6 +123,7 @@ func MigrateToUnifiedAssetModel() error {
 		parentID := parentAsset.ID
 		newAsset := &entities.Asset{
 			ID:         newID,
+			Locator:    newID,
 			Label:      &oldAsset.Label,
 			Path:       &assetPath,
 			Root:       libraryPath,
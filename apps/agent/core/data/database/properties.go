@@ -0,0 +1,45 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PropertyOps are the comparisons property filters support.
+var PropertyOps = []string{"<=", ">=", "!=", "=", "<", ">"}
+
+// PropertyFilter compares a property of the assets with a value, nested
+// properties are reached with dots as in geometry.size_z.
+type PropertyFilter struct {
+	Key   string
+	Op    string
+	Value any // float64, bool or string
+}
+
+func applyPropertyFilters(q *gorm.DB, filters []PropertyFilter) *gorm.DB {
+	for _, f := range filters {
+		value := f.Value
+		if b, ok := value.(bool); ok {
+			// json_extract returns booleans as 1 and 0
+			value = 0
+			if b {
+				value = 1
+			}
+		}
+		q = q.Where(fmt.Sprintf("json_extract(assets.properties, ?) %s ?", f.Op), propertyPath(f.Key), value)
+	}
+	return q
+}
+
+func propertyPath(key string) string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, part := range strings.Split(key, ".") {
+		sb.WriteString(`."`)
+		sb.WriteString(part)
+		sb.WriteString(`"`)
+	}
+	return sb.String()
+}
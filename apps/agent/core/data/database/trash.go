@@ -0,0 +1,239 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/utils"
+	"gorm.io/gorm"
+)
+
+func initTrash() error {
+	return DB.AutoMigrate(&entities.TrashEntry{})
+}
+
+// TrashAsset snapshots the asset subtree of the entry into it and deletes the
+// rows. Entries without content are only kept when there was an asset.
+func TrashAsset(e *entities.TrashEntry) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if e.AssetID == "" {
+			var ids []string
+			if err := tx.Model(&entities.Asset{}).Where("locator = ?", e.Locator).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				e.AssetID = ids[0]
+			}
+		}
+		ids, err := snapshotSubtree(tx, e)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 && !e.HasContent {
+			return nil
+		}
+		if err := tx.Create(e).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Exec("DELETE FROM asset_tags WHERE asset_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", e.AssetID).Delete(&entities.Asset{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if len(e.Assets) > 0 {
+		publishAssetEvent(&entities.Asset{ID: e.AssetID}, "delete")
+	}
+	return nil
+}
+
+func snapshotSubtree(tx *gorm.DB, e *entities.TrashEntry) ([]string, error) {
+	var ids []string
+	if err := tx.Raw(fmt.Sprintf(subtreeIDs, "id = ?"), e.AssetID).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	var assets []*entities.Asset
+	if err := tx.Where("id IN ?", ids).Preload("Tags").Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range assets {
+		if a.ID != e.AssetID {
+			continue
+		}
+		e.ParentID, e.Label, e.NodeKind = a.ParentID, a.Label, a.NodeKind
+		if !e.HasContent {
+			e.Size = a.Size
+		}
+	}
+	e.Assets = assets
+	return ids, nil
+}
+
+// trashUnseen keeps the rows of assets a scan didn't find anymore, one entry
+// for each of the topmost ones.
+func trashUnseen(tx *gorm.DB, unseen []string) error {
+	if len(unseen) == 0 {
+		return nil
+	}
+	var assets []*entities.Asset
+	if err := tx.Select("id", "parent_id", "fs_name", "root", "path", "locator").Where("id IN ?", unseen).Find(&assets).Error; err != nil {
+		return err
+	}
+
+	gone := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		gone[a.ID] = true
+	}
+	for _, a := range assets {
+		if a.ParentID != nil && gone[*a.ParentID] {
+			continue
+		}
+		e := entities.NewTrashEntry(a.FSName, a.Root, utils.VoZ(a.Path))
+		e.AssetID = a.ID
+		if _, err := snapshotSubtree(tx, e); err != nil {
+			return err
+		}
+		if err := tx.Create(e).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetTrashEntries() ([]*entities.TrashEntry, error) {
+	var rtn []*entities.TrashEntry
+	return rtn, DB.Order("deleted_at DESC").Find(&rtn).Error
+}
+
+func GetTrashEntry(id string) (entities.TrashEntry, error) {
+	var e entities.TrashEntry
+	return e, DB.Where("id = ?", id).First(&e).Error
+}
+
+func DeleteTrashEntry(id string) error {
+	return DB.Where("id = ?", id).Delete(&entities.TrashEntry{}).Error
+}
+
+// GetExpiredTrashEntries returns the entries deleted before the given time.
+func GetExpiredTrashEntries(before time.Time) ([]*entities.TrashEntry, error) {
+	var rtn []*entities.TrashEntry
+	return rtn, DB.Select("id", "asset_id", "fs_name", "path", "has_content", "assets", "deleted_at").
+		Where("deleted_at < ?", before).Find(&rtn).Error
+}
+
+// RestoreAssets puts the rows of a snapshot back before its files are
+// rediscovered, so the assets get their IDs back. Rows whose path is taken or
+// whose parent is gone are left to discovery.
+func RestoreAssets(assets []*entities.Asset) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Parents have to go in before their children, the snapshot isn't ordered
+		pending := assets
+		for len(pending) > 0 {
+			var next []*entities.Asset
+			for _, a := range pending {
+				restored, err := restoreAsset(tx, a)
+				if err != nil {
+					return err
+				}
+				if !restored {
+					next = append(next, a)
+				}
+			}
+			if len(next) == len(pending) {
+				return nil
+			}
+			pending = next
+		}
+		return nil
+	})
+}
+
+// restoreAsset inserts a snapshot row, false means its parent isn't there.
+func restoreAsset(tx *gorm.DB, a *entities.Asset) (bool, error) {
+	var n int64
+	if err := tx.Model(&entities.Asset{}).Where("id = ? OR locator = ?", a.ID, a.Locator).Count(&n).Error; err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return true, nil
+	}
+	if a.ParentID != nil {
+		if err := tx.Model(&entities.Asset{}).Where("id = ?", *a.ParentID).Count(&n).Error; err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return false, nil
+		}
+	}
+
+	row := *a
+	// Discovery checks the files again
+	row.ModTime, row.Hash = nil, nil
+	if err := tx.Omit("NestedAssets", "Tags", "Parent").Create(&row).Error; err != nil {
+		return false, err
+	}
+	if err := EnsureTags(tx, a.Tags); err != nil {
+		return false, err
+	}
+	return true, tx.Model(&row).Association("Tags").Replace(a.Tags)
+}
+
+// RestoreAssetMetadata puts labels, descriptions, properties and tags of a
+// snapshot back on the assets rediscovered at the same paths.
+func RestoreAssetMetadata(assets []*entities.Asset) error {
+	restored := make([]*entities.Asset, 0, len(assets))
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, a := range assets {
+			var current entities.Asset
+			if err := tx.Where("locator = ?", a.Locator).First(&current).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				return err
+			}
+
+			// What processing found since is kept unless the snapshot says otherwise
+			properties := current.Properties
+			if properties == nil {
+				properties = make(entities.Properties)
+			}
+			for k, v := range a.Properties {
+				properties[k] = v
+			}
+			if err := tx.Model(&current).Updates(map[string]any{
+				"label":       a.Label,
+				"description": a.Description,
+				"properties":  properties,
+			}).Error; err != nil {
+				return err
+			}
+
+			if err := EnsureTags(tx, a.Tags); err != nil {
+				return err
+			}
+			if err := tx.Model(&current).Association("Tags").Replace(a.Tags); err != nil {
+				return err
+			}
+			restored = append(restored, &current)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, a := range restored {
+		publishAssetEvent(a, "update")
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"github.com/eduardooliveira/stLib/core/utils"
@@ -24,12 +25,13 @@ const (
 )
 
 type Asset struct {
-	ID           string     `json:"id" gorm:"primaryKey"`
+	ID           string     `json:"id" gorm:"primaryKey"`       // stable, survives moves and renames
+	Locator      string     `json:"locator" gorm:"uniqueIndex"` // derived from filesystem, root and path
 	Label        *string    `json:"label"`
 	Description  *string    `json:"description,omitempty"`
 	Path         *string    `json:"path,omitempty"`
 	Root         string     `json:"root"`
-	FSKind       string     `json:"fs_kind"` // "local" for now
+	FSKind       string     `json:"fs_kind"` // kind of the filesystem, or "bundle" in and for bundles
 	FSName       string     `json:"fs_name"` // filesystem name
 	Extension    *string    `json:"extension,omitempty"`
 	Kind         *string    `json:"kind,omitempty"` // asset type: "model", "image", "dir", etc.
@@ -39,6 +41,9 @@ type Asset struct {
 	NestedAssets []*Asset   `json:"nested_assets,omitempty" gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE;"`
 	Thumbnail    *string    `json:"thumbnail,omitempty"`
 	SeenOnScan   *bool      `json:"seen_on_scan,omitempty"`
+	Size         int64      `json:"size,omitempty"`
+	ModTime      *time.Time `json:"mod_time,omitempty"`
+	Hash         *string    `json:"hash,omitempty"` // SHA-1 of the content, only when library.hash_files is enabled
 	Properties   Properties `json:"properties,omitempty" gorm:"type:json"`
 	Tags         []*Tag     `json:"tags,omitempty" gorm:"many2many:asset_tags"`
 	CreatedAt    time.Time  `json:"created_at"`
@@ -58,15 +63,15 @@ func NewAsset(fsName, root, path string, isDir bool, parent *Asset) *Asset {
 // NewAssetWithFS creates a new asset with filesystem context for bundle detection
 func NewAssetWithFS(fs LibFS, fsName, root, path string, isDir bool, parent *Asset) *Asset {
 	ext := filepath.Ext(path)
-	id := generateAssetID(fsName, root, path)
 
 	asset := &Asset{
-		ID:         id,
+		ID:         uuid.New().String(),
+		Locator:    generateLocator(fsName, root, path),
 		Path:       utils.Ptr(path),
 		Root:       root,
 		FSName:     fsName,
 		FSKind:     "local",
-		Label:      utils.Ptr(strings.TrimSuffix(filepath.Base(path), ext)),
+		Label:      utils.Ptr(LabelForPath(path)),
 		Extension:  utils.Ptr(ext),
 		Properties: make(Properties),
 	}
@@ -82,7 +87,35 @@ func NewAssetWithFS(fs LibFS, fsName, root, path string, isDir bool, parent *Ass
 	return asset
 }
 
-func generateAssetID(fsName, root, path string) string {
+// LocatorForPath returns the locator of an asset discovered at path.
+func LocatorForPath(fsName, root, path string) string {
+	return generateLocator(fsName, root, path)
+}
+
+// LabelForPath returns the label an asset discovered at path would get.
+func LabelForPath(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// SetFingerprint records the size and modification time of the asset content.
+func (a *Asset) SetFingerprint(size int64, modTime time.Time) {
+	a.Size = size
+	a.ModTime = &modTime
+}
+
+// SameFingerprint reports whether the asset content matches a previously stored one.
+// Hashes win over size and modification time when both sides have them.
+func (a *Asset) SameFingerprint(other *Asset) bool {
+	if a.Hash != nil && other.Hash != nil {
+		return *a.Hash == *other.Hash
+	}
+	if a.ModTime == nil || other.ModTime == nil {
+		return false
+	}
+	return a.Size == other.Size && a.ModTime.Equal(*other.ModTime)
+}
+
+func generateLocator(fsName, root, path string) string {
 	data := []byte(filepath.Join(fsName, root, path))
 	md5Hash := md5.Sum(data)
 	return hex.EncodeToString(md5Hash[:])
@@ -100,6 +133,11 @@ func determineAssetType(path string, isDir bool, parent *Asset, fs LibFS, ext, f
 		return NodeKindBundled, "bundle", kind
 	}
 
+	fsKind := "local"
+	if fs != nil {
+		fsKind = fs.Kind()
+	}
+
 	// Directory handling
 	if isDir {
 		if parent == nil {
@@ -107,14 +145,14 @@ func determineAssetType(path string, isDir bool, parent *Asset, fs LibFS, ext, f
 			if label == "" {
 				label = filepath.Base(path)
 			}
-			return NodeKindRoot, "local", utils.Ptr("dir")
+			return NodeKindRoot, fsKind, utils.Ptr("dir")
 		}
-		return NodeKindDir, "local", utils.Ptr("dir")
+		return NodeKindDir, fsKind, utils.Ptr("dir")
 	}
 
 	// File handling
 	kind := inferKindFromExtension(ext)
-	return NodeKindFile, "local", kind
+	return NodeKindFile, fsKind, kind
 }
 
 func inferKindFromExtension(ext string) *string {
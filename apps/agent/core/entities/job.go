@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobFailed    JobState = "failed"
+	JobDone      JobState = "done"
+	JobCancelled JobState = "cancelled"
+)
+
+const (
+	JobKindRender = "render"
+	JobKindEnrich = "enrich"
+)
+
+type Job struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	Kind        string    `json:"kind" gorm:"index"`
+	AssetID     string    `json:"asset_id" gorm:"index"`
+	State       JobState  `json:"state" gorm:"index"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   *string   `json:"last_error,omitempty"`
+	RunAt       time.Time `json:"run_at" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewJob(kind, assetID string, maxAttempts int) *Job {
+	return &Job{
+		ID:          uuid.New().String(),
+		Kind:        kind,
+		AssetID:     assetID,
+		State:       JobPending,
+		MaxAttempts: maxAttempts,
+		RunAt:       time.Now(),
+	}
+}
@@ -0,0 +1,51 @@
+package entities
+
+// SliceMetadata is what a slicer recorded about a print, with the same keys
+// whichever slicer it was. What the slicer didn't record is left out.
+type SliceMetadata struct {
+	Slicer         string          `json:"slicer,omitempty"`
+	SlicerVersion  string          `json:"slicer_version,omitempty"`
+	PrinterModel   string          `json:"printer_model,omitempty"`
+	NozzleDiameter float64         `json:"nozzle_diameter,omitempty"` // mm, of the first extruder
+	Extruders      []SliceExtruder `json:"extruders,omitempty"`
+	LayerHeight    float64         `json:"layer_height,omitempty"`   // mm
+	InfillDensity  *float64        `json:"infill_density,omitempty"` // %
+	Supports       *bool           `json:"supports,omitempty"`
+	EstimatedTime  float64         `json:"estimated_time,omitempty"` // s
+	Weight         float64         `json:"weight,omitempty"`         // g
+}
+
+// SliceExtruder is the nozzle and filament of an extruder, or of a filament
+// slot on printers that swap filaments through one nozzle.
+type SliceExtruder struct {
+	NozzleDiameter float64 `json:"nozzle_diameter,omitempty"`
+	FilamentType   string  `json:"filament_type,omitempty"`
+	FilamentColor  string  `json:"filament_color,omitempty"`
+}
+
+// SlicePlate is a build plate of a 3MF project, what is on it and what it
+// prints with. Thumbnail and GCode are the bundled assets of the plate.
+type SlicePlate struct {
+	Index         int                  `json:"index"`
+	Name          string               `json:"name,omitempty"`
+	Objects       []SlicePlateObject   `json:"objects"`
+	Filaments     []SlicePlateFilament `json:"filaments,omitempty"`
+	Thumbnail     string               `json:"thumbnail,omitempty"`
+	GCode         string               `json:"gcode,omitempty"`
+	EstimatedTime float64              `json:"estimated_time,omitempty"` // s, once sliced
+	Weight        float64              `json:"weight,omitempty"`         // g, once sliced
+}
+
+type SlicePlateObject struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Instances int    `json:"instances"`
+}
+
+// SlicePlateFilament is a filament slot a plate uses, numbered from 1.
+type SlicePlateFilament struct {
+	Slot   int     `json:"slot"`
+	Type   string  `json:"type,omitempty"`
+	Color  string  `json:"color,omitempty"`
+	Weight float64 `json:"weight,omitempty"` // g, once sliced
+}
@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrashEntry is something deleted from the library. Its files are kept under
+// the data path and its asset rows as a snapshot, until it's restored or purged.
+type TrashEntry struct {
+	ID       string   `json:"id" gorm:"primaryKey"`
+	AssetID  string   `json:"asset_id" gorm:"index"`
+	Locator  string   `json:"locator"`
+	ParentID *string  `json:"parent_id,omitempty"`
+	Root     string   `json:"root"`
+	FSName   string   `json:"fs_name"`
+	Path     string   `json:"path"`
+	Label    *string  `json:"label,omitempty"`
+	NodeKind NodeKind `json:"node_kind,omitempty"`
+	Size     int64    `json:"size"`
+	// HasContent is false when the files were already gone and only the rows were kept
+	HasContent bool      `json:"has_content"`
+	Assets     []*Asset  `json:"-" gorm:"serializer:json"`
+	DeletedAt  time.Time `json:"deleted_at" gorm:"index"`
+}
+
+func NewTrashEntry(fsName, root, path string) *TrashEntry {
+	return &TrashEntry{
+		ID:        uuid.New().String(),
+		Locator:   LocatorForPath(fsName, root, path),
+		Root:      root,
+		FSName:    fsName,
+		Path:      path,
+		DeletedAt: time.Now(),
+	}
+}
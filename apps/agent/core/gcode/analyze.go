@@ -0,0 +1,304 @@
+package gcode
+
+import (
+	"context"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Used when the file doesn't say otherwise
+	defaultAccel            = 1500.0 // mm/s²
+	defaultFilamentDiameter = 1.75   // mm
+	defaultFilamentDensity  = 1.24   // g/cm³, PLA
+)
+
+// ToolUsage is the filament a tool extruded.
+type ToolUsage struct {
+	Tool   int
+	Length float64 // mm
+	Mass   float64 // g
+}
+
+// Analysis describes a print from the moves of its G-code, whatever the
+// slicer wrote in its comments.
+type Analysis struct {
+	Layers           int
+	FirstLayerHeight float64
+	// LayerHeight is the most common height of the layers after the first
+	LayerHeight float64
+	// Min and Max bound the extruding moves
+	Min         Point
+	Max         Point
+	Filament    []ToolUsage
+	Toolchanges int
+
+	MaxNozzleTemp  float64
+	MaxBedTemp     float64
+	MaxChamberTemp float64
+
+	// EstimatedTime is in seconds, moves accelerate and slow down at corners
+	// but the printer limits other than acceleration aren't known
+	EstimatedTime float64
+}
+
+// FilamentLength is the filament extruded by all tools, in mm.
+func (a *Analysis) FilamentLength() float64 {
+	var rtn float64
+	for _, u := range a.Filament {
+		rtn += u.Length
+	}
+	return rtn
+}
+
+// FilamentMass is the filament extruded by all tools, in g.
+func (a *Analysis) FilamentMass() float64 {
+	var rtn float64
+	for _, u := range a.Filament {
+		rtn += u.Mass
+	}
+	return rtn
+}
+
+type analyzer struct {
+	a Analysis
+
+	printAccel  float64
+	travelAccel float64
+
+	tool      int
+	toolSet   bool
+	extruded  map[int]float64
+	layers    map[int64]struct{}
+	diameters []float64
+	densities []float64
+
+	// The time of a move is known once the next one tells how fast it can
+	// leave its end
+	prev      *Move
+	prevEntry float64
+	prevAccel float64
+}
+
+// Analyze walks the moves of a G-code file.
+func Analyze(ctx context.Context, r io.Reader) (*Analysis, error) {
+	an := &analyzer{
+		printAccel:  defaultAccel,
+		travelAccel: defaultAccel,
+		extruded:    make(map[int]float64),
+		layers:      make(map[int64]struct{}),
+	}
+	an.a.Min = Point{math.Inf(1), math.Inf(1), math.Inf(1)}
+	an.a.Max = Point{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+
+	in := &Interpreter{OnCommand: an.command, OnMove: an.move}
+	if err := in.Run(ctx, r); err != nil {
+		return nil, err
+	}
+	an.flush(0)
+	an.finish()
+	return &an.a, nil
+}
+
+func (an *analyzer) command(c *Command) error {
+	switch {
+	case c.Letter == 0 && c.Name == "":
+		an.comment(c.Comment)
+	case c.Letter == 'T':
+		if an.toolSet && c.Number != an.tool {
+			an.a.Toolchanges++
+		}
+		an.tool, an.toolSet = c.Number, true
+	case c.Is('M', 104), c.Is('M', 109):
+		an.a.MaxNozzleTemp = math.Max(an.a.MaxNozzleTemp, math.Max(c.Get('S'), c.Get('R')))
+	case c.Is('M', 140), c.Is('M', 190):
+		an.a.MaxBedTemp = math.Max(an.a.MaxBedTemp, math.Max(c.Get('S'), c.Get('R')))
+	case c.Is('M', 141), c.Is('M', 191):
+		an.a.MaxChamberTemp = math.Max(an.a.MaxChamberTemp, math.Max(c.Get('S'), c.Get('R')))
+	case c.Is('M', 204):
+		if c.Has('S') && c.Get('S') > 0 {
+			an.printAccel, an.travelAccel = c.Get('S'), c.Get('S')
+		}
+		if c.Has('P') && c.Get('P') > 0 {
+			an.printAccel = c.Get('P')
+		}
+		if c.Has('T') && c.Get('T') > 0 {
+			an.travelAccel = c.Get('T')
+		}
+	case c.Is('G', 4):
+		an.flush(0)
+		an.a.EstimatedTime += c.Get('P')/1000 + c.Get('S')
+	case c.Name == "SET_VELOCITY_LIMIT":
+		if v, ok := c.Arg("ACCEL"); ok {
+			if accel, err := strconv.ParseFloat(v, 64); err == nil && accel > 0 {
+				an.printAccel, an.travelAccel = accel, accel
+			}
+		}
+	}
+	return nil
+}
+
+// comment picks the filament settings slicers write as key = value or
+// key: value, lists have a value per tool.
+func (an *analyzer) comment(s string) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		key, value, ok = strings.Cut(s, ":")
+	}
+	if !ok {
+		return
+	}
+	switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), " ", "_") {
+	case "filament_diameter":
+		an.diameters = floatList(value)
+	case "filament_density":
+		an.densities = floatList(value)
+	}
+}
+
+func (an *analyzer) move(m Move) error {
+	if m.E != 0 {
+		an.extruded[m.Tool] += m.E
+	}
+	if m.Extruding() {
+		an.layers[int64(math.Round(m.To.Z*1000))] = struct{}{}
+		for _, p := range []Point{m.From, m.To} {
+			an.a.Min = Point{math.Min(an.a.Min.X, p.X), math.Min(an.a.Min.Y, p.Y), math.Min(an.a.Min.Z, p.Z)}
+			an.a.Max = Point{math.Max(an.a.Max.X, p.X), math.Max(an.a.Max.Y, p.Y), math.Max(an.a.Max.Z, p.Z)}
+		}
+	}
+
+	if m.From == m.To {
+		// Retracting and priming in place
+		an.flush(0)
+		an.a.EstimatedTime += math.Abs(m.E) / m.Feedrate
+		return nil
+	}
+
+	accel := an.travelAccel
+	if m.E > 0 {
+		accel = an.printAccel
+	}
+	entry := 0.0
+	if an.prev != nil {
+		entry = junctionSpeed(an.prev, &m)
+		an.flush(entry)
+	}
+	an.prev, an.prevEntry, an.prevAccel = &m, entry, accel
+	return nil
+}
+
+// flush adds the time of the pending move, leaving it at the exit speed.
+func (an *analyzer) flush(exit float64) {
+	if an.prev == nil {
+		return
+	}
+	an.a.EstimatedTime += moveTime(an.prev.Length(), an.prevEntry, an.prev.Feedrate, exit, an.prevAccel)
+	an.prev = nil
+}
+
+func (an *analyzer) finish() {
+	a := &an.a
+	if len(an.layers) == 0 {
+		a.Min, a.Max = Point{}, Point{}
+	}
+
+	zs := make([]int64, 0, len(an.layers))
+	for z := range an.layers {
+		zs = append(zs, z)
+	}
+	slices.Sort(zs)
+	a.Layers = len(zs)
+	if len(zs) > 0 {
+		a.FirstLayerHeight = float64(zs[0]) / 1000
+	}
+	heights := make(map[int64]int)
+	for i := 1; i < len(zs); i++ {
+		heights[zs[i]-zs[i-1]]++
+	}
+	var common int64
+	for h, n := range heights {
+		if n > heights[common] || n == heights[common] && h < common {
+			common = h
+		}
+	}
+	a.LayerHeight = float64(common) / 1000
+
+	tools := make([]int, 0, len(an.extruded))
+	for t := range an.extruded {
+		tools = append(tools, t)
+	}
+	slices.Sort(tools)
+	for _, t := range tools {
+		length := math.Max(an.extruded[t], 0)
+		if length == 0 {
+			continue
+		}
+		d := toolValue(an.diameters, t, defaultFilamentDiameter)
+		density := toolValue(an.densities, t, defaultFilamentDensity)
+		volume := math.Pi * d * d / 4 * length / 1000 // cm³
+		a.Filament = append(a.Filament, ToolUsage{Tool: t, Length: length, Mass: volume * density})
+	}
+}
+
+// junctionSpeed is how fast the toolhead can go from one move into the next,
+// the sharper the corner the slower.
+func junctionSpeed(a, b *Move) float64 {
+	la, lb := a.Length(), b.Length()
+	if la == 0 || lb == 0 {
+		return 0
+	}
+	cos := ((a.To.X-a.From.X)*(b.To.X-b.From.X) +
+		(a.To.Y-a.From.Y)*(b.To.Y-b.From.Y) +
+		(a.To.Z-a.From.Z)*(b.To.Z-b.From.Z)) / (la * lb)
+	if cos <= 0 {
+		return 0
+	}
+	return math.Min(a.Feedrate, b.Feedrate) * cos
+}
+
+// moveTime is the time of a trapezoidal speed profile over length, from the
+// entry speed up to the cruise speed and down to the exit speed.
+func moveTime(length, entry, cruise, exit, accel float64) float64 {
+	if length <= 0 || cruise <= 0 {
+		return 0
+	}
+	accelDist := (cruise*cruise - entry*entry) / (2 * accel)
+	decelDist := (cruise*cruise - exit*exit) / (2 * accel)
+	if accelDist+decelDist <= length {
+		return (cruise-entry)/accel + (cruise-exit)/accel + (length-accelDist-decelDist)/cruise
+	}
+
+	// Too short to reach the cruise speed
+	peak := math.Sqrt((2*accel*length + entry*entry + exit*exit) / 2)
+	if peak < math.Max(entry, exit) {
+		return length / ((entry + exit) / 2)
+	}
+	return (peak-entry)/accel + (peak-exit)/accel
+}
+
+func floatList(s string) []float64 {
+	var rtn []float64
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil
+		}
+		rtn = append(rtn, v)
+	}
+	return rtn
+}
+
+// toolValue picks the value of a tool from a list, single values apply to all.
+func toolValue(values []float64, tool int, def float64) float64 {
+	switch {
+	case tool >= 0 && tool < len(values) && values[tool] > 0:
+		return values[tool]
+	case len(values) > 0 && values[0] > 0:
+		return values[0]
+	}
+	return def
+}
@@ -0,0 +1,400 @@
+package gcode
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultFeedrate is used until a file sets one, in mm/s
+	defaultFeedrate = 50.0
+	// arcSegment is the length of the straight moves arcs are split into
+	arcSegment     = 1.0
+	maxArcSegments = 720
+	// ctxCheckLines is how often a run looks at its context
+	ctxCheckLines = 1 << 16
+)
+
+// Point is a position in millimetres.
+type Point struct {
+	X, Y, Z float64
+}
+
+// Move is a straight segment of the toolhead, arcs are split into several.
+type Move struct {
+	From Point
+	To   Point
+	// E is the filament pushed during the move, negative when retracting
+	E float64
+	// Feedrate is in mm/s
+	Feedrate float64
+	Tool     int
+	// Line is where the move is in the file, starting at 1
+	Line int
+}
+
+// Extruding reports whether the move lays down material.
+func (m Move) Extruding() bool {
+	return m.E > 0 && (m.From.X != m.To.X || m.From.Y != m.To.Y)
+}
+
+// Length is the distance the toolhead travels.
+func (m Move) Length() float64 {
+	return math.Sqrt(sq(m.To.X-m.From.X) + sq(m.To.Y-m.From.Y) + sq(m.To.Z-m.From.Z))
+}
+
+// Command is a line of G-code. Letter and Number are set for G, M and T
+// codes, Name and Args for extended commands like SET_VELOCITY_LIMIT. Lines
+// holding only a comment have neither.
+type Command struct {
+	Letter  byte
+	Number  int
+	Name    string
+	Args    string
+	Comment string
+	Line    int
+
+	params [26]float64
+	has    uint32
+}
+
+// Has reports whether the command sets parameter p, an upper case letter.
+func (c *Command) Has(p byte) bool {
+	return p >= 'A' && p <= 'Z' && c.has&(1<<(p-'A')) != 0
+}
+
+// Get returns parameter p, 0 when it isn't set.
+func (c *Command) Get(p byte) float64 {
+	if !c.Has(p) {
+		return 0
+	}
+	return c.params[p-'A']
+}
+
+// Is reports whether the command is the given G, M or T code.
+func (c *Command) Is(letter byte, number int) bool {
+	return c.Letter == letter && c.Number == number
+}
+
+// Arg returns a KEY=VALUE argument of an extended command.
+func (c *Command) Arg(key string) (string, bool) {
+	for _, f := range strings.Fields(c.Args) {
+		k, v, ok := strings.Cut(f, "=")
+		if ok && strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Interpreter runs G-code keeping track of the toolhead like a printer would:
+// absolute and relative modes, G92 offsets, units and the active tool.
+type Interpreter struct {
+	// OnCommand is called for every line, before moves are applied
+	OnCommand func(c *Command) error
+	// OnMove is called for every move of the toolhead or the extruder
+	OnMove func(m Move) error
+
+	pos      Point
+	e        float64
+	relXYZ   bool
+	relE     bool
+	inches   bool
+	feedrate float64
+	tool     int
+}
+
+// Run interprets r until its end.
+func (in *Interpreter) Run(ctx context.Context, r io.Reader) error {
+	in.feedrate = defaultFeedrate
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var c Command
+	for line := 1; scanner.Scan(); line++ {
+		if line%ctxCheckLines == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if !parseLine(scanner.Text(), line, &c) {
+			continue
+		}
+		if in.OnCommand != nil {
+			if err := in.OnCommand(&c); err != nil {
+				return err
+			}
+		}
+		if err := in.apply(&c); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (in *Interpreter) apply(c *Command) error {
+	switch c.Letter {
+	case 'G':
+		switch c.Number {
+		case 0, 1:
+			return in.move(c)
+		case 2, 3:
+			return in.arc(c)
+		case 20:
+			in.inches = true
+		case 21:
+			in.inches = false
+		case 90:
+			in.relXYZ, in.relE = false, false
+		case 91:
+			in.relXYZ, in.relE = true, true
+		case 92:
+			if c.Has('X') {
+				in.pos.X = in.units(c.Get('X'))
+			}
+			if c.Has('Y') {
+				in.pos.Y = in.units(c.Get('Y'))
+			}
+			if c.Has('Z') {
+				in.pos.Z = in.units(c.Get('Z'))
+			}
+			if c.Has('E') {
+				in.e = in.units(c.Get('E'))
+			}
+		}
+	case 'M':
+		switch c.Number {
+		case 82:
+			in.relE = false
+		case 83:
+			in.relE = true
+		}
+	case 'T':
+		in.tool = c.Number
+	}
+	return nil
+}
+
+func (in *Interpreter) move(c *Command) error {
+	to, e := in.target(c)
+	m := Move{From: in.pos, To: to, E: e, Feedrate: in.feedrate, Tool: in.tool, Line: c.Line}
+	in.pos = to
+	if m.From == m.To && m.E == 0 || in.OnMove == nil {
+		return nil
+	}
+	return in.OnMove(m)
+}
+
+// arc splits G2 and G3 moves, clockwise and counterclockwise, in straight
+// segments. The center is given by I and J or by the radius R.
+func (in *Interpreter) arc(c *Command) error {
+	from := in.pos
+	to, e := in.target(c)
+
+	cx, cy := from.X+in.units(c.Get('I')), from.Y+in.units(c.Get('J'))
+	if c.Has('R') && !c.Has('I') && !c.Has('J') {
+		var ok bool
+		if cx, cy, ok = radiusCenter(from, to, in.units(c.Get('R')), c.Number == 2); !ok {
+			cx, cy = from.X, from.Y
+		}
+	}
+
+	r := math.Hypot(from.X-cx, from.Y-cy)
+	start := math.Atan2(from.Y-cy, from.X-cx)
+	sweep := math.Atan2(to.Y-cy, to.X-cx) - start
+	if c.Number == 2 {
+		if sweep >= 0 {
+			sweep -= 2 * math.Pi
+		}
+	} else if sweep <= 0 {
+		sweep += 2 * math.Pi
+	}
+
+	n := int(math.Ceil(math.Abs(sweep) * r / arcSegment))
+	n = min(max(n, 1), maxArcSegments)
+	prev := from
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		p := to
+		if i < n {
+			a := start + sweep*t
+			p = Point{cx + r*math.Cos(a), cy + r*math.Sin(a), from.Z + (to.Z-from.Z)*t}
+		}
+		m := Move{From: prev, To: p, E: e / float64(n), Feedrate: in.feedrate, Tool: in.tool, Line: c.Line}
+		if in.OnMove != nil {
+			if err := in.OnMove(m); err != nil {
+				return err
+			}
+		}
+		prev = p
+	}
+	in.pos = to
+	return nil
+}
+
+// target applies the parameters of a move to the current position, it
+// returns where the move ends and the filament it pushes.
+func (in *Interpreter) target(c *Command) (Point, float64) {
+	if c.Has('F') && c.Get('F') > 0 {
+		in.feedrate = in.units(c.Get('F')) / 60
+	}
+	to := in.pos
+	axis := func(p byte, v *float64) {
+		if !c.Has(p) {
+			return
+		}
+		if in.relXYZ {
+			*v += in.units(c.Get(p))
+		} else {
+			*v = in.units(c.Get(p))
+		}
+	}
+	axis('X', &to.X)
+	axis('Y', &to.Y)
+	axis('Z', &to.Z)
+
+	var e float64
+	if c.Has('E') {
+		if in.relE {
+			e = in.units(c.Get('E'))
+		} else {
+			e = in.units(c.Get('E')) - in.e
+		}
+		in.e += e
+	}
+	return to, e
+}
+
+func (in *Interpreter) units(v float64) float64 {
+	if in.inches {
+		return v * 25.4
+	}
+	return v
+}
+
+// radiusCenter finds the center of an arc given by its radius, a negative
+// radius picks the longer of the two arcs.
+func radiusCenter(from, to Point, r float64, clockwise bool) (float64, float64, bool) {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	d := math.Hypot(dx, dy)
+	if d == 0 || d > 2*math.Abs(r) {
+		return 0, 0, false
+	}
+	h := math.Sqrt(r*r - d*d/4)
+	if clockwise == (r > 0) {
+		h = -h
+	}
+	return from.X + dx/2 - h*dy/d, from.Y + dy/2 + h*dx/d, true
+}
+
+// parseLine reads a line into c, it reports false for blank lines. Words may
+// be split by spaces, tabs or nothing at all, like G1X10Y20E.5.
+func parseLine(s string, line int, c *Command) bool {
+	*c = Command{Line: line}
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		c.Comment = strings.TrimSpace(s[i+1:])
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return c.Comment != ""
+	}
+
+	// Line numbers come before the command and checksums after it
+	if upper(s[0]) == 'N' && len(s) > 1 && isDigit(s[1]) {
+		s = strings.TrimLeft(s[1:], "0123456789")
+		if i := strings.IndexByte(s, '*'); i >= 0 {
+			s = s[:i]
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return c.Comment != ""
+		}
+	}
+
+	letter := upper(s[0])
+	end := 1
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	n, err := strconv.Atoi(s[1:end])
+	// Subcodes like G29.1 are dropped
+	if end < len(s) && s[end] == '.' {
+		end++
+		for end < len(s) && isDigit(s[end]) {
+			end++
+		}
+	}
+	if err != nil || (letter != 'G' && letter != 'M' && letter != 'T') || (end < len(s) && !isSpace(s[end]) && !isLetter(s[end])) {
+		word, rest := s, ""
+		if i := strings.IndexAny(s, " \t"); i >= 0 {
+			word, rest = s[:i], s[i:]
+		}
+		c.Name, c.Args = strings.ToUpper(word), strings.TrimSpace(rest)
+		return true
+	}
+	c.Letter, c.Number = letter, n
+	parseParams(s[end:], c)
+	return true
+}
+
+// parseParams reads parameters like X10 Y-2.5 E.5, spaces between them are
+// optional. Words that aren't a letter and a number are skipped.
+func parseParams(s string, c *Command) {
+	for i := 0; i < len(s); {
+		if isSpace(s[i]) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(s) && isValue(s[j]) {
+			j++
+		}
+		if !isLetter(s[i]) || (j == i+1 && j < len(s) && !isSpace(s[j])) {
+			for i < len(s) && !isSpace(s[i]) {
+				i++
+			}
+			continue
+		}
+
+		p := upper(s[i])
+		v, err := strconv.ParseFloat(s[i+1:j], 64)
+		if err == nil || j == i+1 {
+			c.params[p-'A'] = v
+			c.has |= 1 << (p - 'A')
+		}
+		i = j
+	}
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isLetter(b byte) bool {
+	return upper(b) >= 'A' && upper(b) <= 'Z'
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// isValue reports whether b can be part of a parameter value.
+func isValue(b byte) bool {
+	return isDigit(b) || b == '.' || b == '-' || b == '+'
+}
+
+func upper(b byte) byte {
+	if b >= 'a' && b <= 'z' {
+		return b - 'a' + 'A'
+	}
+	return b
+}
+
+func sq(v float64) float64 {
+	return v * v
+}
@@ -0,0 +1,81 @@
+package gcode
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	for _, tc := range []struct {
+		line    string
+		blank   bool
+		letter  byte
+		number  int
+		name    string
+		args    string
+		comment string
+		params  map[byte]float64
+	}{
+		{line: "G1 X10 Y20 E.5 F1800", letter: 'G', number: 1, params: map[byte]float64{'X': 10, 'Y': 20, 'E': .5, 'F': 1800}},
+		{line: "G1X10Y20E.5", letter: 'G', number: 1, params: map[byte]float64{'X': 10, 'Y': 20, 'E': .5}},
+		{line: "G1\tX-1.5\tZ+.2", letter: 'G', number: 1, params: map[byte]float64{'X': -1.5, 'Z': .2}},
+		{line: "g0 x5 y6", letter: 'G', number: 0, params: map[byte]float64{'X': 5, 'Y': 6}},
+		{line: "N12 G1 X1*45", letter: 'G', number: 1, params: map[byte]float64{'X': 1}},
+		{line: "N12G92E0*71", letter: 'G', number: 92, params: map[byte]float64{'E': 0}},
+		{line: "G29.1 Z0.2", letter: 'G', number: 29, params: map[byte]float64{'Z': .2}},
+		{line: "G28 X ; home x", letter: 'G', number: 28, comment: "home x", params: map[byte]float64{'X': 0}},
+		{line: "T1", letter: 'T', number: 1, params: map[byte]float64{}},
+		{line: "M104S210T0", letter: 'M', number: 104, params: map[byte]float64{'S': 210, 'T': 0}},
+		{line: "M117 Hello world", letter: 'M', number: 117, params: map[byte]float64{}},
+		{line: "SET_VELOCITY_LIMIT ACCEL=500", name: "SET_VELOCITY_LIMIT", args: "ACCEL=500", params: map[byte]float64{}},
+		{line: "EXCLUDE_OBJECT_START\tNAME=cube", name: "EXCLUDE_OBJECT_START", args: "NAME=cube", params: map[byte]float64{}},
+		{line: "TIMELAPSE_TAKE_FRAME", name: "TIMELAPSE_TAKE_FRAME", params: map[byte]float64{}},
+		{line: ";LAYER_CHANGE", comment: "LAYER_CHANGE", params: map[byte]float64{}},
+		{line: "   ", blank: true},
+	} {
+		var c Command
+		if ok := parseLine(tc.line, 7, &c); ok == tc.blank {
+			t.Errorf("%q: parsed = %v", tc.line, ok)
+			continue
+		}
+		if tc.blank {
+			continue
+		}
+		if c.Letter != tc.letter || c.Number != tc.number || c.Name != tc.name || c.Args != tc.args || c.Comment != tc.comment || c.Line != 7 {
+			t.Errorf("%q: got %c%d name %q args %q comment %q, want %c%d name %q args %q comment %q",
+				tc.line, c.Letter, c.Number, c.Name, c.Args, c.Comment, tc.letter, tc.number, tc.name, tc.args, tc.comment)
+		}
+		params := make(map[byte]float64)
+		for p := byte('A'); p <= 'Z'; p++ {
+			if c.Has(p) {
+				params[p] = c.Get(p)
+			}
+		}
+		if !maps.Equal(params, tc.params) {
+			t.Errorf("%q: params = %v, want %v", tc.line, params, tc.params)
+		}
+	}
+}
+
+func TestAnalyzeCompactGCode(t *testing.T) {
+	spaced := "G21\nG90\nM83\nG1 Z0.2 F600\nG1 X10 Y10 E1\nG1 X20 Y10 E1\nG1 Z0.4\nG1 X20 Y20 E1\n"
+	compact := "G21\nG90\nM83\nG1Z0.2F600\nG1X10Y10E1\nG1\tX20\tY10\tE1\nG1Z.4\nG1X20Y20E1\n"
+
+	want, err := Analyze(context.Background(), strings.NewReader(spaced))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.Layers != 2 || want.FilamentLength() != 3 {
+		t.Fatalf("spaced G-code has %d layers and %v mm of filament, want 2 and 3", want.Layers, want.FilamentLength())
+	}
+	got, err := Analyze(context.Background(), strings.NewReader(compact))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("compact G-code analysis = %+v, want %+v", got, want)
+	}
+}
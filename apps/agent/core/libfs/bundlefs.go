@@ -1,12 +1,14 @@
 package libfs
 
 import (
+	"archive/zip"
 	"context"
 	"errors"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/eduardooliveira/stLib/core/entities"
 	"github.com/mholt/archiver/v4"
@@ -16,8 +18,17 @@ type bundleFS struct {
 	fs             fs.FS
 	parentFS       LibFS
 	bundleLocation string
-	name           string
-	root           string
+	// path of the archive in parentFS
+	path string
+	name string
+	root string
+	// files the archive was read from, rewrites add one, they're closed with
+	// the filesystem as entries opened before a rewrite may still read them
+	mu    sync.Mutex
+	files []*os.File
+	// owned is the filesystem holding the archive when it was opened along
+	// with it, it's closed with the bundle
+	owned LibFS
 }
 
 // getFileSystemByName retrieves a filesystem by name from the registry
@@ -28,66 +39,43 @@ func getFileSystemByName(fsName string) (LibFS, error) {
 	return nil, errors.New("file system not found")
 }
 
+// resolveBundleFS finds the filesystem of an asset flagged as bundle. Bundles
+// themselves live in a regular filesystem, or inside another bundle when
+// nested, while their contents live in the bundle owning them, its ID is the
+// contents' root.
 func resolveBundleFS(ctx context.Context, asset entities.Asset) (LibFS, error) {
-	if asset.FSKind == "bundle" {
-		return resolveParentFS(ctx, asset)
-	}
-	return getFileSystemByName(asset.FSName)
-}
-
-func resolveParentFS(ctx context.Context, asset entities.Asset) (LibFS, error) {
-	if asset.FSKind != "bundle" {
-		return getFileSystemByName(asset.FSName)
-	}
-
-	// Get the parent asset
-	parentNode, err := getParentAsset(asset)
-	if err != nil {
-		// If parent not loaded but ParentID exists, try to load it
-		if asset.ParentID != nil && asset.Parent == nil {
-			return nil, errors.New("parent asset not loaded - need to load parent chain")
-		}
-		return nil, err
+	if f, ok := fileSystems[asset.FSName]; ok && f.GetRoot() == asset.Root {
+		return f, nil
 	}
 
-	// Traverse up to find root filesystem
-	rootAsset, err := traverseToRoot(asset.Root, parentNode)
+	owner, err := findBundleOwner(asset)
 	if err != nil {
 		return nil, err
 	}
-
-	// Recursively resolve parent filesystem
-	parentFS, err := resolveParentFS(ctx, *rootAsset)
-	if err != nil {
-		return nil, err
+	if owner == nil {
+		return getFileSystemByName(asset.FSName)
 	}
 
-	return newBundleFS(ctx, parentFS, *rootAsset)
+	return OpenBundleFS(ctx, *owner)
 }
 
-func getParentAsset(asset entities.Asset) (*entities.Asset, error) {
-	if asset.Parent != nil {
-		return asset.Parent, nil
-	}
-	if asset.ParentID != nil {
-		return nil, errors.New("parent asset not loaded")
-	}
-	return nil, errors.New("no parent asset")
-}
-
-func traverseToRoot(rootID string, node *entities.Asset) (*entities.Asset, error) {
-	current := node
-	for current.ID != rootID && current.ParentID != nil {
-		if current.Parent != nil {
-			current = current.Parent
-		} else {
+// findBundleOwner walks up the loaded parent chain looking for the bundle the
+// asset was extracted from, nil means the asset isn't inside a bundle.
+func findBundleOwner(asset entities.Asset) (*entities.Asset, error) {
+	current := &asset
+	for current.ParentID != nil {
+		if current.Parent == nil {
 			return nil, errors.New("parent chain incomplete")
 		}
+		current = current.Parent
+		if current.ID == asset.Root {
+			return current, nil
+		}
 	}
-	return current, nil
+	return nil, nil
 }
 
-func newBundleFS(ctx context.Context, parentFS LibFS, asset entities.Asset) (LibFS, error) {
+func newBundleFS(ctx context.Context, parentFS LibFS, asset entities.Asset) (*bundleFS, error) {
 	path := ""
 	if asset.Path != nil {
 		path = *asset.Path
@@ -98,12 +86,16 @@ func newBundleFS(ctx context.Context, parentFS LibFS, asset entities.Asset) (Lib
 		return nil, err
 	}
 
-	bundlePath, err := prepareBundlePath(ctx, parentFS, path, base)
+	locator := asset.Locator
+	if locator == "" {
+		locator = entities.LocatorForPath(parentFS.GetName(), parentFS.GetRoot(), path)
+	}
+	bundlePath, err := prepareBundlePath(ctx, parentFS, path, locator)
 	if err != nil {
 		return nil, err
 	}
 
-	return openBundleFileSystem(ctx, bundlePath, parentFS, base, asset.ID)
+	return openBundleFileSystem(ctx, bundlePath, parentFS, path, asset.ID)
 }
 
 func validateBundleExtension(filename string) error {
@@ -116,8 +108,11 @@ func validateBundleExtension(filename string) error {
 	}
 }
 
-func prepareBundlePath(ctx context.Context, parentFS LibFS, path, base string) (string, error) {
-	if parentFS.Kind() == "local" {
+// prepareBundlePath returns where the archive can be read locally. Archives of
+// other filesystems are copied to the cache, named after the locator of their
+// asset as nested bundles can share a name and path.
+func prepareBundlePath(ctx context.Context, parentFS LibFS, path, locator string) (string, error) {
+	if parentFS.Kind() == "local" || parentFS.Kind() == "git" {
 		return filepath.Join(parentFS.GetLocation(), path), nil
 	}
 
@@ -126,10 +121,7 @@ func prepareBundlePath(ctx context.Context, parentFS LibFS, path, base string) (
 	if err != nil {
 		return "", err
 	}
-
-	// Create cache key based on filesystem name and path
-	cacheKey := filepath.Join(parentFS.GetName(), filepath.Dir(path), base)
-	cachePath := filepath.Join(cacheFS.GetLocation(), cacheKey)
+	cachePath := filepath.Join(cacheFS.GetLocation(), "bundles", locator+filepath.Ext(path))
 
 	// Ensure cache directory exists
 	cacheDir := filepath.Dir(cachePath)
@@ -137,20 +129,29 @@ func prepareBundlePath(ctx context.Context, parentFS LibFS, path, base string) (
 		return "", err
 	}
 
-	// Check if already exists in cache
-	if _, err := os.Stat(cachePath); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			if err := cacheFile(parentFS, path, cachePath); err != nil {
-				return "", err
-			}
-		} else {
+	// Check if already exists in cache and is still current
+	cached, err := os.Stat(cachePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err == nil {
+		src, err := fs.Stat(parentFS.GetFS(), path)
+		if err != nil {
 			return "", err
 		}
+		if !src.ModTime().After(cached.ModTime()) && src.Size() == cached.Size() {
+			return cachePath, nil
+		}
 	}
 
+	if err := cacheFile(parentFS, path, cachePath); err != nil {
+		return "", err
+	}
 	return cachePath, nil
 }
 
+// cacheFile copies a file next to destPath and renames it over, bundles that
+// have the previous copy open keep reading it whole.
 func cacheFile(parentFS LibFS, srcPath string, destPath string) error {
 	reader, err := parentFS.Open(srcPath)
 	if err != nil {
@@ -158,25 +159,40 @@ func cacheFile(parentFS LibFS, srcPath string, destPath string) error {
 	}
 	defer reader.Close()
 
-	writer, err := os.Create(destPath)
+	writer, err := os.CreateTemp(filepath.Dir(destPath), ".mmp-bundle-*")
 	if err != nil {
 		return err
 	}
-	defer writer.Close()
+	defer os.Remove(writer.Name())
 
-	_, err = io.Copy(writer, reader)
-	return err
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return os.Rename(writer.Name(), destPath)
 }
 
-func openBundleFileSystem(ctx context.Context, bundlePath string, parentFS LibFS, name, rootID string) (LibFS, error) {
+func openBundleFileSystem(ctx context.Context, bundlePath string, parentFS LibFS, path, rootID string) (*bundleFS, error) {
+	// The archive reads from the file for as long as the filesystem is used,
+	// Close closes it
 	file, err := os.Open(bundlePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	bfs, err := archiver.FileSystem(ctx, bundlePath, file)
+	var bfs fs.FS
+	switch filepath.Ext(bundlePath) {
+	case ".zip", ".3mf":
+		// archiver can't open entries of zip archives, archive/zip is an fs.FS already
+		bfs, err = openZip(file)
+	default:
+		bfs, err = archiver.FileSystem(ctx, bundlePath, file)
+	}
 	if err != nil {
+		file.Close()
 		return nil, err
 	}
 
@@ -184,11 +200,48 @@ func openBundleFileSystem(ctx context.Context, bundlePath string, parentFS LibFS
 		fs:             bfs,
 		parentFS:       parentFS,
 		bundleLocation: bundlePath,
-		name:           name,
+		path:           path,
+		name:           filepath.Base(path),
 		root:           rootID,
+		files:          []*os.File{file},
 	}, nil
 }
 
+// Close releases the archive, and the bundles opened to reach it.
+func (bfs *bundleFS) Close() error {
+	bfs.mu.Lock()
+	files := bfs.files
+	bfs.files = nil
+	bfs.mu.Unlock()
+
+	var errs []error
+	for _, f := range files {
+		errs = append(errs, f.Close())
+	}
+	if bfs.owned != nil {
+		errs = append(errs, Close(bfs.owned))
+	}
+	return errors.Join(errs...)
+}
+
+// closingFile releases the filesystem it was opened from when closed.
+type closingFile struct {
+	fs.File
+	f LibFS
+}
+
+func (f *closingFile) Close() error {
+	return errors.Join(f.File.Close(), Close(f.f))
+}
+
+func openZip(file *os.File) (fs.FS, error) {
+	st, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(file, st.Size())
+}
+
 func (fs *bundleFS) GetFS() fs.FS {
 	return fs.fs
 }
@@ -213,20 +266,36 @@ func (fs *bundleFS) Open(name string) (fs.File, error) {
 	return fs.fs.Open(name)
 }
 
+// ReadDir and Stat go through the archive, opening the root of an archive as
+// a file isn't supported.
+func (bfs *bundleFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(bfs.fs, name)
+}
+
+func (bfs *bundleFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(bfs.fs, name)
+}
+
+// Writable reports whether the archive can be rewritten, only zip based
+// bundles in a writable filesystem can.
 func (fs *bundleFS) Writable() bool {
-	return false
+	return isZipBundle(fs.name) && fs.parentFS.Writable()
 }
 
 func (fs *bundleFS) Create(name string) (io.WriteCloser, error) {
-	return nil, errors.New("write not supported on bundle filesystem")
+	return fs.createEntry(name)
 }
 
 func (fs *bundleFS) Mkdir(name string) error {
-	return errors.New("mkdir not supported on bundle filesystem")
+	return fs.mkdirEntry(name)
 }
 
 func (fs *bundleFS) Remove(name string) error {
-	return errors.New("remove not supported on bundle filesystem")
+	return fs.removeEntry(name)
+}
+
+func (fs *bundleFS) Rename(oldName, newName string) error {
+	return fs.renameEntry(oldName, newName)
 }
 
 func (fs *bundleFS) IsBundle(path string) bool {
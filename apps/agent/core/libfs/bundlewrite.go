@@ -0,0 +1,280 @@
+package libfs
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rewrites of the same archive have to wait for each other
+var bundleLocks sync.Map
+
+// BundleContainer returns the filesystem holding the archive of a bundle
+// filesystem and the archive's path in it.
+func BundleContainer(f LibFS) (LibFS, string, bool) {
+	bfs, ok := f.(*bundleFS)
+	if !ok {
+		return nil, "", false
+	}
+	return bfs.parentFS, bfs.path, true
+}
+
+func isZipBundle(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".zip" || ext == ".3mf"
+}
+
+// zipRewrite copies an archive into a temporary file, leaving out or renaming
+// entries on the way, so entries can be added before it's swapped in.
+type zipRewrite struct {
+	bfs    *bundleFS
+	temp   *os.File
+	zw     *zip.Writer
+	unlock func()
+}
+
+// rewrite starts a copy of the archive, rename returns the name an entry is
+// kept under or false to drop it.
+func (bfs *bundleFS) rewrite(rename func(name string) (string, bool)) (*zipRewrite, error) {
+	if !bfs.Writable() {
+		return nil, errors.New("write not supported on " + filepath.Ext(bfs.name) + " bundle filesystem")
+	}
+
+	l, _ := bundleLocks.LoadOrStore(bfs.bundleLocation, &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+	mu.Lock()
+
+	rw, err := bfs.copyArchive(rename)
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	rw.unlock = mu.Unlock
+	return rw, nil
+}
+
+func (bfs *bundleFS) copyArchive(rename func(name string) (string, bool)) (*zipRewrite, error) {
+	src, err := os.Open(bfs.bundleLocation)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	st, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(src, st.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	// Next to the archive so the swap is a rename on the same device
+	temp, err := os.CreateTemp(filepath.Dir(bfs.bundleLocation), ".mmp-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	rw := &zipRewrite{bfs: bfs, temp: temp, zw: zip.NewWriter(temp)}
+	// CreateTemp makes it owner only, the archive keeps the mode it had
+	if err := temp.Chmod(st.Mode().Perm()); err != nil {
+		rw.abort()
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		name, keep := rename(f.Name)
+		if !keep {
+			continue
+		}
+		if name == f.Name {
+			err = rw.zw.Copy(f)
+		} else {
+			err = copyRenamed(rw.zw, f, name)
+		}
+		if err != nil {
+			rw.abort()
+			return nil, err
+		}
+	}
+	return rw, nil
+}
+
+func copyRenamed(zw *zip.Writer, f *zip.File, name string) error {
+	h := f.FileHeader
+	h.Name = name
+	w, err := zw.CreateRaw(&h)
+	if err != nil {
+		return err
+	}
+	r, err := f.OpenRaw()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (rw *zipRewrite) abort() {
+	rw.zw.Close()
+	rw.temp.Close()
+	os.Remove(rw.temp.Name())
+	if rw.unlock != nil {
+		rw.unlock()
+	}
+}
+
+// finish swaps the new archive in. Local archives are renamed over, others
+// are uploaded to their filesystem and the cached copy is replaced.
+func (rw *zipRewrite) finish() error {
+	defer rw.unlock()
+	defer os.Remove(rw.temp.Name())
+
+	if err := rw.zw.Close(); err != nil {
+		rw.temp.Close()
+		return err
+	}
+	if err := rw.temp.Sync(); err != nil {
+		rw.temp.Close()
+		return err
+	}
+	if err := rw.temp.Close(); err != nil {
+		return err
+	}
+
+	bfs := rw.bfs
+	switch parent := bfs.parentFS.(type) {
+	case *localFS:
+		if err := os.Rename(rw.temp.Name(), bfs.bundleLocation); err != nil {
+			return err
+		}
+	case *gitFS:
+		if err := os.Rename(rw.temp.Name(), bfs.bundleLocation); err != nil {
+			return err
+		}
+		if err := parent.commit(commitMessage("Update", bfs.path), bfs.path); err != nil {
+			return err
+		}
+	default:
+		temp := rw.temp.Name()
+		if _, err := CopyFile(os.DirFS(filepath.Dir(temp)), parent, filepath.Base(temp), bfs.path); err != nil {
+			return err
+		}
+		if err := os.Rename(rw.temp.Name(), bfs.bundleLocation); err != nil {
+			return err
+		}
+	}
+	return bfs.reopen()
+}
+
+// reopen reads the swapped in archive, the previous one stays readable by
+// whoever still holds it until the filesystem is closed.
+func (bfs *bundleFS) reopen() error {
+	file, err := os.Open(bfs.bundleLocation)
+	if err != nil {
+		return err
+	}
+	zfs, err := openZip(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	bfs.mu.Lock()
+	bfs.files = append(bfs.files, file)
+	bfs.mu.Unlock()
+	bfs.fs = zfs
+	return nil
+}
+
+// bundleWriter writes a new entry and swaps the archive in on Close.
+type bundleWriter struct {
+	io.Writer
+	rw *zipRewrite
+}
+
+func (w *bundleWriter) Close() error {
+	return w.rw.finish()
+}
+
+func zipName(name string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+}
+
+func under(entry, name string) bool {
+	entry = strings.TrimSuffix(entry, "/")
+	return entry == name || strings.HasPrefix(entry, name+"/")
+}
+
+func (bfs *bundleFS) createEntry(name string) (io.WriteCloser, error) {
+	name = zipName(name)
+	rw, err := bfs.rewrite(func(n string) (string, bool) {
+		return n, strings.TrimSuffix(n, "/") != name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	h.Modified = time.Now()
+	w, err := rw.zw.CreateHeader(h)
+	if err != nil {
+		rw.abort()
+		return nil, err
+	}
+	return &bundleWriter{Writer: w, rw: rw}, nil
+}
+
+func (bfs *bundleFS) removeEntry(name string) error {
+	name = zipName(name)
+	if _, err := fs.Stat(bfs.fs, name); err != nil {
+		return err
+	}
+	rw, err := bfs.rewrite(func(n string) (string, bool) {
+		return n, !under(n, name)
+	})
+	if err != nil {
+		return err
+	}
+	return rw.finish()
+}
+
+func (bfs *bundleFS) mkdirEntry(name string) error {
+	name = zipName(name)
+	if _, err := fs.Stat(bfs.fs, name); err == nil {
+		return nil
+	}
+	rw, err := bfs.rewrite(func(n string) (string, bool) { return n, true })
+	if err != nil {
+		return err
+	}
+	h := &zip.FileHeader{Name: name + "/"}
+	h.Modified = time.Now()
+	h.SetMode(fs.ModeDir | 0755)
+	if _, err := rw.zw.CreateHeader(h); err != nil {
+		rw.abort()
+		return err
+	}
+	return rw.finish()
+}
+
+func (bfs *bundleFS) renameEntry(oldName, newName string) error {
+	oldName, newName = zipName(oldName), zipName(newName)
+	if _, err := fs.Stat(bfs.fs, oldName); err != nil {
+		return err
+	}
+	rw, err := bfs.rewrite(func(n string) (string, bool) {
+		if !under(n, oldName) {
+			return n, !under(n, newName)
+		}
+		return newName + strings.TrimPrefix(n, oldName), true
+	})
+	if err != nil {
+		return err
+	}
+	return rw.finish()
+}
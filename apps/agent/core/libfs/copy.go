@@ -0,0 +1,68 @@
+package libfs
+
+import (
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+// treeWriter is implemented by filesystems that record every write as a
+// change of its own, writeTree lets a whole tree go in as one.
+type treeWriter interface {
+	writeTree(name string, write func(dst LibFS) error) error
+}
+
+// CopyTree copies a file or a directory with everything in it into a
+// filesystem and returns the number of bytes copied.
+func CopyTree(src fs.FS, from string, dst LibFS, to string) (int64, error) {
+	var size int64
+	write := func(dst LibFS) error {
+		return fs.WalkDir(src, from, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(from, p)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(to, rel)
+			if d.IsDir() {
+				return dst.Mkdir(target)
+			}
+
+			n, err := CopyFile(src, dst, p, target)
+			size += n
+			return err
+		})
+	}
+
+	if tw, ok := dst.(treeWriter); ok {
+		return size, tw.writeTree(to, write)
+	}
+	return size, write(dst)
+}
+
+// CopyFile copies a single file into a filesystem and returns its size.
+func CopyFile(src fs.FS, dst LibFS, from, to string) (int64, error) {
+	r, err := src.Open(from)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return WriteFile(dst, to, r)
+}
+
+// WriteFile creates a file of a filesystem with what's read from r, the file
+// is only complete once it's closed so errors of Close count.
+func WriteFile(dst LibFS, name string, r io.Reader) (int64, error) {
+	w, err := dst.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
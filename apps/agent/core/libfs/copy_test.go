@@ -0,0 +1,56 @@
+package libfs
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCopyTreeCommitsOnce(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(dir, mainBranch(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := openTestGitFS(t, dir, "")
+	src := fstest.MapFS{
+		"model/a.stl":         {Data: []byte("solid a")},
+		"model/parts/b.stl":   {Data: []byte("solid b")},
+		"model/parts/c.3mf":   {Data: []byte("c")},
+		"model/readme.md":     {Data: []byte("# model")},
+		"elsewhere/other.stl": {Data: []byte("not copied")},
+	}
+
+	size, err := CopyTree(src, "model", dst, "copied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 22 {
+		t.Errorf("copied %d bytes, want 22", size)
+	}
+	if got := readString(t, dst, "copied/parts/b.stl"); got != "solid b" {
+		t.Errorf("copied/parts/b.stl = %q", got)
+	}
+
+	log, err := repo.Log(&git.LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits := 0
+	log.ForEach(func(*object.Commit) error {
+		commits++
+		return nil
+	})
+	if commits != 1 {
+		t.Errorf("copy made %d commits, want 1", commits)
+	}
+	status, err := dst.worktree.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.IsClean() {
+		t.Errorf("copied files left uncommitted:\n%s", status)
+	}
+}
@@ -2,13 +2,25 @@ package libfs
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/eduardooliveira/stLib/core/runtime"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	defaultGitAuthorName  = "MMP"
+	defaultGitAuthorEmail = "mmp@localhost"
 )
 
 type gitFS struct {
@@ -17,6 +29,9 @@ type gitFS struct {
 	worktree     *git.Worktree
 	rootPath     string
 	discovarable bool
+	// mu serializes changes to the index, the commits and syncs
+	mu   sync.Mutex
+	sync SyncStatus
 }
 
 func newGitFS(cfg runtime.FileSystem) (LibFS, error) {
@@ -80,22 +95,250 @@ func (fs *gitFS) Open(name string) (fs.File, error) {
 	return os.Open(fullPath)
 }
 
+// Writable reports whether the agent may commit to the repository, it has to
+// be enabled with the writable key as git filesystems used to be read only.
 func (fs *gitFS) Writable() bool {
-	return false
+	return configBool(fs.config, "writable", false)
 }
 
+// Create writes the file in the worktree and commits it on Close.
 func (fs *gitFS) Create(name string) (io.WriteCloser, error) {
-	return nil, errors.New("write not supported on git filesystem")
+	if !fs.Writable() {
+		return nil, errors.New("write not supported on read only git filesystem")
+	}
+
+	verb := "Update"
+	if _, err := os.Stat(filepath.Join(fs.rootPath, name)); os.IsNotExist(err) {
+		verb = "Add"
+	}
+	f, err := fs.create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &gitWriter{File: f, commit: func() error {
+		return fs.commit(commitMessage(verb, name), name)
+	}}, nil
+}
+
+// create writes a file in the worktree, leaving the commit to the caller.
+func (fs *gitFS) create(name string) (*os.File, error) {
+	fullPath := filepath.Join(fs.rootPath, name)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(fullPath)
+}
+
+// writeTree lets write fill the worktree and commits what it wrote under name
+// at once, instead of a commit per file.
+func (fs *gitFS) writeTree(name string, write func(dst LibFS) error) error {
+	if !fs.Writable() {
+		return errors.New("write not supported on read only git filesystem")
+	}
+	verb := "Update"
+	if _, err := os.Stat(filepath.Join(fs.rootPath, name)); os.IsNotExist(err) {
+		verb = "Add"
+	}
+	if err := write(&gitTree{gitFS: fs}); err != nil {
+		return err
+	}
+	return fs.commit(commitMessage(verb, name), name)
 }
 
+// Mkdir only creates the directory, git doesn't track empty ones.
 func (fs *gitFS) Mkdir(name string) error {
-	return errors.New("mkdir not supported on git filesystem")
+	if !fs.Writable() {
+		return errors.New("mkdir not supported on read only git filesystem")
+	}
+	return os.MkdirAll(filepath.Join(fs.rootPath, name), 0755)
 }
 
 func (fs *gitFS) Remove(name string) error {
-	return errors.New("remove not supported on git filesystem")
+	if !fs.Writable() {
+		return errors.New("remove not supported on read only git filesystem")
+	}
+	if err := os.RemoveAll(filepath.Join(fs.rootPath, name)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(fs.rootPath, MetadataPath(name))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return fs.commit(commitMessage("Remove", name), name, MetadataPath(name))
+}
+
+func (fs *gitFS) Rename(oldName, newName string) error {
+	if !fs.Writable() {
+		return errors.New("rename not supported on read only git filesystem")
+	}
+	newPath := filepath.Join(fs.rootPath, newName)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(fs.rootPath, oldName), newPath); err != nil {
+		return err
+	}
+	// The sidecar follows its file
+	oldMeta := filepath.Join(fs.rootPath, MetadataPath(oldName))
+	if _, err := os.Stat(oldMeta); err == nil {
+		if err := os.Rename(oldMeta, filepath.Join(fs.rootPath, MetadataPath(newName))); err != nil {
+			return err
+		}
+	}
+	return fs.commit(fmt.Sprintf("Move %s to %s", oldName, newName), oldName, newName, MetadataPath(oldName), MetadataPath(newName))
 }
 
 func (fs *gitFS) IsBundle(path string) bool {
 	return IsBundle(path)
 }
+
+// History lists the commits that touched name, its sidecar or anything under it.
+func (fs *gitFS) History(name string) ([]Revision, error) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	meta := MetadataPath(name)
+	iter, err := fs.repo.Log(&git.LogOptions{
+		PathFilter: func(p string) bool {
+			return name == "." || p == name || p == meta || strings.HasPrefix(p, name+"/")
+		},
+	})
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return []Revision{}, nil
+		}
+		return nil, err
+	}
+	defer iter.Close()
+
+	rtn := make([]Revision, 0)
+	err = iter.ForEach(func(c *object.Commit) error {
+		rtn = append(rtn, Revision{
+			Hash:    c.Hash.String(),
+			Author:  c.Author.Name,
+			Email:   c.Author.Email,
+			Message: strings.TrimSpace(c.Message),
+			Time:    c.Author.When,
+		})
+		return nil
+	})
+	return rtn, err
+}
+
+// OpenRevision opens name as it was at rev, dated with the commit.
+func (fs *gitFS) OpenRevision(name, rev string) (fs.File, error) {
+	hash, err := fs.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("%w: revision %s", os.ErrNotExist, rev)
+	}
+	commit, err := fs.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	f, err := commit.File(filepath.ToSlash(filepath.Clean(name)))
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return nil, fmt.Errorf("%w: %s at %s", os.ErrNotExist, name, rev)
+		}
+		return nil, err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	return &revisionFile{ReadCloser: r, info: &remoteFileInfo{name: f.Name, size: f.Size, modTime: commit.Committer.When}}, nil
+}
+
+// commit stages the given paths, whether they changed or are gone, and commits
+// them with the configured author.
+func (fs *gitFS) commit(message string, paths ...string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, p := range paths {
+		if err := fs.stage(filepath.ToSlash(filepath.Clean(p))); err != nil {
+			return err
+		}
+	}
+
+	_, err := fs.worktree.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  fs.configOr("author_name", defaultGitAuthorName),
+			Email: fs.configOr("author_email", defaultGitAuthorEmail),
+			When:  time.Now(),
+		},
+	})
+	if errors.Is(err, git.ErrEmptyCommit) {
+		return nil
+	}
+	return err
+}
+
+func (fs *gitFS) stage(name string) error {
+	if _, err := os.Lstat(filepath.Join(fs.rootPath, name)); err == nil {
+		_, err := fs.worktree.Add(name)
+		return err
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Gone from the worktree, drop it and anything under it from the index
+	idx, err := fs.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	kept := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if e.Name != name && !strings.HasPrefix(e.Name, name+"/") {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(idx.Entries) {
+		return nil
+	}
+	idx.Entries = kept
+	return fs.repo.Storer.SetIndex(idx)
+}
+
+func (fs *gitFS) configOr(key, def string) string {
+	if v := configString(fs.config, key); v != "" {
+		return v
+	}
+	return def
+}
+
+func commitMessage(verb, name string) string {
+	if isSidecar(name) {
+		return fmt.Sprintf("Update metadata of %s", strings.TrimSuffix(name, MetadataSuffix))
+	}
+	return fmt.Sprintf("%s %s", verb, path.Clean(filepath.ToSlash(name)))
+}
+
+// gitWriter commits the file once it's completely written.
+type gitWriter struct {
+	*os.File
+	commit func() error
+}
+
+func (w *gitWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	return w.commit()
+}
+
+// gitTree is a git filesystem whose files are committed by writeTree.
+type gitTree struct {
+	*gitFS
+}
+
+func (t *gitTree) Create(name string) (io.WriteCloser, error) {
+	return t.create(name)
+}
+
+// revisionFile is the content of a file at a commit.
+type revisionFile struct {
+	io.ReadCloser
+	info *remoteFileInfo
+}
+
+func (f *revisionFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
@@ -0,0 +1,309 @@
+package libfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	SyncStateSynced   = "synced"
+	SyncStateDiverged = "diverged"
+	SyncStateConflict = "conflict"
+	SyncStateError    = "error"
+)
+
+const defaultSyncInterval = 5 * time.Minute
+
+// Syncer is implemented by filesystems that follow a remote copy of the library.
+type Syncer interface {
+	// SyncInterval is how often Sync should run, zero when not syncing at all.
+	SyncInterval() time.Duration
+	// Sync brings the filesystem and the remote up to date and returns the
+	// paths it changed locally.
+	Sync(ctx context.Context) ([]Change, error)
+	SyncStatus() SyncStatus
+}
+
+type SyncStatus struct {
+	FS       string    `json:"fs"`
+	Remote   string    `json:"remote"`
+	Branch   string    `json:"branch"`
+	State    string    `json:"state,omitempty"`
+	Ahead    int       `json:"ahead"`
+	Behind   int       `json:"behind"`
+	Head     string    `json:"head,omitempty"`
+	LastSync time.Time `json:"last_sync,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// ErrSyncDiverged is returned when local and remote both have commits the
+// other lacks, they have to be merged by hand.
+var ErrSyncDiverged = errors.New("local and remote history diverged")
+
+// ErrSyncBranch is returned when the worktree isn't on the configured branch,
+// syncing would reset or push a branch the files don't come from.
+var ErrSyncBranch = errors.New("worktree is not on the synced branch")
+
+func (fs *gitFS) SyncInterval() time.Duration {
+	if configString(fs.config, "remote") == "" {
+		return 0
+	}
+	switch v := fs.config.Config["sync_interval"].(type) {
+	case int:
+		return time.Duration(v) * time.Second
+	case int64:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultSyncInterval
+}
+
+func (fs *gitFS) SyncStatus() SyncStatus {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	s := fs.sync
+	s.FS = fs.GetName()
+	s.Remote = configString(fs.config, "remote")
+	s.Branch = fs.branch()
+	return s
+}
+
+// Sync fetches the branch, fast-forwards the worktree when only the remote
+// moved and pushes when only local commits are missing on the remote.
+func (fs *gitFS) Sync(ctx context.Context) ([]Change, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	changes, err := fs.doSync(ctx)
+	fs.sync.LastSync = time.Now()
+	fs.sync.Error = ""
+	switch {
+	case err == nil:
+		fs.sync.State = SyncStateSynced
+	case errors.Is(err, ErrSyncDiverged):
+		fs.sync.State = SyncStateDiverged
+	case errors.Is(err, git.ErrUnstagedChanges):
+		fs.sync.State = SyncStateConflict
+	default:
+		fs.sync.State = SyncStateError
+	}
+	if err != nil {
+		fs.sync.Error = err.Error()
+	}
+	return changes, err
+}
+
+func (fs *gitFS) doSync(ctx context.Context) ([]Change, error) {
+	remote, err := fs.remote()
+	if err != nil {
+		return nil, err
+	}
+	branch := fs.branch()
+	localRef := plumbing.NewBranchReferenceName(branch)
+	trackingRef := plumbing.NewRemoteReferenceName(remote.Config().Name, branch)
+
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remote.Config().Name,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", localRef, trackingRef))},
+		Auth:       fs.auth(),
+	})
+	remoteMissing := errors.Is(err, transport.ErrEmptyRemoteRepository) || errors.Is(err, git.NoMatchingRefSpecError{})
+	if err != nil && !remoteMissing && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+
+	head, err := fs.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if head.Name() != localRef {
+		return nil, fmt.Errorf("%w: %s is checked out instead of %s", ErrSyncBranch, head.Name().Short(), branch)
+	}
+	fs.sync.Head = head.Hash().String()
+	local, err := fs.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	if remoteMissing {
+		fs.sync.Ahead, fs.sync.Behind = 0, 0
+		return nil, fs.push(ctx, remote, localRef)
+	}
+
+	tracking, err := fs.repo.Reference(trackingRef, true)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := fs.repo.CommitObject(tracking.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	ahead, behind, err := divergence(local, upstream)
+	if err != nil {
+		return nil, err
+	}
+	fs.sync.Ahead, fs.sync.Behind = ahead, behind
+
+	switch {
+	case ahead > 0 && behind > 0:
+		return nil, fmt.Errorf("%w: %d local and %d remote commits", ErrSyncDiverged, ahead, behind)
+	case ahead > 0:
+		if err := fs.push(ctx, remote, localRef); err != nil {
+			return nil, err
+		}
+		fs.sync.Ahead = 0
+		return nil, nil
+	case behind > 0:
+		changes, err := changedPaths(local, upstream)
+		if err != nil {
+			return nil, err
+		}
+		if err := fs.worktree.Reset(&git.ResetOptions{Commit: upstream.Hash, Mode: git.MergeReset}); err != nil {
+			return nil, fmt.Errorf("fast-forward failed: %w", err)
+		}
+		fs.sync.Behind = 0
+		fs.sync.Head = upstream.Hash.String()
+		return changes, nil
+	}
+	return nil, nil
+}
+
+func (fs *gitFS) push(ctx context.Context, remote *git.Remote, ref plumbing.ReferenceName) error {
+	err := remote.PushContext(ctx, &git.PushOptions{
+		RemoteName: remote.Config().Name,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", ref, ref))},
+		Auth:       fs.auth(),
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("push failed: %w", err)
+	}
+	return nil
+}
+
+// remote returns the configured remote, either the name of one of the
+// repository's remotes or an URL used without touching the repository config.
+func (fs *gitFS) remote() (*git.Remote, error) {
+	name := configString(fs.config, "remote")
+	if r, err := fs.repo.Remote(name); err == nil {
+		return r, nil
+	} else if !errors.Is(err, git.ErrRemoteNotFound) {
+		return nil, err
+	}
+
+	cfg := &config.RemoteConfig{Name: "mmp", URLs: []string{name}}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return git.NewRemote(fs.repo.Storer, cfg), nil
+}
+
+// branch is the configured branch, or the one checked out.
+func (fs *gitFS) branch() string {
+	if b := configString(fs.config, "branch"); b != "" {
+		return b
+	}
+	if head, err := fs.repo.Head(); err == nil && head.Name().IsBranch() {
+		return head.Name().Short()
+	}
+	return "main"
+}
+
+func (fs *gitFS) auth() transport.AuthMethod {
+	user, password := configString(fs.config, "username"), configString(fs.config, "password")
+	if user == "" && password == "" {
+		return nil
+	}
+	return &http.BasicAuth{Username: user, Password: password}
+}
+
+// divergence counts the commits each side has that the other lacks.
+func divergence(local, upstream *object.Commit) (int, int, error) {
+	if local.Hash == upstream.Hash {
+		return 0, 0, nil
+	}
+	bases, err := local.MergeBase(upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+	var base plumbing.Hash
+	if len(bases) > 0 {
+		base = bases[0].Hash
+	}
+
+	ahead, err := countUntil(local, base)
+	if err != nil {
+		return 0, 0, err
+	}
+	behind, err := countUntil(upstream, base)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+func countUntil(from *object.Commit, base plumbing.Hash) (int, error) {
+	count := 0
+	iter := object.NewCommitPreorderIter(from, nil, []plumbing.Hash{base})
+	err := iter.ForEach(func(c *object.Commit) error {
+		if c.Hash == base {
+			return nil
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// changedPaths lists what the fast-forward from one commit to the other
+// changes in the worktree, as discovery changes.
+func changedPaths(from, to *object.Commit) ([]Change, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	rtn := make([]Change, 0, len(diff))
+	for _, c := range diff {
+		if isSidecar(c.From.Name) || isSidecar(c.To.Name) {
+			continue
+		}
+		switch {
+		case c.From.Name == "":
+			rtn = append(rtn, Change{Op: ChangeCreate, Path: c.To.Name})
+		case c.To.Name == "":
+			rtn = append(rtn, Change{Op: ChangeRemove, Path: c.From.Name})
+		default:
+			rtn = append(rtn, Change{Op: ChangeWrite, Path: c.To.Name})
+		}
+	}
+	return rtn, nil
+}
+
+// Sidecars are versioned with their files but aren't assets of their own
+func isSidecar(name string) bool {
+	return strings.HasSuffix(name, MetadataSuffix)
+}
@@ -0,0 +1,222 @@
+package libfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// newTestRemote creates the bare repository both sides of a sync share.
+func newTestRemote(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := git.PlainInitWithOptions(dir, mainBranch(true)); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func mainBranch(bare bool) *git.PlainInitOptions {
+	return &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+		Bare:        bare,
+	}
+}
+
+func openTestGitFS(t *testing.T, dir, remote string) *gitFS {
+	t.Helper()
+	f, err := newGitFS(runtime.FileSystem{Name: "git", Kind: "git", Path: dir, Config: map[string]any{
+		"remote":   remote,
+		"branch":   "main",
+		"writable": true,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.(*gitFS)
+}
+
+// newTestGitFS starts a library with one commit and publishes it on the remote.
+func newTestGitFS(t *testing.T, remote string) *gitFS {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := git.PlainInitWithOptions(dir, mainBranch(false)); err != nil {
+		t.Fatal(err)
+	}
+	f := openTestGitFS(t, dir, remote)
+	writeGitFile(t, f, "a.stl", "solid a")
+	if _, err := f.Sync(context.Background()); err != nil {
+		t.Fatalf("initial sync: %v", err)
+	}
+	return f
+}
+
+// cloneTestGitFS is another copy of the library following the same remote.
+func cloneTestGitFS(t *testing.T, remote string) *gitFS {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := git.PlainClone(dir, false, &git.CloneOptions{URL: remote}); err != nil {
+		t.Fatal(err)
+	}
+	return openTestGitFS(t, dir, "origin")
+}
+
+func writeGitFile(t *testing.T, f *gitFS, name, content string) {
+	t.Helper()
+	w, err := f.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func headHash(t *testing.T, repo *git.Repository) plumbing.Hash {
+	t.Helper()
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return head.Hash()
+}
+
+func remoteHash(t *testing.T, remote string) plumbing.Hash {
+	t.Helper()
+	repo, err := git.PlainOpen(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName("main"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref.Hash()
+}
+
+func TestGitSyncPushesWhenAhead(t *testing.T) {
+	remote := newTestRemote(t)
+	f := newTestGitFS(t, remote)
+	if got, want := remoteHash(t, remote), headHash(t, f.repo); got != want {
+		t.Fatalf("remote is at %s after publishing, want %s", got, want)
+	}
+
+	writeGitFile(t, f, "b.stl", "solid b")
+	changes, err := f.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("push changed local paths: %v", changes)
+	}
+	if got, want := remoteHash(t, remote), headHash(t, f.repo); got != want {
+		t.Errorf("remote is at %s, want %s", got, want)
+	}
+	if s := f.SyncStatus(); s.State != SyncStateSynced || s.Ahead != 0 || s.Behind != 0 {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func TestGitSyncFastForwards(t *testing.T) {
+	remote := newTestRemote(t)
+	a := newTestGitFS(t, remote)
+	writeGitFile(t, a, "gone.stl", "solid gone")
+	if _, err := a.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b := cloneTestGitFS(t, remote)
+
+	writeGitFile(t, a, "a.stl", "solid a2")
+	writeGitFile(t, a, "dir/new.stl", "solid new")
+	writeGitFile(t, a, MetadataPath("dir/new.stl"), "label: new")
+	if err := a.Remove("gone.stl"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := b.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(changes, func(x, y Change) int { return strings.Compare(x.Path, y.Path) })
+	want := []Change{
+		{Op: ChangeWrite, Path: "a.stl"},
+		{Op: ChangeCreate, Path: "dir/new.stl"},
+		{Op: ChangeRemove, Path: "gone.stl"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+
+	if got, want := headHash(t, b.repo), headHash(t, a.repo); got != want {
+		t.Errorf("fast-forwarded to %s, want %s", got, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(b.rootPath, "a.stl")); err != nil || string(data) != "solid a2" {
+		t.Errorf("a.stl = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(b.rootPath, "gone.stl")); !os.IsNotExist(err) {
+		t.Errorf("removed file still in the worktree: %v", err)
+	}
+	if s := b.SyncStatus(); s.State != SyncStateSynced || s.Behind != 0 || s.Head != headHash(t, a.repo).String() {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func TestGitSyncDiverged(t *testing.T) {
+	remote := newTestRemote(t)
+	a := newTestGitFS(t, remote)
+	b := cloneTestGitFS(t, remote)
+
+	writeGitFile(t, a, "a.stl", "solid from a")
+	if _, err := a.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	writeGitFile(t, b, "b.stl", "solid from b")
+	before := headHash(t, b.repo)
+
+	_, err := b.Sync(context.Background())
+	if !errors.Is(err, ErrSyncDiverged) {
+		t.Fatalf("sync error = %v, want diverged", err)
+	}
+	if s := b.SyncStatus(); s.State != SyncStateDiverged || s.Ahead != 1 || s.Behind != 1 {
+		t.Errorf("status = %+v", s)
+	}
+	if got := headHash(t, b.repo); got != before {
+		t.Errorf("diverged sync moved head to %s", got)
+	}
+	if got, want := remoteHash(t, remote), headHash(t, a.repo); got != want {
+		t.Errorf("diverged sync pushed, remote is at %s, want %s", got, want)
+	}
+}
+
+func TestGitSyncOtherBranch(t *testing.T) {
+	remote := newTestRemote(t)
+	f := newTestGitFS(t, remote)
+	err := f.worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("wip"), Create: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeGitFile(t, f, "wip.stl", "solid wip")
+	published := remoteHash(t, remote)
+
+	if _, err := f.Sync(context.Background()); !errors.Is(err, ErrSyncBranch) {
+		t.Fatalf("sync error = %v, want wrong branch", err)
+	}
+	if got := remoteHash(t, remote); got != published {
+		t.Errorf("remote moved to %s", got)
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/eduardooliveira/stLib/core/entities"
 	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/utils"
 )
 
 type LibFS interface {
@@ -24,6 +28,7 @@ type LibFS interface {
 	Create(name string) (io.WriteCloser, error)
 	Mkdir(name string) error
 	Remove(name string) error
+	Rename(oldName, newName string) error
 	IsBundle(path string) bool
 	IsDiscovarable() bool
 	SetDiscovarable(bool)
@@ -61,6 +66,16 @@ func LoadFSs() error {
 				if err != nil {
 					return err
 				}
+			case "s3":
+				fs, err = newS3FS(cfgFS)
+				if err != nil {
+					return err
+				}
+			case "webdav":
+				fs, err = newWebDAVFS(cfgFS)
+				if err != nil {
+					return err
+				}
 			default:
 				return errors.New("unsupported filesystem kind: " + cfgFS.Kind)
 			}
@@ -120,16 +135,15 @@ func GetDefaultFS() LibFS {
 	return fileSystems[defaultFSName]
 }
 
+// GetAssetFS returns the filesystem holding the asset's file, Close releases
+// it. Assets inside bundles need their parent chain loaded up to the bundle
+// they belong to.
 func GetAssetFS(ctx context.Context, asset entities.Asset) (LibFS, error) {
 	if asset.FSKind == "bundle" {
 		return resolveBundleFS(ctx, asset)
 	}
 
-	if f, ok := fileSystems[asset.FSName]; ok {
-		return f, nil
-	}
-
-	return nil, errors.New("file system not found")
+	return getFileSystemByName(asset.FSName)
 }
 
 func GetLibFS(name string) (LibFS, error) {
@@ -153,8 +167,73 @@ func IsBundle(path string) bool {
 	return slices.Contains(bundleExts, filepath.Ext(path))
 }
 
+// GetBundleFS opens the contents of a bundle living in parentFS, Close
+// releases it. parentFS stays open.
 func GetBundleFS(ctx context.Context, parentFS LibFS, asset entities.Asset) (LibFS, error) {
-	return newBundleFS(ctx, parentFS, asset)
+	f, err := newBundleFS(ctx, parentFS, asset)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenBundleFS opens the contents of a bundle asset, Close releases them along
+// with the bundles opened to reach it.
+func OpenBundleFS(ctx context.Context, asset entities.Asset) (LibFS, error) {
+	container, err := GetAssetFS(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newBundleFS(ctx, container, asset)
+	if err != nil {
+		Close(container)
+		return nil, err
+	}
+	f.owned = container
+	return f, nil
+}
+
+// OpenAsset opens the file of an asset, closing it releases the filesystem it
+// was opened from.
+func OpenAsset(ctx context.Context, asset entities.Asset) (fs.File, error) {
+	f, err := GetAssetFS(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+	file, err := f.Open(strings.TrimPrefix(path.Clean(utils.VoZ(asset.Path)), "/"))
+	if err != nil {
+		Close(f)
+		return nil, err
+	}
+	return &closingFile{File: file, f: f}, nil
+}
+
+// Close releases a filesystem GetAssetFS or GetBundleFS returned. Bundles
+// hold their archive open until then, the registered filesystems stay open.
+func Close(f LibFS) error {
+	if bfs, ok := f.(*bundleFS); ok {
+		return bfs.Close()
+	}
+	return nil
+}
+
+func configString(cfg runtime.FileSystem, key string) string {
+	if v, ok := cfg.Config[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func configBool(cfg runtime.FileSystem, key string, def bool) bool {
+	switch v := cfg.Config[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
 }
 
 func createFolder(path string) error {
@@ -23,6 +23,14 @@ func newLocalFS(cfg runtime.FileSystem) LibFS {
 	}
 }
 
+// NewLocalFS is a directory of the agent's own used like a library filesystem,
+// it's neither registered nor discovered.
+func NewLocalFS(name, path string) LibFS {
+	f := newLocalFS(runtime.FileSystem{Name: name, Path: path, Kind: "local"})
+	f.SetDiscovarable(false)
+	return f
+}
+
 func (fs *localFS) IsDiscovarable() bool {
 	return fs.discovarable
 }
@@ -76,6 +84,14 @@ func (fs *localFS) Remove(name string) error {
 	return os.RemoveAll(filepath.Join(fs.config.Path, name))
 }
 
+func (fs *localFS) Rename(oldName, newName string) error {
+	newPath := filepath.Join(fs.config.Path, newName)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(fs.config.Path, oldName), newPath)
+}
+
 func (fs *localFS) IsBundle(path string) bool {
 	return IsBundle(path)
 }
@@ -0,0 +1,195 @@
+package libfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// remoteBackend is what a network filesystem provides so remoteFS can expose
+// it as an fs.FS. Names are slash separated and relative to the filesystem root.
+type remoteBackend interface {
+	stat(ctx context.Context, name string) (fs.FileInfo, error)
+	readDir(ctx context.Context, name string) ([]fs.FileInfo, error)
+	open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// remoteFS adapts a remoteBackend to fs.FS. Files are only fetched on the first
+// Read, so walking and stat'ing a remote tree doesn't download anything.
+type remoteFS struct {
+	backend remoteBackend
+}
+
+func (r *remoteFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := r.backend.stat(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &remoteFile{backend: r.backend, name: name, info: info}, nil
+}
+
+func (r *remoteFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := r.backend.stat(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+func (r *remoteFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := r.backend.readDir(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return dirEntries(infos), nil
+}
+
+type remoteFile struct {
+	backend remoteBackend
+	name    string
+	info    fs.FileInfo
+	body    io.ReadCloser
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (f *remoteFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *remoteFile) Read(p []byte) (int, error) {
+	if f.info.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	}
+	if f.body == nil {
+		body, err := f.backend.open(context.Background(), f.name)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = body
+	}
+	return f.body.Read(p)
+}
+
+func (f *remoteFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	if !f.read {
+		infos, err := f.backend.readDir(context.Background(), f.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+		}
+		f.entries = dirEntries(infos)
+		f.read = true
+	}
+
+	if n <= 0 {
+		rtn := f.entries
+		f.entries = nil
+		return rtn, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	rtn := f.entries[:n]
+	f.entries = f.entries[n:]
+	return rtn, nil
+}
+
+func (f *remoteFile) Close() error {
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+func dirEntries(infos []fs.FileInfo) []fs.DirEntry {
+	rtn := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		rtn = append(rtn, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(rtn, func(i, j int) bool { return rtn[i].Name() < rtn[j].Name() })
+	return rtn
+}
+
+// remoteFileInfo is the fs.FileInfo of a remote file or directory.
+type remoteFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *remoteFileInfo) Name() string {
+	return path.Base(i.name)
+}
+
+func (i *remoteFileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *remoteFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (i *remoteFileInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i *remoteFileInfo) IsDir() bool {
+	return i.dir
+}
+
+func (i *remoteFileInfo) Sys() any {
+	return nil
+}
+
+// pipeWriter streams what is written to an upload running in the background,
+// Close waits for the upload to finish and returns its error.
+type pipeWriter struct {
+	w    *io.PipeWriter
+	done chan error
+}
+
+func newPipeWriter(upload func(r io.Reader) error) io.WriteCloser {
+	r, w := io.Pipe()
+	pw := &pipeWriter{w: w, done: make(chan error, 1)}
+	go func() {
+		err := upload(r)
+		// Unblock the writer if the upload gave up early
+		r.CloseWithError(err)
+		pw.done <- err
+	}()
+	return pw
+}
+
+func (pw *pipeWriter) Write(p []byte) (int, error) {
+	return pw.w.Write(p)
+}
+
+func (pw *pipeWriter) Close() error {
+	if err := pw.w.Close(); err != nil {
+		return err
+	}
+	return <-pw.done
+}
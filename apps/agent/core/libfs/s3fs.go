@@ -0,0 +1,340 @@
+package libfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+// objectStore is the part of the S3 API the s3 filesystem relies on, keeping
+// it small allows running against an in-process fake instead of a bucket.
+type objectStore interface {
+	StatObject(ctx context.Context, key string) (objectInfo, error)
+	// ListObjects returns the objects under prefix, without recursion common
+	// prefixes are returned as well, with a trailing slash.
+	ListObjects(ctx context.Context, prefix string, recursive bool) ([]objectInfo, error)
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	// PutObject stores the object, size is -1 when not known upfront
+	PutObject(ctx context.Context, key string, r io.Reader, size int64) error
+	RemoveObject(ctx context.Context, key string) error
+	CopyObject(ctx context.Context, srcKey, dstKey string) error
+}
+
+type objectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+type s3FS struct {
+	config       runtime.FileSystem
+	store        objectStore
+	bucket       string
+	prefix       string
+	remote       *remoteFS
+	discovarable bool
+}
+
+func newS3FS(cfg runtime.FileSystem) (LibFS, error) {
+	endpoint := configString(cfg, "endpoint")
+	bucket := configString(cfg, "bucket")
+	if endpoint == "" || bucket == "" {
+		return nil, fmt.Errorf("s3 filesystem %s needs an endpoint and a bucket", cfg.Name)
+	}
+
+	opts := &minio.Options{
+		Creds:  credentials.NewStaticV4(configString(cfg, "access_key"), configString(cfg, "secret_key"), ""),
+		Secure: configBool(cfg, "use_ssl", true),
+		Region: configString(cfg, "region"),
+	}
+	if configBool(cfg, "path_style", false) {
+		opts.BucketLookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	return newS3FSWithStore(cfg, bucket, &minioStore{client: client, bucket: bucket}), nil
+}
+
+func newS3FSWithStore(cfg runtime.FileSystem, bucket string, store objectStore) *s3FS {
+	prefix := configString(cfg, "prefix")
+	if prefix == "" {
+		prefix = cfg.Path
+	}
+
+	s := &s3FS{
+		config:       cfg,
+		store:        store,
+		bucket:       bucket,
+		prefix:       strings.Trim(prefix, "/"),
+		discovarable: true,
+	}
+	s.remote = &remoteFS{backend: s}
+	return s
+}
+
+func (s *s3FS) IsDiscovarable() bool {
+	return s.discovarable
+}
+
+func (s *s3FS) SetDiscovarable(d bool) {
+	s.discovarable = d
+}
+
+func (s *s3FS) GetFS() fs.FS {
+	return s.remote
+}
+
+func (s *s3FS) GetName() string {
+	return s.config.Name
+}
+
+func (s *s3FS) GetLocation() string {
+	return "s3://" + path.Join(s.bucket, s.prefix)
+}
+
+func (s *s3FS) GetRoot() string {
+	return s.GetLocation()
+}
+
+func (s *s3FS) Kind() string {
+	return "s3"
+}
+
+func (s *s3FS) Open(name string) (fs.File, error) {
+	return s.remote.Open(name)
+}
+
+func (s *s3FS) Writable() bool {
+	return !configBool(s.config, "read_only", false)
+}
+
+func (s *s3FS) Create(name string) (io.WriteCloser, error) {
+	if !s.Writable() {
+		return nil, errors.New("write not supported on read only s3 filesystem")
+	}
+	key := s.key(name)
+	return newPipeWriter(func(r io.Reader) error {
+		return s.store.PutObject(context.Background(), key, r, -1)
+	}), nil
+}
+
+// Mkdir stores an empty marker object, S3 has no directories of its own and
+// prefixes without objects would vanish.
+func (s *s3FS) Mkdir(name string) error {
+	if !s.Writable() {
+		return errors.New("mkdir not supported on read only s3 filesystem")
+	}
+	return s.store.PutObject(context.Background(), s.key(name)+"/", strings.NewReader(""), 0)
+}
+
+// Remove deletes the object or everything under the prefix, like os.RemoveAll.
+func (s *s3FS) Remove(name string) error {
+	if !s.Writable() {
+		return errors.New("remove not supported on read only s3 filesystem")
+	}
+	ctx := context.Background()
+	key := s.key(name)
+
+	objects, err := s.store.ListObjects(ctx, key+"/", true)
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		if err := s.store.RemoveObject(ctx, o.Key); err != nil {
+			return err
+		}
+	}
+	if err := s.store.RemoveObject(ctx, key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Rename copies the object, or every object under the prefix, and removes the
+// originals. S3 has no rename of its own.
+func (s *s3FS) Rename(oldName, newName string) error {
+	if !s.Writable() {
+		return errors.New("rename not supported on read only s3 filesystem")
+	}
+	ctx := context.Background()
+	oldKey, newKey := s.key(oldName), s.key(newName)
+
+	if _, err := s.store.StatObject(ctx, oldKey); err == nil {
+		if err := s.store.CopyObject(ctx, oldKey, newKey); err != nil {
+			return err
+		}
+		return s.store.RemoveObject(ctx, oldKey)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	objects, err := s.store.ListObjects(ctx, oldKey+"/", true)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return fs.ErrNotExist
+	}
+	for _, o := range objects {
+		if err := s.store.CopyObject(ctx, o.Key, newKey+"/"+strings.TrimPrefix(o.Key, oldKey+"/")); err != nil {
+			return err
+		}
+	}
+	for _, o := range objects {
+		if err := s.store.RemoveObject(ctx, o.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *s3FS) IsBundle(path string) bool {
+	return IsBundle(path)
+}
+
+func (s *s3FS) key(name string) string {
+	if name == "." || name == "" {
+		return s.prefix
+	}
+	return path.Join(s.prefix, name)
+}
+
+func (s *s3FS) dirPrefix(name string) string {
+	key := s.key(name)
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func (s *s3FS) stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	if name == "." {
+		return &remoteFileInfo{name: ".", dir: true}, nil
+	}
+
+	o, err := s.store.StatObject(ctx, s.key(name))
+	if err == nil {
+		return &remoteFileInfo{name: name, size: o.Size, modTime: o.ModTime}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Not an object, it's a directory if anything is stored under it
+	objects, err := s.store.ListObjects(ctx, s.dirPrefix(name), false)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return &remoteFileInfo{name: name, dir: true}, nil
+}
+
+func (s *s3FS) readDir(ctx context.Context, name string) ([]fs.FileInfo, error) {
+	prefix := s.dirPrefix(name)
+	objects, err := s.store.ListObjects(ctx, prefix, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rtn := make([]fs.FileInfo, 0, len(objects))
+	seen := make(map[string]bool, len(objects))
+	for _, o := range objects {
+		rel := strings.TrimPrefix(o.Key, prefix)
+		// Skip the directory's own marker, and markers of subdirectories some
+		// services list next to the common prefix
+		if rel == "" || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+		if strings.HasSuffix(rel, "/") {
+			rtn = append(rtn, &remoteFileInfo{name: strings.TrimSuffix(rel, "/"), dir: true})
+			continue
+		}
+		rtn = append(rtn, &remoteFileInfo{name: rel, size: o.Size, modTime: o.ModTime})
+	}
+	return rtn, nil
+}
+
+func (s *s3FS) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return s.store.GetObject(ctx, s.key(name))
+}
+
+// minioStore implements objectStore on top of any S3 compatible service.
+type minioStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func (m *minioStore) StatObject(ctx context.Context, key string) (objectInfo, error) {
+	o, err := m.client.StatObject(ctx, m.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return objectInfo{}, minioError(err)
+	}
+	return objectInfo{Key: o.Key, Size: o.Size, ModTime: o.LastModified}, nil
+}
+
+func (m *minioStore) ListObjects(ctx context.Context, prefix string, recursive bool) ([]objectInfo, error) {
+	rtn := make([]objectInfo, 0)
+	for o := range m.client.ListObjects(ctx, m.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: recursive}) {
+		if o.Err != nil {
+			return nil, minioError(o.Err)
+		}
+		rtn = append(rtn, objectInfo{Key: o.Key, Size: o.Size, ModTime: o.LastModified})
+	}
+	return rtn, nil
+}
+
+func (m *minioStore) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	o, err := m.client.GetObject(ctx, m.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, minioError(err)
+	}
+	return o, nil
+}
+
+func (m *minioStore) PutObject(ctx context.Context, key string, r io.Reader, size int64) error {
+	// Unsigned payloads avoid the chunked streaming signature over plain HTTP,
+	// which not every S3 compatible service understands
+	_, err := m.client.PutObject(ctx, m.bucket, key, r, size, minio.PutObjectOptions{DisableContentSha256: true})
+	return minioError(err)
+}
+
+func (m *minioStore) RemoveObject(ctx context.Context, key string) error {
+	return minioError(m.client.RemoveObject(ctx, m.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+func (m *minioStore) CopyObject(ctx context.Context, srcKey, dstKey string) error {
+	_, err := m.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: m.bucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: m.bucket, Object: srcKey},
+	)
+	return minioError(err)
+}
+
+func minioError(err error) error {
+	if err == nil {
+		return nil
+	}
+	resp := minio.ToErrorResponse(err)
+	if resp.StatusCode == http.StatusNotFound || resp.Code == "NoSuchKey" {
+		return fmt.Errorf("%w: %s", fs.ErrNotExist, resp.Message)
+	}
+	return err
+}
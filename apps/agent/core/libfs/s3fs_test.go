@@ -0,0 +1,288 @@
+package libfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+// memStore is an in-memory objectStore listing the way S3 does: keys sorted,
+// and common prefixes with a trailing slash when not recursing.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemStore(objects map[string]string) *memStore {
+	m := &memStore{objects: make(map[string][]byte)}
+	for k, v := range objects {
+		m.objects[k] = []byte(v)
+	}
+	return m
+}
+
+var memModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func (m *memStore) StatObject(_ context.Context, key string) (objectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return objectInfo{}, fs.ErrNotExist
+	}
+	return objectInfo{Key: key, Size: int64(len(data)), ModTime: memModTime}, nil
+}
+
+func (m *memStore) ListObjects(_ context.Context, prefix string, recursive bool) ([]objectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var rtn []objectInfo
+	seen := make(map[string]bool)
+	for _, key := range m.keys() {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, "/"); !recursive && i >= 0 && i < len(rest)-1 {
+			common := prefix + rest[:i+1]
+			if !seen[common] {
+				seen[common] = true
+				rtn = append(rtn, objectInfo{Key: common})
+			}
+			continue
+		}
+		rtn = append(rtn, objectInfo{Key: key, Size: int64(len(m.objects[key])), ModTime: memModTime})
+	}
+	return rtn, nil
+}
+
+func (m *memStore) GetObject(_ context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memStore) PutObject(_ context.Context, key string, r io.Reader, _ int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memStore) RemoveObject(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// S3 doesn't complain about missing keys
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memStore) CopyObject(_ context.Context, srcKey, dstKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[srcKey]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	m.objects[dstKey] = slices.Clone(data)
+	return nil
+}
+
+func (m *memStore) keys() []string {
+	keys := make([]string, 0, len(m.objects))
+	for k := range m.objects {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func newTestS3FS(t *testing.T, objects map[string]string, config map[string]any) (*s3FS, *memStore) {
+	t.Helper()
+	store := newMemStore(objects)
+	cfg := runtime.FileSystem{Name: "s3", Kind: "s3", Config: map[string]any{"prefix": "lib"}}
+	for k, v := range config {
+		cfg.Config[k] = v
+	}
+	return newS3FSWithStore(cfg, "bucket", store), store
+}
+
+func readString(t *testing.T, f LibFS, name string) string {
+	t.Helper()
+	b, err := fs.ReadFile(f.GetFS(), name)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(b)
+}
+
+func entryNames(t *testing.T, f LibFS, name string) []string {
+	t.Helper()
+	entries, err := fs.ReadDir(f.GetFS(), name)
+	if err != nil {
+		t.Fatalf("read dir %s: %v", name, err)
+	}
+	var rtn []string
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		rtn = append(rtn, n)
+	}
+	return rtn
+}
+
+func TestS3FSRead(t *testing.T) {
+	s, _ := newTestS3FS(t, map[string]string{
+		"lib/a.txt":         "hello",
+		"lib/dir/b.stl":     "solid b",
+		"lib/dir/sub/c.txt": "c",
+		"lib/empty/":        "",
+		"other/x.txt":       "outside the prefix",
+	}, nil)
+
+	if err := fstest.TestFS(s.GetFS(), "a.txt", "dir/b.stl", "dir/sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readString(t, s, "dir/b.stl"); got != "solid b" {
+		t.Errorf("dir/b.stl = %q", got)
+	}
+	f, err := s.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil || string(b) != "hello" {
+		t.Errorf("Open(a.txt) read %q, %v", b, err)
+	}
+
+	if got, want := entryNames(t, s, "."), []string{"a.txt", "dir/", "empty/"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(.) = %v, want %v", got, want)
+	}
+	if got, want := entryNames(t, s, "dir"), []string{"b.stl", "sub/"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(dir) = %v, want %v", got, want)
+	}
+	if got := entryNames(t, s, "empty"); len(got) != 0 {
+		t.Errorf("ReadDir(empty) = %v, want nothing", got)
+	}
+
+	info, err := fs.Stat(s.GetFS(), "a.txt")
+	if err != nil || info.IsDir() || info.Size() != 5 || !info.ModTime().Equal(memModTime) {
+		t.Errorf("Stat(a.txt) = %v, %v", info, err)
+	}
+	info, err = fs.Stat(s.GetFS(), "dir/sub")
+	if err != nil || !info.IsDir() {
+		t.Errorf("Stat(dir/sub) = %v, %v", info, err)
+	}
+	if _, err := fs.Stat(s.GetFS(), "x.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(x.txt) error = %v, want not exist", err)
+	}
+}
+
+func TestS3FSWrite(t *testing.T) {
+	s, store := newTestS3FS(t, map[string]string{
+		"lib/dir/b.stl":     "solid b",
+		"lib/dir/sub/c.txt": "c",
+	}, nil)
+
+	w, err := s.Create("new/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, "written"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, s, "new/file.txt"); got != "written" {
+		t.Errorf("new/file.txt = %q", got)
+	}
+
+	if err := s.Mkdir("made"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fs.Stat(s.GetFS(), "made"); err != nil || !info.IsDir() {
+		t.Errorf("Stat(made) = %v, %v", info, err)
+	}
+
+	if err := s.Rename("new/file.txt", "renamed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, s, "renamed.txt"); got != "written" {
+		t.Errorf("renamed.txt = %q", got)
+	}
+	if _, err := fs.Stat(s.GetFS(), "new/file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("renamed file still there: %v", err)
+	}
+
+	if err := s.Rename("dir", "moved"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, s, "moved/sub/c.txt"); got != "c" {
+		t.Errorf("moved/sub/c.txt = %q", got)
+	}
+	if _, err := fs.Stat(s.GetFS(), "dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("renamed directory still there: %v", err)
+	}
+	if err := s.Rename("missing", "elsewhere"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Rename(missing) error = %v, want not exist", err)
+	}
+
+	if err := s.Remove("moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("renamed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("made"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := store.keys(); len(keys) != 0 {
+		t.Errorf("objects left after removing everything: %v", keys)
+	}
+}
+
+func TestS3FSReadOnly(t *testing.T) {
+	s, store := newTestS3FS(t, map[string]string{"lib/a.txt": "hello"}, map[string]any{"read_only": true})
+
+	if s.Writable() {
+		t.Fatal("read only filesystem is writable")
+	}
+	if _, err := s.Create("b.txt"); err == nil {
+		t.Error("Create succeeded on a read only filesystem")
+	}
+	if err := s.Mkdir("d"); err == nil {
+		t.Error("Mkdir succeeded on a read only filesystem")
+	}
+	if err := s.Rename("a.txt", "b.txt"); err == nil {
+		t.Error("Rename succeeded on a read only filesystem")
+	}
+	if err := s.Remove("a.txt"); err == nil {
+		t.Error("Remove succeeded on a read only filesystem")
+	}
+	if keys := store.keys(); !slices.Equal(keys, []string{"lib/a.txt"}) {
+		t.Errorf("objects changed on a read only filesystem: %v", keys)
+	}
+}
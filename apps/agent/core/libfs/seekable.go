@@ -0,0 +1,77 @@
+package libfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+// SeekableFile is a file that can be served with range requests.
+type SeekableFile interface {
+	fs.File
+	io.Seeker
+}
+
+// OpenSeekable opens name on the filesystem and makes sure the file can seek.
+// Files of remote and bundle filesystems are spooled to a temporary file first.
+func OpenSeekable(f LibFS, name string) (SeekableFile, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return Seekable(file)
+}
+
+// Seekable returns file itself when it can seek, or a spooled copy of it in
+// which case file is closed already.
+func Seekable(file fs.File) (SeekableFile, error) {
+	if s, ok := file.(SeekableFile); ok {
+		return s, nil
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir := filepath.Join(runtime.GetDataPath(), "temp")
+	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	temp, err := os.CreateTemp(tempDir, "seekable_*")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(temp, file); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return nil, err
+	}
+	if _, err := temp.Seek(0, io.SeekStart); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return nil, err
+	}
+	return &spooledFile{File: temp, info: info}, nil
+}
+
+// spooledFile is a temporary copy of a file that keeps the original's info and
+// is removed on Close.
+type spooledFile struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (s *spooledFile) Stat() (fs.FileInfo, error) {
+	return s.info, nil
+}
+
+func (s *spooledFile) Close() error {
+	defer os.Remove(s.File.Name())
+	return s.File.Close()
+}
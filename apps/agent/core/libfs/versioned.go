@@ -0,0 +1,31 @@
+package libfs
+
+import (
+	"io/fs"
+	"time"
+)
+
+// MetadataSuffix is appended to the path of a file to name the sidecar that
+// keeps its metadata on filesystems that version it.
+const MetadataSuffix = ".mmp.json"
+
+// Versioned is implemented by filesystems that keep the history of their files.
+type Versioned interface {
+	// History lists the revisions that touched name, newest first.
+	History(name string) ([]Revision, error)
+	// OpenRevision opens name as it was at rev.
+	OpenRevision(name, rev string) (fs.File, error)
+}
+
+type Revision struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// MetadataPath returns the path of the metadata sidecar of name.
+func MetadataPath(name string) string {
+	return name + MetadataSuffix
+}
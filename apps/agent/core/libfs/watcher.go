@@ -0,0 +1,276 @@
+package libfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/logger"
+)
+
+type ChangeOp string
+
+const (
+	ChangeCreate ChangeOp = "create"
+	ChangeWrite  ChangeOp = "write"
+	ChangeRemove ChangeOp = "remove"
+	ChangeMove   ChangeOp = "move"
+)
+
+// Change is a debounced filesystem change, with paths relative to the LibFS root.
+type Change struct {
+	Op      ChangeOp
+	Path    string
+	OldPath string
+}
+
+const maxDebounceWait = 30 * time.Second
+
+// IsWatchable reports whether changes on the filesystem can be followed with inotify.
+func IsWatchable(f LibFS) bool {
+	return f.Kind() == "local" || f.Kind() == "git"
+}
+
+// Watch follows changes under the root of a local or git filesystem and emits them
+// in batches once no new event arrived for the debounce period.
+func Watch(ctx context.Context, f LibFS, debounce time.Duration, skip func(name string) bool) (<-chan []Change, error) {
+	if !IsWatchable(f) {
+		return nil, errors.New("watch not supported on " + f.Kind() + " filesystem")
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := &fsWatcher{
+		w:       w,
+		root:    filepath.Clean(f.GetRoot()),
+		skip:    skip,
+		pending: make(map[string]*Change),
+		log:     logger.GetLogger().With(zap.String("module", "watcher"), zap.String("fs", f.GetName())),
+	}
+
+	if err := fw.addRecursive(fw.root); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	out := make(chan []Change)
+	go fw.run(ctx, debounce, out)
+
+	return out, nil
+}
+
+type fsWatcher struct {
+	w       *fsnotify.Watcher
+	root    string
+	skip    func(name string) bool
+	pending map[string]*Change
+	renamed []string
+	log     *zap.Logger
+}
+
+func (fw *fsWatcher) run(ctx context.Context, debounce time.Duration, out chan<- []Change) {
+	defer close(out)
+	defer fw.w.Close()
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	var firstEvent time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-fw.w.Errors:
+			if !ok {
+				return
+			}
+			fw.log.Warn("watch error", zap.Error(err))
+		case ev, ok := <-fw.w.Events:
+			if !ok {
+				return
+			}
+			if !fw.handle(ev) {
+				continue
+			}
+			if firstEvent.IsZero() {
+				firstEvent = time.Now()
+			}
+			// Keep postponing the flush while a large copy is in progress, up to a hard limit.
+			if time.Since(firstEvent) < maxDebounceWait {
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			firstEvent = time.Time{}
+			batch := fw.flush()
+			if len(batch) == 0 {
+				continue
+			}
+			select {
+			case out <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (fw *fsWatcher) handle(ev fsnotify.Event) bool {
+	rel, err := filepath.Rel(fw.root, ev.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if fw.skip != nil && fw.skip(part) {
+			return false
+		}
+	}
+
+	switch {
+	case ev.Op&fsnotify.Create == fsnotify.Create:
+		if st, err := os.Stat(ev.Name); err == nil && st.IsDir() {
+			if err := fw.addRecursive(ev.Name); err != nil {
+				fw.log.Warn("failed to watch new directory", zap.String("path", rel), zap.Error(err))
+			}
+		}
+		fw.record(rel, ChangeCreate)
+	case ev.Op&fsnotify.Write == fsnotify.Write:
+		fw.record(rel, ChangeWrite)
+	case ev.Op&fsnotify.Remove == fsnotify.Remove:
+		fw.record(rel, ChangeRemove)
+	case ev.Op&fsnotify.Rename == fsnotify.Rename:
+		fw.unwatch(ev.Name)
+		fw.record(rel, ChangeRemove)
+		fw.renamed = append(fw.renamed, rel)
+	default:
+		return false
+	}
+	return true
+}
+
+// record coalesces a new operation with whatever is already pending for the path.
+func (fw *fsWatcher) record(path string, op ChangeOp) {
+	prev, ok := fw.pending[path]
+	if !ok {
+		fw.pending[path] = &Change{Op: op, Path: path}
+		return
+	}
+
+	switch {
+	case prev.Op == ChangeCreate && op == ChangeRemove:
+		delete(fw.pending, path)
+	case prev.Op == ChangeCreate && op == ChangeWrite:
+	case prev.Op == ChangeRemove && op != ChangeRemove:
+		prev.Op = ChangeWrite
+	default:
+		prev.Op = op
+	}
+}
+
+// flush returns the pending changes, pairing renamed paths with created ones so
+// moves can be applied without losing metadata. Moves come first, then removals,
+// then creations and writes with parents ahead of their children.
+func (fw *fsWatcher) flush() []Change {
+	creates := make([]*Change, 0)
+	for _, c := range fw.pending {
+		if c.Op == ChangeCreate {
+			creates = append(creates, c)
+		}
+	}
+	sort.Slice(creates, func(i, j int) bool { return creates[i].Path < creates[j].Path })
+
+	renames := make([]*Change, 0)
+	for _, p := range fw.renamed {
+		if c, ok := fw.pending[p]; ok && c.Op == ChangeRemove {
+			renames = append(renames, c)
+		}
+	}
+
+	paired := make(map[*Change]bool)
+	for _, r := range renames {
+		var match *Change
+		for _, c := range creates {
+			if !paired[c] && filepath.Base(c.Path) == filepath.Base(r.Path) {
+				match = c
+				break
+			}
+		}
+		if match == nil && len(renames) == 1 {
+			for _, c := range creates {
+				if !paired[c] {
+					match = c
+					break
+				}
+			}
+		}
+		if match == nil {
+			continue
+		}
+		paired[match] = true
+		match.Op = ChangeMove
+		match.OldPath = r.Path
+		delete(fw.pending, r.Path)
+	}
+
+	rtn := make([]Change, 0, len(fw.pending))
+	for _, c := range fw.pending {
+		rtn = append(rtn, *c)
+	}
+	sort.SliceStable(rtn, func(i, j int) bool {
+		if opOrder(rtn[i].Op) != opOrder(rtn[j].Op) {
+			return opOrder(rtn[i].Op) < opOrder(rtn[j].Op)
+		}
+		return rtn[i].Path < rtn[j].Path
+	})
+
+	fw.pending = make(map[string]*Change)
+	fw.renamed = nil
+	return rtn
+}
+
+func opOrder(op ChangeOp) int {
+	switch op {
+	case ChangeMove:
+		return 0
+	case ChangeRemove:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// unwatch drops the watches of a renamed directory and its subdirectories; the
+// new location is picked up again by the matching create event.
+func (fw *fsWatcher) unwatch(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for _, p := range fw.w.WatchList() {
+		if p == dir || strings.HasPrefix(p, prefix) {
+			_ = fw.w.Remove(p)
+		}
+	}
+}
+
+func (fw *fsWatcher) addRecursive(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && fw.skip != nil && fw.skip(d.Name()) {
+			return filepath.SkipDir
+		}
+		return fw.w.Add(path)
+	})
+}
@@ -0,0 +1,296 @@
+package libfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/studio-b12/gowebdav"
+
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+const defaultWebDAVCacheTTL = time.Minute
+
+type webdavFS struct {
+	config       runtime.FileSystem
+	client       *gowebdav.Client
+	url          string
+	root         string
+	remote       *remoteFS
+	listings     *listingCache
+	discovarable bool
+}
+
+func newWebDAVFS(cfg runtime.FileSystem) (LibFS, error) {
+	url := configString(cfg, "url")
+	if url == "" {
+		return nil, fmt.Errorf("webdav filesystem %s needs a url", cfg.Name)
+	}
+
+	ttl := defaultWebDAVCacheTTL
+	switch v := cfg.Config["cache_ttl"].(type) {
+	case int:
+		ttl = time.Duration(v) * time.Second
+	case int64:
+		ttl = time.Duration(v) * time.Second
+	case float64:
+		ttl = time.Duration(v * float64(time.Second))
+	}
+
+	root := cfg.Path
+	if root == "" {
+		root = "/"
+	}
+
+	client := gowebdav.NewClient(url, configString(cfg, "username"), configString(cfg, "password"))
+	client.SetTimeout(time.Minute)
+
+	w := &webdavFS{
+		config:       cfg,
+		client:       client,
+		url:          strings.TrimSuffix(url, "/"),
+		root:         path.Clean("/" + root),
+		listings:     newListingCache(ttl),
+		discovarable: true,
+	}
+	w.remote = &remoteFS{backend: w}
+	return w, nil
+}
+
+func (w *webdavFS) IsDiscovarable() bool {
+	return w.discovarable
+}
+
+func (w *webdavFS) SetDiscovarable(d bool) {
+	w.discovarable = d
+}
+
+func (w *webdavFS) GetFS() fs.FS {
+	return w.remote
+}
+
+func (w *webdavFS) GetName() string {
+	return w.config.Name
+}
+
+func (w *webdavFS) GetLocation() string {
+	return w.url + w.root
+}
+
+func (w *webdavFS) GetRoot() string {
+	return w.GetLocation()
+}
+
+func (w *webdavFS) Kind() string {
+	return "webdav"
+}
+
+func (w *webdavFS) Open(name string) (fs.File, error) {
+	return w.remote.Open(name)
+}
+
+func (w *webdavFS) Writable() bool {
+	return !configBool(w.config, "read_only", false)
+}
+
+// Create spools the content to a temporary file and uploads it on Close, most
+// servers want to know the length of a PUT upfront.
+func (w *webdavFS) Create(name string) (io.WriteCloser, error) {
+	if !w.Writable() {
+		return nil, errors.New("write not supported on read only webdav filesystem")
+	}
+
+	tempDir := filepath.Join(runtime.GetDataPath(), "temp")
+	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(tempDir, "webdav_*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &spoolWriter{File: f, upload: func(r io.Reader, size int64) error {
+		defer w.listings.invalidate(name)
+		return webdavError(w.client.WriteStreamWithLength(w.remotePath(name), r, size, 0644))
+	}}, nil
+}
+
+func (w *webdavFS) Mkdir(name string) error {
+	if !w.Writable() {
+		return errors.New("mkdir not supported on read only webdav filesystem")
+	}
+	defer w.listings.invalidate(name)
+	return webdavError(w.client.MkdirAll(w.remotePath(name), 0755))
+}
+
+func (w *webdavFS) Remove(name string) error {
+	if !w.Writable() {
+		return errors.New("remove not supported on read only webdav filesystem")
+	}
+	defer w.listings.invalidate(name)
+	return webdavError(w.client.RemoveAll(w.remotePath(name)))
+}
+
+func (w *webdavFS) Rename(oldName, newName string) error {
+	if !w.Writable() {
+		return errors.New("rename not supported on read only webdav filesystem")
+	}
+	defer w.listings.invalidate(oldName)
+	defer w.listings.invalidate(newName)
+	if err := w.client.MkdirAll(w.remotePath(path.Dir(newName)), 0755); err != nil {
+		return webdavError(err)
+	}
+	return webdavError(w.client.Rename(w.remotePath(oldName), w.remotePath(newName), true))
+}
+
+func (w *webdavFS) IsBundle(path string) bool {
+	return IsBundle(path)
+}
+
+func (w *webdavFS) remotePath(name string) string {
+	return path.Join(w.root, name)
+}
+
+func (w *webdavFS) stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	if name == "." {
+		return &remoteFileInfo{name: ".", dir: true}, nil
+	}
+
+	// Discovery stats every entry it just listed, answer from the listing
+	if infos, ok := w.listings.get(path.Dir(name)); ok {
+		for _, info := range infos {
+			if info.Name() == path.Base(name) {
+				return info, nil
+			}
+		}
+		return nil, fs.ErrNotExist
+	}
+
+	info, err := w.client.Stat(w.remotePath(name))
+	if err != nil {
+		return nil, webdavError(err)
+	}
+	return &remoteFileInfo{name: name, size: info.Size(), modTime: info.ModTime(), dir: info.IsDir()}, nil
+}
+
+func (w *webdavFS) readDir(ctx context.Context, name string) ([]fs.FileInfo, error) {
+	if infos, ok := w.listings.get(name); ok {
+		return infos, nil
+	}
+
+	// ReadDir is a PROPFIND with depth 1
+	entries, err := w.client.ReadDir(w.remotePath(name))
+	if err != nil {
+		return nil, webdavError(err)
+	}
+
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		infos = append(infos, &remoteFileInfo{name: e.Name(), size: e.Size(), modTime: e.ModTime(), dir: e.IsDir()})
+	}
+	w.listings.put(name, infos)
+	return infos, nil
+}
+
+func (w *webdavFS) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := w.client.ReadStream(w.remotePath(name))
+	if err != nil {
+		return nil, webdavError(err)
+	}
+	return r, nil
+}
+
+func webdavError(err error) error {
+	if err != nil && gowebdav.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %s", fs.ErrNotExist, err.Error())
+	}
+	return err
+}
+
+// listingCache keeps directory listings of a remote filesystem for a while, so
+// repeated scans don't list every directory again.
+type listingCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedListing
+}
+
+type cachedListing struct {
+	infos []fs.FileInfo
+	at    time.Time
+}
+
+func newListingCache(ttl time.Duration) *listingCache {
+	return &listingCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedListing),
+	}
+}
+
+func (c *listingCache) get(dir string) ([]fs.FileInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.entries[path.Clean(dir)]
+	if !ok || time.Since(l.at) > c.ttl {
+		return nil, false
+	}
+	return l.infos, true
+}
+
+func (c *listingCache) put(dir string, infos []fs.FileInfo) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path.Clean(dir)] = cachedListing{infos: infos, at: time.Now()}
+}
+
+// invalidate drops the listings of the changed path, of everything under it
+// and of its ancestors, writes can create missing parents.
+func (c *listingCache) invalidate(name string) {
+	name = path.Clean(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		delete(c.entries, dir)
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	for dir := range c.entries {
+		if dir == name || strings.HasPrefix(dir, name+"/") || name == "." {
+			delete(c.entries, dir)
+		}
+	}
+}
+
+// spoolWriter buffers writes in a temporary file and hands it to upload on
+// Close, once its length is known.
+type spoolWriter struct {
+	*os.File
+	upload func(r io.Reader, size int64) error
+}
+
+func (s *spoolWriter) Close() error {
+	defer os.Remove(s.File.Name())
+	defer s.File.Close()
+
+	size, err := s.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := s.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return s.upload(s.File, size)
+}
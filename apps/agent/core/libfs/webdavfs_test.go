@@ -0,0 +1,199 @@
+package libfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+// newTestWebDAVFS serves a temporary directory over WebDAV and opens its lib
+// directory as a filesystem, the directory is returned to change it behind
+// the filesystem's back.
+func newTestWebDAVFS(t *testing.T, files map[string]string, config map[string]any) (*webdavFS, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, "lib", name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(&webdav.Handler{FileSystem: webdav.Dir(dir), LockSystem: webdav.NewMemLS()})
+	t.Cleanup(srv.Close)
+
+	cfg := runtime.FileSystem{Name: "dav", Kind: "webdav", Path: "/lib", Config: map[string]any{"url": srv.URL}}
+	for k, v := range config {
+		cfg.Config[k] = v
+	}
+	f, err := newWebDAVFS(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.(*webdavFS), filepath.Join(dir, "lib")
+}
+
+func TestWebDAVFSRead(t *testing.T) {
+	w, _ := newTestWebDAVFS(t, map[string]string{
+		"a.txt":         "hello",
+		"dir/b.stl":     "solid b",
+		"dir/sub/c.txt": "c",
+	}, nil)
+
+	if err := fstest.TestFS(w.GetFS(), "a.txt", "dir/b.stl", "dir/sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := w.Open("dir/b.stl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil || string(b) != "solid b" {
+		t.Errorf("Open(dir/b.stl) read %q, %v", b, err)
+	}
+
+	if got, want := entryNames(t, w, "dir"), []string{"b.stl", "sub/"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(dir) = %v, want %v", got, want)
+	}
+	info, err := fs.Stat(w.GetFS(), "a.txt")
+	if err != nil || info.IsDir() || info.Size() != 5 {
+		t.Errorf("Stat(a.txt) = %v, %v", info, err)
+	}
+	if _, err := w.Open("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(missing.txt) error = %v, want not exist", err)
+	}
+}
+
+func TestWebDAVFSListingCache(t *testing.T) {
+	w, dir := newTestWebDAVFS(t, map[string]string{"a.txt": "a", "dir/b.txt": "b"}, map[string]any{"cache_ttl": 3600})
+
+	if got, want := entryNames(t, w, "."), []string{"a.txt", "dir/"}; !slices.Equal(got, want) {
+		t.Fatalf("ReadDir(.) = %v, want %v", got, want)
+	}
+	// Changes made elsewhere show once the listing expires
+	if err := os.WriteFile(filepath.Join(dir, "elsewhere.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(t, w, "."), []string{"a.txt", "dir/"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(.) after an outside change = %v, want the cached %v", got, want)
+	}
+
+	// Writes through the filesystem drop the listings they change
+	writeString(t, w, "dir/new/c.txt", "c")
+	if got, want := entryNames(t, w, "."), []string{"a.txt", "dir/", "elsewhere.txt"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(.) after Create = %v, want %v", got, want)
+	}
+	if got, want := entryNames(t, w, "dir"), []string{"b.txt", "new/"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(dir) after Create = %v, want %v", got, want)
+	}
+
+	if err := w.Rename("dir/b.txt", "moved/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(t, w, "dir"), []string{"new/"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(dir) after Rename = %v, want %v", got, want)
+	}
+	if got, want := entryNames(t, w, "moved"), []string{"b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(moved) after Rename = %v, want %v", got, want)
+	}
+
+	if err := w.Remove("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(t, w, "."), []string{"a.txt", "elsewhere.txt", "moved/"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDir(.) after Remove = %v, want %v", got, want)
+	}
+	if _, err := fs.Stat(w.GetFS(), "dir/new/c.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(dir/new/c.txt) after Remove error = %v, want not exist", err)
+	}
+}
+
+func TestWebDAVFSWrite(t *testing.T) {
+	w, dir := newTestWebDAVFS(t, map[string]string{"dir/b.stl": "solid b", "dir/sub/c.txt": "c"}, nil)
+
+	writeString(t, w, "new/file.txt", "written")
+	if got := readString(t, w, "new/file.txt"); got != "written" {
+		t.Errorf("new/file.txt = %q", got)
+	}
+
+	if err := w.Mkdir("made/deep"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fs.Stat(w.GetFS(), "made/deep"); err != nil || !info.IsDir() {
+		t.Errorf("Stat(made/deep) = %v, %v", info, err)
+	}
+
+	if err := w.Rename("dir", "moved/dir"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, w, "moved/dir/sub/c.txt"); got != "c" {
+		t.Errorf("moved/dir/sub/c.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dir")); !os.IsNotExist(err) {
+		t.Errorf("renamed directory still there: %v", err)
+	}
+
+	for _, name := range []string{"new", "made", "moved"} {
+		if err := w.Remove(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("left after removing everything: %v, %v", entries, err)
+	}
+}
+
+func TestWebDAVFSReadOnly(t *testing.T) {
+	w, dir := newTestWebDAVFS(t, map[string]string{"a.txt": "hello"}, map[string]any{"read_only": true})
+
+	if w.Writable() {
+		t.Fatal("read only filesystem is writable")
+	}
+	if _, err := w.Create("b.txt"); err == nil {
+		t.Error("Create succeeded on a read only filesystem")
+	}
+	if err := w.Mkdir("d"); err == nil {
+		t.Error("Mkdir succeeded on a read only filesystem")
+	}
+	if err := w.Rename("a.txt", "b.txt"); err == nil {
+		t.Error("Rename succeeded on a read only filesystem")
+	}
+	if err := w.Remove("a.txt"); err == nil {
+		t.Error("Remove succeeded on a read only filesystem")
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("files changed on a read only filesystem: %v, %v", entries, err)
+	}
+}
+
+func writeString(t *testing.T, f LibFS, name, content string) {
+	t.Helper()
+	w, err := f.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
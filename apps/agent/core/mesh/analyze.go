@@ -0,0 +1,158 @@
+package mesh
+
+import (
+	"math"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+)
+
+const (
+	// weldTolerance merges vertices closer than this, in model units, when
+	// looking at how triangles connect
+	weldTolerance = 1e-4
+	// Models whose largest side is below this many units may be in inches,
+	// only a hint as plenty of small parts are modelled in millimetres
+	inchGuessMax = 6.0
+)
+
+// Metrics describe the geometry of a model.
+// Sizes are in millimetres when the file declares its unit and in model units
+// otherwise.
+type Metrics struct {
+	SizeX       float64
+	SizeY       float64
+	SizeZ       float64
+	Volume      float64
+	SurfaceArea float64
+	Triangles   int
+	// Watertight meshes have no open edges
+	Watertight bool
+	// Manifold meshes have every edge shared by exactly two consistently
+	// oriented triangles
+	Manifold bool
+	// Unit is the unit the file declares, empty when it doesn't
+	Unit string
+	// GuessedUnit is the unit a file without one looks like it's in, empty
+	// when the file declares it
+	GuessedUnit string
+}
+
+// Analyze measures a model. Only a declared unit converts the sizes, a guessed
+// one is merely reported so a small model in millimetres keeps its size.
+func Analyze(m *Model) Metrics {
+	size := m.Mesh.BoundingBox().Size()
+	rtn := Metrics{
+		Triangles: len(m.Mesh.Triangles),
+		Unit:      m.Unit,
+	}
+	rtn.Watertight, rtn.Manifold = topology(m.Mesh)
+
+	scale, ok := millimetres[rtn.Unit]
+	if !ok {
+		scale = 1
+	}
+	if rtn.Unit == "" {
+		rtn.GuessedUnit = guessUnit(size)
+	}
+	rtn.SizeX, rtn.SizeY, rtn.SizeZ = size.X*scale, size.Y*scale, size.Z*scale
+	rtn.Volume = m.Mesh.Volume() * scale * scale * scale
+	rtn.SurfaceArea = m.Mesh.SurfaceArea() * scale * scale
+	return rtn
+}
+
+// millimetres is the length of one unit in millimetres.
+var millimetres = map[string]float64{
+	"mm":   1,
+	"cm":   10,
+	"m":    1000,
+	"inch": 25.4,
+}
+
+// unitNames are the spellings of units exporters write in comments.
+var unitNames = map[string]string{
+	"mm":          "mm",
+	"millimeter":  "mm",
+	"millimeters": "mm",
+	"millimetre":  "mm",
+	"millimetres": "mm",
+	"cm":          "cm",
+	"centimeter":  "cm",
+	"centimeters": "cm",
+	"m":           "m",
+	"meter":       "m",
+	"meters":      "m",
+	"in":          "inch",
+	"inch":        "inch",
+	"inches":      "inch",
+}
+
+// commentUnit reads the unit an OBJ or PLY comment like "units: mm" or
+// "Unit = inches" declares, empty when it isn't one.
+func commentUnit(comment string) string {
+	f := strings.FieldsFunc(strings.ToLower(comment), func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ':' || r == '='
+	})
+	if len(f) != 2 || (f[0] != "unit" && f[0] != "units") {
+		return ""
+	}
+	return unitNames[f[1]]
+}
+
+type vertexKey [3]int64
+
+type edgeKey struct {
+	a, b vertexKey
+}
+
+// topology counts how triangles share their edges, vertices are welded first
+// as STL repeats them for every triangle.
+func topology(m *fauxgl.Mesh) (watertight, manifold bool) {
+	if len(m.Triangles) == 0 {
+		return false, false
+	}
+
+	// Directed edges, an edge used twice the same way is flipped or shared by
+	// more than two triangles
+	edges := make(map[edgeKey]int, len(m.Triangles)*3)
+	for _, t := range m.Triangles {
+		v := [3]vertexKey{weld(t.V1.Position), weld(t.V2.Position), weld(t.V3.Position)}
+		if v[0] == v[1] || v[1] == v[2] || v[0] == v[2] {
+			continue
+		}
+		for i := range v {
+			edges[edgeKey{v[i], v[(i+1)%3]}]++
+		}
+	}
+
+	watertight, manifold = true, true
+	for e, n := range edges {
+		reverse := edges[edgeKey{e.b, e.a}]
+		if reverse == 0 && n == 1 {
+			watertight = false
+		}
+		if n != 1 || reverse != 1 {
+			manifold = false
+		}
+		if !watertight && !manifold {
+			break
+		}
+	}
+	return watertight && len(edges) > 0, manifold && len(edges) > 0
+}
+
+func weld(p fauxgl.Vector) vertexKey {
+	return vertexKey{
+		int64(math.Round(p.X / weldTolerance)),
+		int64(math.Round(p.Y / weldTolerance)),
+		int64(math.Round(p.Z / weldTolerance)),
+	}
+}
+
+func guessUnit(size fauxgl.Vector) string {
+	largest := math.Max(size.X, math.Max(size.Y, size.Z))
+	if largest > 0 && largest < inchGuessMax {
+		return "inch"
+	}
+	return "mm"
+}
@@ -0,0 +1,116 @@
+package mesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/utils"
+)
+
+var ErrUnsupported = errors.New("unsupported mesh format")
+
+// Model is the geometry of a model file.
+type Model struct {
+	Mesh *fauxgl.Mesh
+	// Unit is the unit the file declares the mesh in, 3MF meshes are converted
+	// to millimetres on load. Empty for files that don't say.
+	Unit string
+	// Parts are the named groups or objects of the file, empty when it has none
+	Parts []Part
+}
+
+// Part is a named range of the model triangles.
+type Part struct {
+	Name  string
+	Start int
+	End   int
+}
+
+type loader func(ctx context.Context, asset *entities.Asset) (*Model, error)
+
+var loaders = map[string]loader{
+	".stl": fileLoader(loadSTL),
+	".obj": readerLoader(ReadOBJ),
+	".ply": readerLoader(ReadPLY),
+	".3mf": load3MF,
+}
+
+// Supported reports whether meshes can be loaded from files with the extension.
+func Supported(ext string) bool {
+	_, ok := loaders[strings.ToLower(ext)]
+	return ok
+}
+
+// Load reads the mesh of a model asset, from its own filesystem or bundle.
+func Load(ctx context.Context, asset *entities.Asset) (*Model, error) {
+	if asset.Extension == nil {
+		return nil, ErrUnsupported
+	}
+	l, ok := loaders[strings.ToLower(*asset.Extension)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, *asset.Extension)
+	}
+	return l(ctx, asset)
+}
+
+// fileLoader wraps fauxgl loaders that read from a path, the file is copied
+// to a temporary one first as it may live in a remote filesystem or a bundle.
+func fileLoader(load func(path string) (*Model, error)) loader {
+	return func(ctx context.Context, asset *entities.Asset) (*Model, error) {
+		src, err := libfs.OpenAsset(ctx, *asset)
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+
+		tempPath, err := spool(src, filepath.Ext(utils.VoZ(asset.Path)))
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tempPath)
+
+		return load(tempPath)
+	}
+}
+
+// readerLoader wraps parsers that stream the file.
+func readerLoader(read func(r io.Reader) (*Model, error)) loader {
+	return func(ctx context.Context, asset *entities.Asset) (*Model, error) {
+		src, err := libfs.OpenAsset(ctx, *asset)
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+		return read(src)
+	}
+}
+
+func spool(r io.Reader, ext string) (string, error) {
+	tempDir := filepath.Join(runtime.GetDataPath(), "temp")
+	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	temp, err := os.CreateTemp(tempDir, "mesh_*"+strings.ToLower(ext))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := io.Copy(temp, r); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return "", fmt.Errorf("failed to copy to temp file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	return temp.Name(), nil
+}
@@ -0,0 +1,108 @@
+package mesh
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+)
+
+// ReadOBJ reads the faces of a Wavefront OBJ file, polygons are split into
+// triangle fans. Objects and groups become parts, materials and textures are
+// ignored.
+func ReadOBJ(r io.Reader) (*Model, error) {
+	vertices := make([]fauxgl.Vector, 0, 1024)
+	mesh := fauxgl.NewEmptyMesh()
+	model := &Model{Mesh: mesh}
+	var part *Part
+
+	startPart := func(name string) {
+		if part != nil {
+			part.End = len(mesh.Triangles)
+			if part.End > part.Start {
+				model.Parts = append(model.Parts, *part)
+			}
+		}
+		part = &Part{Name: name, Start: len(mesh.Triangles)}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment, ok := strings.CutPrefix(strings.TrimSpace(line), "#"); ok {
+			if u := commentUnit(comment); u != "" && model.Unit == "" {
+				model.Unit = u
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, errors.New("obj vertex with less than 3 coordinates")
+			}
+			var p [3]float64
+			for i := range p {
+				v, err := strconv.ParseFloat(fields[i+1], 64)
+				if err != nil {
+					return nil, err
+				}
+				p[i] = v
+			}
+			vertices = append(vertices, fauxgl.V(p[0], p[1], p[2]))
+		case "o", "g":
+			name := strings.TrimSpace(strings.Join(fields[1:], " "))
+			if part != nil && part.Name == name {
+				continue
+			}
+			startPart(name)
+		case "f":
+			idx := make([]int, 0, len(fields)-1)
+			for _, f := range fields[1:] {
+				i, ok := objIndex(f, len(vertices))
+				if !ok {
+					return nil, errors.New("obj face with an invalid vertex index")
+				}
+				idx = append(idx, i)
+			}
+			for i := 1; i+1 < len(idx); i++ {
+				mesh.Triangles = append(mesh.Triangles, fauxgl.NewTriangleForPoints(vertices[idx[0]], vertices[idx[i]], vertices[idx[i+1]]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if part != nil {
+		startPart("")
+	}
+	if len(mesh.Triangles) == 0 {
+		return nil, errors.New("obj has no faces")
+	}
+	return model, nil
+}
+
+// objIndex resolves the vertex of a face element like 3, 3/1 or -1//2,
+// negative indices count back from the last vertex.
+func objIndex(s string, n int) (int, bool) {
+	v, _, _ := strings.Cut(s, "/")
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	if i < 0 {
+		i += n
+	} else {
+		i--
+	}
+	return i, i >= 0 && i < n
+}
@@ -0,0 +1,245 @@
+package mesh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+)
+
+type plyProperty struct {
+	name      string
+	dataType  string
+	countType string // set for list properties
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// ReadPLY reads the faces of an ASCII or binary PLY file, polygons are split
+// into triangle fans. Vertex colors, normals and other elements are skipped.
+func ReadPLY(r io.Reader) (*Model, error) {
+	br := bufio.NewReaderSize(r, 256*1024)
+	format, unit, elements, err := readPLYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var read plyReader
+	switch format {
+	case "ascii":
+		read = &plyASCII{r: br}
+	case "binary_little_endian":
+		read = &plyBinary{r: br, order: binary.LittleEndian}
+	case "binary_big_endian":
+		read = &plyBinary{r: br, order: binary.BigEndian}
+	default:
+		return nil, fmt.Errorf("unsupported ply format %q", format)
+	}
+
+	var vertices []fauxgl.Vector
+	mesh := fauxgl.NewEmptyMesh()
+	for _, e := range elements {
+		for i := 0; i < e.count; i++ {
+			var v fauxgl.Vector
+			var face []int
+			for _, p := range e.properties {
+				if p.countType != "" {
+					n, err := read.value(p.countType)
+					if err != nil {
+						return nil, err
+					}
+					if n < 0 || n > math.MaxUint16 {
+						return nil, errors.New("ply list too long")
+					}
+					list := make([]int, int(n))
+					for j := range list {
+						x, err := read.value(p.dataType)
+						if err != nil {
+							return nil, err
+						}
+						list[j] = int(x)
+					}
+					if p.name == "vertex_indices" || p.name == "vertex_index" {
+						face = list
+					}
+					continue
+				}
+
+				x, err := read.value(p.dataType)
+				if err != nil {
+					return nil, err
+				}
+				switch p.name {
+				case "x":
+					v.X = x
+				case "y":
+					v.Y = x
+				case "z":
+					v.Z = x
+				}
+			}
+			read.endElement()
+
+			switch e.name {
+			case "vertex":
+				vertices = append(vertices, v)
+			case "face":
+				for j := 1; j+1 < len(face); j++ {
+					a, b, c := face[0], face[j], face[j+1]
+					if min(a, b, c) < 0 || max(a, b, c) >= len(vertices) {
+						return nil, errors.New("ply face with an invalid vertex index")
+					}
+					mesh.Triangles = append(mesh.Triangles, fauxgl.NewTriangleForPoints(vertices[a], vertices[b], vertices[c]))
+				}
+			}
+		}
+	}
+
+	if len(mesh.Triangles) == 0 {
+		return nil, errors.New("ply has no faces")
+	}
+	return &Model{Mesh: mesh, Unit: unit}, nil
+}
+
+// readPLYHeader returns the format, the unit a comment declares if any, and
+// the elements of a PLY file.
+func readPLYHeader(r *bufio.Reader) (string, string, []plyElement, error) {
+	line, err := r.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "ply" {
+		return "", "", nil, errors.New("not a ply file")
+	}
+
+	var format, unit string
+	var elements []plyElement
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", "", nil, fmt.Errorf("failed to read ply header: %w", err)
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+
+		switch f[0] {
+		case "comment":
+			if u := commentUnit(strings.Join(f[1:], " ")); u != "" {
+				unit = u
+			}
+		case "format":
+			if len(f) < 2 {
+				return "", "", nil, errors.New("invalid ply format line")
+			}
+			format = f[1]
+		case "element":
+			if len(f) < 3 {
+				return "", "", nil, errors.New("invalid ply element line")
+			}
+			count, err := strconv.Atoi(f[2])
+			if err != nil {
+				return "", "", nil, err
+			}
+			elements = append(elements, plyElement{name: f[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return "", "", nil, errors.New("ply property outside of an element")
+			}
+			e := &elements[len(elements)-1]
+			switch {
+			case len(f) >= 5 && f[1] == "list":
+				e.properties = append(e.properties, plyProperty{name: f[4], countType: f[2], dataType: f[3]})
+			case len(f) >= 3:
+				e.properties = append(e.properties, plyProperty{name: f[2], dataType: f[1]})
+			default:
+				return "", "", nil, errors.New("invalid ply property line")
+			}
+		case "end_header":
+			return format, unit, elements, nil
+		}
+	}
+}
+
+type plyReader interface {
+	value(dataType string) (float64, error)
+	endElement()
+}
+
+// plyASCII reads one element per line.
+type plyASCII struct {
+	r      *bufio.Reader
+	fields []string
+}
+
+func (p *plyASCII) value(string) (float64, error) {
+	for len(p.fields) == 0 {
+		line, err := p.r.ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			return 0, err
+		}
+		p.fields = strings.Fields(line)
+	}
+	v, err := strconv.ParseFloat(p.fields[0], 64)
+	p.fields = p.fields[1:]
+	return v, err
+}
+
+func (p *plyASCII) endElement() {
+	p.fields = nil
+}
+
+type plyBinary struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (p *plyBinary) value(dataType string) (float64, error) {
+	var size int
+	switch dataType {
+	case "char", "int8", "uchar", "uint8":
+		size = 1
+	case "short", "int16", "ushort", "uint16":
+		size = 2
+	case "int", "int32", "uint", "uint32", "float", "float32":
+		size = 4
+	case "double", "float64":
+		size = 8
+	default:
+		return 0, fmt.Errorf("unsupported ply type %q", dataType)
+	}
+
+	b := p.buf[:size]
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		return 0, err
+	}
+	switch dataType {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(p.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(p.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(p.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(p.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(p.order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(p.order.Uint64(b)), nil
+	}
+}
+
+func (p *plyBinary) endElement() {}
@@ -0,0 +1,110 @@
+package mesh
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+)
+
+// Split modes
+const (
+	SplitAuto       = "auto"       // the parts of the file, or its components when it has one
+	SplitObjects    = "objects"    // the named parts of the file
+	SplitComponents = "components" // the pieces no triangle connects
+)
+
+// maxPieces keeps scans and debris from turning into thousands of files.
+const maxPieces = 256
+
+var (
+	ErrNothingToSplit   = errors.New("model has a single part")
+	ErrTooManyPieces    = fmt.Errorf("model has more than %d parts", maxPieces)
+	ErrUnknownSplitMode = errors.New("unknown split mode")
+)
+
+// Piece is one of the parts a model is split into.
+type Piece struct {
+	Name      string
+	Triangles []*fauxgl.Triangle
+}
+
+// Split breaks a model into its named parts or its connected components.
+func Split(model *Model, mode string) ([]Piece, error) {
+	var pieces []Piece
+	switch mode {
+	case SplitObjects:
+		pieces = objectPieces(model)
+	case SplitComponents:
+		pieces = componentPieces(model.Mesh.Triangles)
+	case SplitAuto, "":
+		if pieces = objectPieces(model); len(pieces) < 2 {
+			pieces = componentPieces(model.Mesh.Triangles)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSplitMode, mode)
+	}
+	if len(pieces) > maxPieces {
+		return nil, ErrTooManyPieces
+	}
+	if len(pieces) < 2 {
+		return nil, ErrNothingToSplit
+	}
+	return pieces, nil
+}
+
+func objectPieces(model *Model) []Piece {
+	var rtn []Piece
+	for _, p := range model.Parts {
+		if p.End > p.Start {
+			rtn = append(rtn, Piece{Name: p.Name, Triangles: model.Mesh.Triangles[p.Start:p.End]})
+		}
+	}
+	return rtn
+}
+
+// componentPieces groups the triangles sharing vertices, in the order their
+// first triangle appears.
+func componentPieces(triangles []*fauxgl.Triangle) []Piece {
+	// Union-find over triangles, joined through the first triangle seen at
+	// each welded vertex
+	parent := make([]int, len(triangles))
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	owners := make(map[vertexKey]int, len(triangles)*3/2)
+	for i, t := range triangles {
+		for _, v := range [3]fauxgl.Vector{t.V1.Position, t.V2.Position, t.V3.Position} {
+			k := weld(v)
+			owner, ok := owners[k]
+			if !ok {
+				owners[k] = i
+				continue
+			}
+			if a, b := find(owner), find(i); a != b {
+				parent[max(a, b)] = min(a, b)
+			}
+		}
+	}
+
+	index := make(map[int]int)
+	var rtn []Piece
+	for i, t := range triangles {
+		root := find(i)
+		n, ok := index[root]
+		if !ok {
+			n = len(rtn)
+			index[root] = n
+			rtn = append(rtn, Piece{Name: fmt.Sprintf("part %d", n+1)})
+		}
+		rtn[n].Triangles = append(rtn[n].Triangles, t)
+	}
+	return rtn
+}
@@ -0,0 +1,111 @@
+package mesh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+)
+
+// loadSTL reads binary and ASCII STL, the solids of ASCII files are its parts.
+func loadSTL(path string) (*Model, error) {
+	m, err := fauxgl.LoadSTL(path)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := stlSolids(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Model{Mesh: m, Parts: parts}, nil
+}
+
+// stlSolids reads the named solids of an ASCII STL, counting vertices the way
+// fauxgl does so the ranges match its triangles. Binary files have none.
+func stlSolids(path string) ([]Part, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var header fauxgl.STLHeader
+	if err := binary.Read(f, binary.LittleEndian, &header); err == nil && info.Size() == int64(header.Count)*50+84 {
+		return nil, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var parts []Part
+	vertices := 0
+	open := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "solid":
+			name := strings.Join(fields[1:], " ")
+			if name == "" {
+				name = fmt.Sprintf("solid %d", len(parts)+1)
+			}
+			parts = append(parts, Part{Name: name, Start: vertices / 3})
+			open = true
+		case "endsolid":
+			if open {
+				parts[len(parts)-1].End = vertices / 3
+				open = false
+			}
+		case "vertex":
+			if len(fields) == 4 {
+				vertices++
+			}
+		}
+	}
+	if open {
+		parts[len(parts)-1].End = vertices / 3
+	}
+	return parts, scanner.Err()
+}
+
+// WriteSTL writes triangles as a binary STL.
+func WriteSTL(w io.Writer, triangles []*fauxgl.Triangle) error {
+	bw := bufio.NewWriter(w)
+	header := fauxgl.STLHeader{Count: uint32(len(triangles))}
+	if err := binary.Write(bw, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	for _, t := range triangles {
+		n := t.Normal()
+		if math.IsNaN(n.X) {
+			// Degenerate triangles have no normal, readers compute their own
+			n = fauxgl.Vector{}
+		}
+		d := fauxgl.STLTriangle{
+			N:  [3]float32{float32(n.X), float32(n.Y), float32(n.Z)},
+			V1: vector32(t.V1.Position),
+			V2: vector32(t.V2.Position),
+			V3: vector32(t.V3.Position),
+		}
+		if err := binary.Write(bw, binary.LittleEndian, &d); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func vector32(v fauxgl.Vector) [3]float32 {
+	return [3]float32{float32(v.X), float32(v.Y), float32(v.Z)}
+}
@@ -0,0 +1,300 @@
+package mesh
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+)
+
+const (
+	defaultModelPath = "3D/3dmodel.model"
+	modelRelType     = "http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"
+	// components nest, deeper ones are taken as a reference cycle
+	maxComponentDepth = 16
+)
+
+// Millimetres per 3MF unit
+var unitScales = map[string]float64{
+	"micron":     0.001,
+	"millimeter": 1,
+	"centimeter": 10,
+	"inch":       25.4,
+	"foot":       304.8,
+	"meter":      1000,
+}
+
+type object3MF struct {
+	name       string
+	vertices   []fauxgl.Vector
+	triangles  [][3]int
+	components []ref3MF
+}
+
+// ref3MF points at an object, build items and components both do. path is set
+// when the object lives in another model file of the package.
+type ref3MF struct {
+	objectID  string
+	path      string
+	transform fauxgl.Matrix
+}
+
+type model3MF struct {
+	unit    string
+	objects map[string]*object3MF
+	items   []ref3MF
+}
+
+// load3MF reads the build of a 3MF package: every item with its components
+// and transforms, in millimetres.
+func load3MF(ctx context.Context, asset *entities.Asset) (*Model, error) {
+	bundle, err := libfs.OpenBundleFS(ctx, *asset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer libfs.Close(bundle)
+	return Read3MF(bundle)
+}
+
+// Read3MF reads the build of an opened 3MF package.
+func Read3MF(fsys fs.FS) (*Model, error) {
+	root := rootModelPath(fsys)
+	models := make(map[string]*model3MF)
+	m, err := openModel(fsys, root, models)
+	if err != nil {
+		return nil, err
+	}
+
+	scale, ok := unitScales[m.unit]
+	if !ok {
+		scale = 1
+	}
+
+	items := m.items
+	if len(items) == 0 {
+		// Packages without a build still have their objects
+		for id := range m.objects {
+			items = append(items, ref3MF{objectID: id, transform: fauxgl.Identity()})
+		}
+	}
+
+	mesh := fauxgl.NewEmptyMesh()
+	model := &Model{Mesh: mesh, Unit: "mm"}
+	for _, item := range items {
+		start := len(mesh.Triangles)
+		if err := addObject(fsys, models, root, item, fauxgl.Scale(fauxgl.V(scale, scale, scale)), mesh, 0); err != nil {
+			return nil, err
+		}
+		// Every build item is a part, named after its object
+		name := "object " + item.objectID
+		if obj := lookupObject(fsys, models, root, item); obj != nil && obj.name != "" {
+			name = obj.name
+		}
+		model.Parts = append(model.Parts, Part{Name: name, Start: start, End: len(mesh.Triangles)})
+	}
+	if len(mesh.Triangles) == 0 {
+		return nil, errors.New("3mf has no mesh")
+	}
+	return model, nil
+}
+
+func addObject(fsys fs.FS, models map[string]*model3MF, modelPath string, ref ref3MF, parent fauxgl.Matrix, mesh *fauxgl.Mesh, depth int) error {
+	if depth > maxComponentDepth {
+		return errors.New("3mf components nest too deep")
+	}
+	if ref.path != "" {
+		modelPath = strings.TrimPrefix(ref.path, "/")
+	}
+	m, err := openModel(fsys, modelPath, models)
+	if err != nil {
+		return err
+	}
+	obj, ok := m.objects[ref.objectID]
+	if !ok {
+		return fmt.Errorf("3mf object %s not found in %s", ref.objectID, modelPath)
+	}
+
+	matrix := parent.Mul(ref.transform)
+	for _, t := range obj.triangles {
+		if !validIndex(t[0], obj) || !validIndex(t[1], obj) || !validIndex(t[2], obj) {
+			continue
+		}
+		mesh.Triangles = append(mesh.Triangles, fauxgl.NewTriangleForPoints(
+			matrix.MulPosition(obj.vertices[t[0]]),
+			matrix.MulPosition(obj.vertices[t[1]]),
+			matrix.MulPosition(obj.vertices[t[2]]),
+		))
+	}
+	for _, c := range obj.components {
+		if err := addObject(fsys, models, modelPath, c, matrix, mesh, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lookupObject(fsys fs.FS, models map[string]*model3MF, modelPath string, ref ref3MF) *object3MF {
+	if ref.path != "" {
+		modelPath = strings.TrimPrefix(ref.path, "/")
+	}
+	m, err := openModel(fsys, modelPath, models)
+	if err != nil {
+		return nil
+	}
+	return m.objects[ref.objectID]
+}
+
+func validIndex(i int, obj *object3MF) bool {
+	return i >= 0 && i < len(obj.vertices)
+}
+
+// rootModelPath finds the model the package relationships point at.
+func rootModelPath(fsys fs.FS) string {
+	f, err := fsys.Open("_rels/.rels")
+	if err != nil {
+		return defaultModelPath
+	}
+	defer f.Close()
+
+	var rels struct {
+		Relationships []struct {
+			Target string `xml:"Target,attr"`
+			Type   string `xml:"Type,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(f).Decode(&rels); err != nil {
+		return defaultModelPath
+	}
+	for _, r := range rels.Relationships {
+		if r.Type == modelRelType {
+			return path.Clean(strings.TrimPrefix(r.Target, "/"))
+		}
+	}
+	return defaultModelPath
+}
+
+func openModel(fsys fs.FS, name string, models map[string]*model3MF) (*model3MF, error) {
+	if m, ok := models[name]; ok {
+		return m, nil
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := parseModel(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	models[name] = m
+	return m, nil
+}
+
+// parseModel walks the model XML token by token, meshes get too large to
+// unmarshal into structs.
+func parseModel(r io.Reader) (*model3MF, error) {
+	m := &model3MF{unit: "millimeter", objects: make(map[string]*object3MF)}
+	var current *object3MF
+
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "model":
+				if u := attr(t, "unit"); u != "" {
+					m.unit = u
+				}
+			case "object":
+				current = &object3MF{name: attr(t, "name")}
+				m.objects[attr(t, "id")] = current
+			case "vertex":
+				if current != nil {
+					current.vertices = append(current.vertices, fauxgl.V(attrFloat(t, "x"), attrFloat(t, "y"), attrFloat(t, "z")))
+				}
+			case "triangle":
+				if current != nil {
+					current.triangles = append(current.triangles, [3]int{attrInt(t, "v1"), attrInt(t, "v2"), attrInt(t, "v3")})
+				}
+			case "component":
+				if current != nil {
+					current.components = append(current.components, newRef(t))
+				}
+			case "item":
+				m.items = append(m.items, newRef(t))
+			}
+		case xml.EndElement:
+			if t.Name.Local == "object" {
+				current = nil
+			}
+		}
+	}
+}
+
+func newRef(t xml.StartElement) ref3MF {
+	return ref3MF{
+		objectID:  attr(t, "objectid"),
+		path:      attr(t, "path"),
+		transform: parseTransform(attr(t, "transform")),
+	}
+}
+
+// parseTransform reads the 3x4 row major matrix of 3MF, points are row
+// vectors there.
+func parseTransform(s string) fauxgl.Matrix {
+	fields := strings.Fields(s)
+	if len(fields) != 12 {
+		return fauxgl.Identity()
+	}
+	var v [12]float64
+	for i, f := range fields {
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return fauxgl.Identity()
+		}
+		v[i] = n
+	}
+	return fauxgl.Matrix{
+		X00: v[0], X01: v[3], X02: v[6], X03: v[9],
+		X10: v[1], X11: v[4], X12: v[7], X13: v[10],
+		X20: v[2], X21: v[5], X22: v[8], X23: v[11],
+		X30: 0, X31: 0, X32: 0, X33: 1,
+	}
+}
+
+func attr(t xml.StartElement, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func attrFloat(t xml.StartElement, name string) float64 {
+	v, _ := strconv.ParseFloat(attr(t, name), 64)
+	return v
+}
+
+func attrInt(t xml.StartElement, name string) int {
+	v, _ := strconv.Atoi(attr(t, name))
+	return v
+}
@@ -2,17 +2,21 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
 	"github.com/eduardooliveira/stLib/core/data/database"
 	"github.com/eduardooliveira/stLib/core/entities"
 	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/utils"
 )
 
 type AssetProcessor interface {
@@ -23,6 +27,35 @@ type RecursiveAssetDiscoverer struct {
 	ctx       context.Context
 	logger    *zap.Logger
 	processor AssetProcessor
+
+	newCount       atomic.Int64
+	changedCount   atomic.Int64
+	unchangedCount atomic.Int64
+	removedCount   atomic.Int64
+	movedCount     atomic.Int64
+	dirsCount      atomic.Int64
+	foundCount     atomic.Int64
+}
+
+// ScanStats counts what a discovery run found compared to the database.
+type ScanStats struct {
+	New       int64 `json:"new"`
+	Changed   int64 `json:"changed"`
+	Unchanged int64 `json:"unchanged"`
+	Removed   int64 `json:"removed"`
+	Moved     int64 `json:"moved"`
+	Dirs      int64 `json:"dirs"`
+	Found     int64 `json:"found"`
+}
+
+func (s *ScanStats) Add(o ScanStats) {
+	s.New += o.New
+	s.Changed += o.Changed
+	s.Unchanged += o.Unchanged
+	s.Removed += o.Removed
+	s.Moved += o.Moved
+	s.Dirs += o.Dirs
+	s.Found += o.Found
 }
 
 func NewAssetDiscoverer(ctx context.Context, logger *zap.Logger, processor AssetProcessor) *RecursiveAssetDiscoverer {
@@ -33,28 +66,224 @@ func NewAssetDiscoverer(ctx context.Context, logger *zap.Logger, processor Asset
 	}
 }
 
+func (d *RecursiveAssetDiscoverer) Stats() ScanStats {
+	return ScanStats{
+		New:       d.newCount.Load(),
+		Changed:   d.changedCount.Load(),
+		Unchanged: d.unchangedCount.Load(),
+		Removed:   d.removedCount.Load(),
+		Moved:     d.movedCount.Load(),
+		Dirs:      d.dirsCount.Load(),
+		Found:     d.foundCount.Load(),
+	}
+}
+
 func (d *RecursiveAssetDiscoverer) DiscoverFS(currFS libfs.LibFS) error {
 	fsName := currFS.GetName()
 
+	// A filesystem pointed at another location keeps its assets when the
+	// paths under it are the same
+	rerooted, err := database.RerootFS(fsName, currFS.GetRoot())
+	if err != nil {
+		d.logger.Warn("failed to reroot assets", zap.Error(err))
+	} else if rerooted > 0 {
+		d.logger.Info("filesystem root changed, kept its assets", zap.String("fs", fsName), zap.String("root", currFS.GetRoot()), zap.Int64("assets", rerooted))
+	}
+
 	// Mark all assets in this filesystem as unseen
 	if err := database.SetDirtyFS(fsName); err != nil {
 		d.logger.Warn("failed to set dirty FS", zap.Error(err))
 	}
 
 	// Discover recursively
-	_, err := d.discoverPath(currFS, ".", nil)
+	_, err = d.discoverPath(currFS, ".", nil)
 	if err != nil {
 		return fmt.Errorf("failed to discover filesystem: %w", err)
 	}
 
 	// Delete unseen assets
-	if err := database.DeleteUnseenInFS(fsName); err != nil {
+	removed, err := database.DeleteUnseenInFS(fsName)
+	if err != nil {
+		d.logger.Warn("failed to delete unseen assets", zap.Error(err))
+	}
+	d.removedCount.Add(removed)
+
+	return nil
+}
+
+// DiscoverSubtree rediscovers everything under an asset of the filesystem,
+// removing what is no longer there.
+func (d *RecursiveAssetDiscoverer) DiscoverSubtree(currFS libfs.LibFS, root *entities.Asset) error {
+	fsName := currFS.GetName()
+
+	var parent *entities.Asset
+	if root.ParentID != nil {
+		p, err := database.GetAsset(*root.ParentID, false)
+		if err != nil {
+			return fmt.Errorf("failed to load parent: %w", err)
+		}
+		parent = &p
+	}
+
+	if err := database.SetDirtySubtree(root.ID, fsName); err != nil {
+		d.logger.Warn("failed to set dirty subtree", zap.Error(err))
+	}
+
+	if _, err := d.discoverPath(currFS, utils.VoZ(root.Path), parent); err != nil {
+		return fmt.Errorf("failed to discover subtree: %w", err)
+	}
+
+	removed, err := database.DeleteUnseenInSubtree(root.ID, fsName)
+	if err != nil {
 		d.logger.Warn("failed to delete unseen assets", zap.Error(err))
 	}
+	d.removedCount.Add(removed)
+
+	return nil
+}
+
+// DiscoverChanges applies a batch of watcher changes to the asset tree without
+// rescanning the whole filesystem. Removals go last, a move seen as a removal
+// and a creation is reconciled before the old path is trashed.
+func (d *RecursiveAssetDiscoverer) DiscoverChanges(currFS libfs.LibFS, changes []libfs.Change) error {
+	var errs []error
+	discovered := make([]string, 0)
+	removed := make([]string, 0)
+
+	for _, c := range changes {
+		switch c.Op {
+		case libfs.ChangeMove:
+			if err := d.moveAsset(currFS, c.OldPath, c.Path); err != nil {
+				d.logger.Warn("failed to move asset, rediscovering", zap.String("from", c.OldPath), zap.String("to", c.Path), zap.Error(err))
+				errs = append(errs, d.discoverChangedPath(currFS, c.Path))
+				removed = append(removed, c.OldPath)
+			}
+		case libfs.ChangeRemove:
+			removed = append(removed, c.Path)
+		default:
+			if isUnder(c.Path, discovered) {
+				continue
+			}
+			if err := d.discoverChangedPath(currFS, c.Path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			discovered = append(discovered, c.Path)
+		}
+	}
+	for _, path := range removed {
+		errs = append(errs, d.removePath(currFS, path))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *RecursiveAssetDiscoverer) discoverChangedPath(currFS libfs.LibFS, path string) error {
+	if _, err := fs.Stat(currFS, path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	// Walk up until an already known ancestor is found, new directories are
+	// discovered together with everything inside them.
+	parent, err := d.findParent(currFS, path)
+	for err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		path = filepath.Dir(path)
+		if path == "." {
+			_, err = d.discoverPath(currFS, ".", nil)
+			return err
+		}
+		parent, err = d.findParent(currFS, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = d.discoverPath(currFS, path, parent)
+	return err
+}
+
+func (d *RecursiveAssetDiscoverer) findParent(currFS libfs.LibFS, path string) (*entities.Asset, error) {
+	locator := entities.LocatorForPath(currFS.GetName(), currFS.GetRoot(), filepath.Dir(path))
+	parent, err := database.GetAssetByLocator(locator)
+	if err != nil {
+		return nil, err
+	}
+	return &parent, nil
+}
 
+func (d *RecursiveAssetDiscoverer) moveAsset(currFS libfs.LibFS, oldPath, newPath string) error {
+	a, err := database.GetAssetByLocator(entities.LocatorForPath(currFS.GetName(), currFS.GetRoot(), oldPath))
+	if err != nil {
+		return err
+	}
+	parent, err := d.ensureDir(currFS, filepath.Dir(newPath))
+	if err != nil {
+		return err
+	}
+	moved, err := database.MoveAsset(a.ID, newPath, &parent.ID)
+	if err != nil {
+		return err
+	}
+	d.logger.Debug("moved asset", zap.String("from", oldPath), zap.String("to", newPath), zap.String("id", moved.ID))
 	return nil
 }
 
+// ensureDir returns the asset for a directory, creating it and any missing
+// ancestors without descending into them.
+func (d *RecursiveAssetDiscoverer) ensureDir(currFS libfs.LibFS, path string) (*entities.Asset, error) {
+	locator := entities.LocatorForPath(currFS.GetName(), currFS.GetRoot(), path)
+	if a, err := database.GetAssetByLocator(locator); err == nil {
+		return &a, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var parent *entities.Asset
+	if path != "." {
+		p, err := d.ensureDir(currFS, filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		parent = p
+	}
+
+	asset := entities.NewAssetWithFS(currFS, currFS.GetName(), currFS.GetRoot(), path, true, parent)
+	seen := true
+	asset.SeenOnScan = &seen
+	if err := database.SaveAsset(asset); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// removePath drops the assets of a path that's gone, their rows are kept in
+// the trash.
+func (d *RecursiveAssetDiscoverer) removePath(currFS libfs.LibFS, path string) error {
+	e := entities.NewTrashEntry(currFS.GetName(), currFS.GetRoot(), path)
+	if err := database.TrashAsset(e); err != nil {
+		return err
+	}
+	if e.AssetID == "" {
+		// Nothing was there anymore, a move was reconciled already
+		return nil
+	}
+	d.removedCount.Add(1)
+	d.logger.Debug("removed asset", zap.String("path", path))
+	return nil
+}
+
+func isUnder(path string, parents []string) bool {
+	for _, p := range parents {
+		if strings.HasPrefix(path, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *RecursiveAssetDiscoverer) discoverPath(currFS libfs.LibFS, path string, parent *entities.Asset) (*entities.Asset, error) {
 	pathInfo, err := fs.Stat(currFS, path)
 	if err != nil {
@@ -63,11 +292,59 @@ func (d *RecursiveAssetDiscoverer) discoverPath(currFS libfs.LibFS, path string,
 
 	isDir := pathInfo.IsDir()
 	isBundle := libfs.IsBundle(path)
+	d.foundCount.Add(1)
+	if isDir {
+		d.dirsCount.Add(1)
+	}
 
 	// Create asset using filesystem
 	asset := entities.NewAssetWithFS(currFS, currFS.GetName(), currFS.GetRoot(), path, isDir, parent)
 	seen := true
 	asset.SeenOnScan = &seen
+	if !isDir {
+		asset.SetFingerprint(pathInfo.Size(), pathInfo.ModTime())
+	}
+
+	existing, err := database.GetAssetByLocator(asset.Locator)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	known := err == nil
+	if !known {
+		// A path never seen before may be an asset that was moved or renamed
+		moved, err := d.findMoved(currFS, asset, parent)
+		if err != nil {
+			d.logger.Warn("failed to look for a moved asset", zap.String("path", path), zap.Error(err))
+		}
+		if moved != nil {
+			existing, known = *moved, true
+		}
+	}
+	if known {
+		asset.ID = existing.ID
+	}
+
+	if known && !isDir && d.unchanged(currFS, asset, &existing) {
+		d.unchangedCount.Add(1)
+		if err := database.MarkAssetSeen(asset.ID); err != nil {
+			d.logger.Warn("failed to mark asset as seen", zap.String("path", path), zap.Error(err))
+		}
+		// Bundle contents are keyed by the bundle itself, an unchanged bundle needs no walk
+		return &existing, nil
+	}
+
+	if !isDir && asset.Hash == nil {
+		d.hash(currFS, asset)
+	}
+
+	if known {
+		keepMetadata(asset, &existing)
+		if !isDir {
+			d.changedCount.Add(1)
+		}
+	} else {
+		d.newCount.Add(1)
+	}
 
 	// Save asset
 	if err := database.SaveAsset(asset); err != nil {
@@ -88,6 +365,7 @@ func (d *RecursiveAssetDiscoverer) discoverPath(currFS libfs.LibFS, path string,
 				d.logger.Warn("failed to create bundle filesystem", zap.String("path", path), zap.Error(err))
 				return asset, nil
 			}
+			defer libfs.Close(bundleFS)
 			innerFS = bundleFS
 			files, err = fs.ReadDir(innerFS, ".")
 			if err != nil {
@@ -103,6 +381,10 @@ func (d *RecursiveAssetDiscoverer) discoverPath(currFS libfs.LibFS, path string,
 		}
 
 		for _, file := range files {
+			// Stop walking once the scan is cancelled, so unseen assets aren't removed
+			if err := d.ctx.Err(); err != nil {
+				return asset, err
+			}
 			if shouldSkipFile(file.Name()) {
 				continue
 			}
@@ -114,6 +396,9 @@ func (d *RecursiveAssetDiscoverer) discoverPath(currFS libfs.LibFS, path string,
 
 			_, err := d.discoverPath(innerFS, childPath, asset)
 			if err != nil {
+				if d.ctx.Err() != nil {
+					return asset, d.ctx.Err()
+				}
 				d.logger.Warn("failed to discover child", zap.String("path", childPath), zap.Error(err))
 				continue
 			}
@@ -126,6 +411,140 @@ func (d *RecursiveAssetDiscoverer) discoverPath(currFS libfs.LibFS, path string,
 	return asset, nil
 }
 
+// findMoved looks for an asset whose path is gone and that is the same file,
+// or a directory with the same name holding mostly the same entries. The one
+// found is moved to the new path, keeping its ID and metadata.
+func (d *RecursiveAssetDiscoverer) findMoved(currFS libfs.LibFS, asset *entities.Asset, parent *entities.Asset) (*entities.Asset, error) {
+	path := utils.VoZ(asset.Path)
+	if path == "." {
+		return nil, nil
+	}
+
+	var candidates []*entities.Asset
+	var err error
+	if asset.NodeKind == entities.NodeKindDir || asset.NodeKind == entities.NodeKindRoot {
+		candidates, err = d.movedDirCandidates(currFS, path)
+	} else {
+		d.hash(currFS, asset)
+		candidates, err = database.GetFilesWithSize(currFS.GetName(), asset.Size)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		if c.Root != currFS.GetRoot() || c.Path == nil {
+			continue
+		}
+		if c.NodeKind != entities.NodeKindDir && c.NodeKind != entities.NodeKindRoot && !asset.SameFingerprint(c) {
+			continue
+		}
+		if _, err := fs.Stat(currFS, *c.Path); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		var parentID *string
+		if parent != nil {
+			parentID = &parent.ID
+		}
+		moved, err := database.MoveAssetTo(c.ID, currFS.GetName(), currFS.GetRoot(), path, parentID)
+		if err != nil {
+			return nil, err
+		}
+		d.movedCount.Add(1)
+		d.logger.Debug("reconciled moved asset", zap.String("from", *c.Path), zap.String("to", path), zap.String("id", c.ID))
+		return moved, nil
+	}
+	return nil, nil
+}
+
+// movedDirCandidates returns the directories named like path where at least
+// half of the children known are found in path.
+func (d *RecursiveAssetDiscoverer) movedDirCandidates(currFS libfs.LibFS, path string) ([]*entities.Asset, error) {
+	dirs, err := database.GetDirsNamed(currFS.GetName(), filepath.Base(path))
+	if err != nil || len(dirs) == 0 {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(currFS, path)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+
+	rtn := make([]*entities.Asset, 0, len(dirs))
+	for _, dir := range dirs {
+		children, err := database.GetChildNames(dir.ID, currFS.GetName())
+		if err != nil {
+			return nil, err
+		}
+		found := 0
+		for _, c := range children {
+			if names[c] {
+				found++
+			}
+		}
+		if len(children) > 0 && found*2 >= len(children) {
+			rtn = append(rtn, dir)
+		}
+	}
+	return rtn, nil
+}
+
+// unchanged compares the fingerprint of a file with the stored one. With
+// library.hash_files enabled, files whose size or modification time moved are
+// hashed so touched but identical files are still skipped.
+func (d *RecursiveAssetDiscoverer) unchanged(currFS libfs.LibFS, asset *entities.Asset, existing *entities.Asset) bool {
+	if asset.SameFingerprint(existing) {
+		return true
+	}
+	d.hash(currFS, asset)
+	if asset.Hash != nil && existing.Hash != nil && *existing.Hash == *asset.Hash {
+		// Same content, only refresh the stored size and modification time
+		existing.Size, existing.ModTime = asset.Size, asset.ModTime
+		if err := database.UpdateAssetFingerprint(existing); err != nil {
+			d.logger.Warn("failed to update asset fingerprint", zap.String("path", utils.VoZ(asset.Path)), zap.Error(err))
+		}
+		return true
+	}
+	return false
+}
+
+// hash stores the SHA-1 of a file when library.hash_files is enabled.
+func (d *RecursiveAssetDiscoverer) hash(currFS libfs.LibFS, asset *entities.Asset) {
+	if !runtime.Cfg.Library.HashFiles || asset.NodeKind == entities.NodeKindBundled {
+		return
+	}
+
+	f, err := currFS.Open(utils.VoZ(asset.Path))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	hash, err := utils.GetReaderSha1(f)
+	if err != nil {
+		d.logger.Warn("failed to hash asset", zap.String("path", utils.VoZ(asset.Path)), zap.Error(err))
+		return
+	}
+	asset.Hash = &hash
+}
+
+// keepMetadata carries over what users and processing stored on an already
+// known asset, so rediscovering a path doesn't reset it.
+func keepMetadata(asset *entities.Asset, existing *entities.Asset) {
+	asset.Label = existing.Label
+	asset.Description = existing.Description
+	asset.Thumbnail = existing.Thumbnail
+	asset.CreatedAt = existing.CreatedAt
+	if existing.Properties != nil {
+		asset.Properties = existing.Properties
+	}
+}
+
 func shouldSkipFile(name string) bool {
 	if strings.HasPrefix(name, ".") {
 		if runtime.Cfg.Library.IgnoreDotFiles {
@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+)
+
+type noopProcessor struct{}
+
+func (noopProcessor) Process(context.Context, *entities.Asset) {}
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "data.db")), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entities.Tag{}, &entities.Asset{}, &entities.TrashEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = prev
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func writeTestFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	p := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func discover(t *testing.T, f libfs.LibFS) {
+	t.Helper()
+	d := NewAssetDiscoverer(context.Background(), zap.NewNop(), noopProcessor{})
+	if err := d.DiscoverFS(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assetAt(t *testing.T, f libfs.LibFS, path string) entities.Asset {
+	t.Helper()
+	a, err := database.GetAssetByLocator(entities.LocatorForPath(f.GetName(), f.GetRoot(), path))
+	if err != nil {
+		t.Fatalf("no asset at %s under %s: %v", path, f.GetRoot(), err)
+	}
+	return a
+}
+
+func TestDiscoverFSKeepsAssetsWhenRootChanges(t *testing.T) {
+	openTestDB(t)
+	base := t.TempDir()
+	oldRoot, newRoot := filepath.Join(base, "old"), filepath.Join(base, "new")
+	writeTestFile(t, oldRoot, "models/benchy.stl", "solid benchy")
+	writeTestFile(t, oldRoot, "cube.stl", "solid cube")
+
+	before := libfs.NewLocalFS("library", oldRoot)
+	discover(t, before)
+	benchy, cube, dir := assetAt(t, before, "models/benchy.stl"), assetAt(t, before, "cube.stl"), assetAt(t, before, "models")
+	if err := database.DB.Model(&benchy).Association("Tags").Append(&entities.Tag{Value: "boat"}); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	database.DB.Model(&entities.Asset{}).Count(&count)
+
+	if err := os.Rename(oldRoot, newRoot); err != nil {
+		t.Fatal(err)
+	}
+	after := libfs.NewLocalFS("library", newRoot)
+	discover(t, after)
+
+	for path, want := range map[string]entities.Asset{"models/benchy.stl": benchy, "cube.stl": cube, "models": dir} {
+		got := assetAt(t, after, path)
+		if got.ID != want.ID {
+			t.Errorf("%s got ID %s, want %s", path, got.ID, want.ID)
+		}
+		if got.Root != newRoot {
+			t.Errorf("%s has root %s, want %s", path, got.Root, newRoot)
+		}
+	}
+	got := assetAt(t, after, "models/benchy.stl")
+	if len(got.Tags) != 1 || got.Tags[0].Value != "boat" {
+		t.Errorf("tags after the root changed = %v, want boat", got.Tags)
+	}
+	var total int64
+	database.DB.Model(&entities.Asset{}).Count(&total)
+	if total != count {
+		t.Errorf("%d assets after the root changed, want %d", total, count)
+	}
+}
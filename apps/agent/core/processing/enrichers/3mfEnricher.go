@@ -15,9 +15,12 @@ import (
 	"github.com/eduardooliveira/stLib/core/entities"
 	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/slicer"
 	"github.com/eduardooliveira/stLib/core/utils"
 )
 
+// mfEnricher extracts the images of 3MF bundles and reads the slicer settings
+// and plates of the projects.
 type mfEnricher struct{}
 
 var imageExts = []string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".webp", ".svg"}
@@ -36,10 +39,11 @@ func (m *mfEnricher) Enrich(ctx context.Context, asset *entities.Asset) error {
 		return fmt.Errorf("failed to get generated fs: %w", err)
 	}
 
-	bundleFS, err := libfs.GetAssetFS(ctx, *asset)
+	bundleFS, err := libfs.OpenBundleFS(ctx, *asset)
 	if err != nil {
 		return fmt.Errorf("failed to open bundle: %w", err)
 	}
+	defer libfs.Close(bundleFS)
 
 	extractedCount := 0
 	err = fs.WalkDir(bundleFS.GetFS(), ".", func(path string, d fs.DirEntry, err error) error {
@@ -101,5 +105,32 @@ func (m *mfEnricher) Enrich(ctx context.Context, asset *entities.Asset) error {
 		logger.GetLogger().Info("extracted images from 3MF", zap.String("asset", asset.ID), zap.Int("count", extractedCount))
 	}
 
+	if asset.Properties == nil {
+		asset.Properties = make(entities.Properties)
+	}
+	meta, err := slicer.From3MF(bundleFS.GetFS())
+	if err != nil {
+		return fmt.Errorf("failed to read slicer settings: %w", err)
+	}
+	if meta != nil {
+		asset.Properties["slice"] = meta
+	}
+
+	// Plates point to their thumbnails and G-code as the bundled assets
+	// discovery made of them
+	plates, err := slicer.Plates3MF(bundleFS.GetFS(), func(path string) string {
+		a, err := database.GetAssetByLocator(entities.LocatorForPath(bundleFS.GetName(), bundleFS.GetRoot(), path))
+		if err != nil {
+			return ""
+		}
+		return a.ID
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read plates: %w", err)
+	}
+	if len(plates) > 0 {
+		asset.Properties["plates"] = plates
+	}
+
 	return nil
 }
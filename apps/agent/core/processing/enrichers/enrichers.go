@@ -11,18 +11,29 @@ type Enricher interface {
 	Enrich(ctx context.Context, asset *entities.Asset) error
 }
 
-var enrichers = map[string]Enricher{}
+// Several enrichers may apply to an extension, they run in registration order
+var enrichers = map[string][]Enricher{}
+
+func Register(ext string, e Enricher) {
+	enrichers[ext] = append(enrichers[ext], e)
+}
 
 func Init() error {
-	enrichers[".gcode"] = &gCodeEnricher{}
-	enrichers[".3mf"] = &mfEnricher{}
+	geometry := &geometryEnricher{}
+	Register(".gcode", &gCodeEnricher{})
+	Register(".gcode", &gCodeAnalysisEnricher{})
+	Register(".3mf", &mfEnricher{})
+	Register(".3mf", geometry)
+	Register(".stl", geometry)
+	Register(".obj", geometry)
+	Register(".ply", geometry)
 	return nil
 }
 
-func Get(asset *entities.Asset) (Enricher, bool) {
+func Get(asset *entities.Asset) ([]Enricher, bool) {
 	if asset.Extension == nil {
 		return nil, false
 	}
-	enricher, ok := enrichers[strings.ToLower(*asset.Extension)]
-	return enricher, ok
+	e, ok := enrichers[strings.ToLower(*asset.Extension)]
+	return e, ok
 }
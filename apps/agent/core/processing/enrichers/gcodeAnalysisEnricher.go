@@ -0,0 +1,69 @@
+package enrichers
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/gcode"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+)
+
+// gCodeAnalysisEnricher stores what the moves of a G-code file tell under the
+// "gcode" property, the keys are the same whichever slicer wrote it.
+type gCodeAnalysisEnricher struct{}
+
+func (g *gCodeAnalysisEnricher) Enrich(ctx context.Context, asset *entities.Asset) error {
+	f, err := libfs.OpenAsset(ctx, *asset)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	a, err := gcode.Analyze(ctx, f)
+	if err != nil {
+		return fmt.Errorf("failed to analyze gcode: %w", err)
+	}
+
+	filament := make([]map[string]any, 0, len(a.Filament))
+	for _, u := range a.Filament {
+		filament = append(filament, map[string]any{
+			"tool":   u.Tool,
+			"length": round(u.Length),
+			"mass":   round(u.Mass),
+		})
+	}
+
+	if asset.Properties == nil {
+		asset.Properties = make(entities.Properties)
+	}
+	asset.Properties["gcode"] = map[string]any{
+		"layers":             a.Layers,
+		"first_layer_height": round(a.FirstLayerHeight),
+		"layer_height":       round(a.LayerHeight),
+		"min_x":              round(a.Min.X),
+		"min_y":              round(a.Min.Y),
+		"min_z":              round(a.Min.Z),
+		"max_x":              round(a.Max.X),
+		"max_y":              round(a.Max.Y),
+		"max_z":              round(a.Max.Z),
+		"size_x":             round(a.Max.X - a.Min.X),
+		"size_y":             round(a.Max.Y - a.Min.Y),
+		"size_z":             round(a.Max.Z - a.Min.Z),
+		"filament":           filament,
+		"filament_length":    round(a.FilamentLength()),
+		"filament_mass":      round(a.FilamentMass()),
+		"toolchanges":        a.Toolchanges,
+		"max_nozzle_temp":    a.MaxNozzleTemp,
+		"max_bed_temp":       a.MaxBedTemp,
+		"max_chamber_temp":   a.MaxChamberTemp,
+		"estimated_time":     math.Round(a.EstimatedTime),
+	}
+
+	logger.GetLogger().Debug("analyzed gcode", zap.String("asset", asset.ID), zap.Int("layers", a.Layers))
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"context"
 	"errors"
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -13,7 +12,7 @@ import (
 	"github.com/eduardooliveira/stLib/core/entities"
 	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/logger"
-	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/eduardooliveira/stLib/core/slicer"
 )
 
 type gCodeEnricher struct {
@@ -24,23 +23,20 @@ func (g *gCodeEnricher) Enrich(ctx context.Context, asset *entities.Asset) error
 		asset.Properties = make(entities.Properties)
 	}
 
-	fs, err := libfs.GetAssetFS(ctx, *asset)
-	if err != nil {
-		return fmt.Errorf("error getting fs: %w", err)
-	}
-
-	f, err := fs.Open(utils.VoZ(asset.Path))
+	f, err := libfs.OpenAsset(ctx, *asset)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	p := slicer.NewParser()
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), ";") {
-			line := strings.Trim(scanner.Text(), " ;")
+		if comment, ok := strings.CutPrefix(strings.TrimLeft(scanner.Text(), " \t"), ";"); ok {
+			line := strings.Trim(comment, " ;")
 
 			if !strings.HasPrefix(line, "thumbnail begin") {
 				parseComment(asset, line)
+				p.Comment(comment)
 			}
 
 		}
@@ -50,6 +46,14 @@ func (g *gCodeEnricher) Enrich(ctx context.Context, asset *entities.Asset) error
 		return errors.Join(err, errors.New("error reading gcode"))
 	}
 
+	m, err := p.Metadata()
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		asset.Properties["slice"] = m
+	}
+
 	logger.GetLogger().Debug("enriched gcode", zap.String("asset", asset.ID))
 	return nil
 }
@@ -0,0 +1,55 @@
+package enrichers
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/mesh"
+)
+
+// geometryEnricher stores the measurements of a model under the "geometry"
+// property, searchable as geometry.size_z and the like. Lengths are in mm when
+// the file declares its unit, kept as unit, and in model units otherwise with
+// the unit they look like kept as unit_guess.
+type geometryEnricher struct{}
+
+func (g *geometryEnricher) Enrich(ctx context.Context, asset *entities.Asset) error {
+	model, err := mesh.Load(ctx, asset)
+	if err != nil {
+		return fmt.Errorf("failed to load mesh: %w", err)
+	}
+	m := mesh.Analyze(model)
+
+	if asset.Properties == nil {
+		asset.Properties = make(entities.Properties)
+	}
+	geometry := map[string]any{
+		"size_x":       round(m.SizeX),
+		"size_y":       round(m.SizeY),
+		"size_z":       round(m.SizeZ),
+		"volume":       round(m.Volume),
+		"surface_area": round(m.SurfaceArea),
+		"triangles":    m.Triangles,
+		"watertight":   m.Watertight,
+		"manifold":     m.Manifold,
+	}
+	if m.Unit != "" {
+		geometry["unit"] = m.Unit
+	}
+	if m.GuessedUnit != "" {
+		geometry["unit_guess"] = m.GuessedUnit
+	}
+	asset.Properties["geometry"] = geometry
+
+	logger.GetLogger().Debug("enriched geometry", zap.String("asset", asset.ID), zap.Int("triangles", m.Triangles))
+	return nil
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -3,10 +3,8 @@ package enrichment
 import (
 	"bufio"
 	"bytes"
-	"crypto/md5"
 	"crypto/sha1"
 	"encoding/base64"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"image"
@@ -26,6 +24,7 @@ import (
 	"github.com/eduardooliveira/stLib/core/runtime"
 	"github.com/eduardooliveira/stLib/core/system"
 	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/google/uuid"
 )
 
 type gcodeRenderer struct{}
@@ -121,24 +120,24 @@ func (g *gcodeRenderer) createRenderedImageAsset(job types.ProcessableAsset, ren
 	// Path format matches migration: projectPath/assetName relative to library root
 	projectPath := filepath.Join(job.Project.Path, job.Project.Name)
 	assetPath := filepath.Join(projectPath, job.Asset.Name)
-	modelAssetID := calculateAssetID("default", runtime.Cfg.Library.Path, assetPath)
+	modelLocator := entities.LocatorForPath("default", runtime.Cfg.Library.Path, assetPath)
 
-	modelAsset, err := database.GetAsset(modelAssetID, false)
+	modelAsset, err := database.GetAssetByLocator(modelLocator)
 	if err != nil {
-		logger.GetLogger().Debug("model asset not found, skipping thumbnail link", zap.String("model_id", modelAssetID), zap.Error(err))
+		logger.GetLogger().Debug("model asset not found, skipping thumbnail link", zap.String("model_locator", modelLocator), zap.Error(err))
 		return nil
 	}
 
 	// Calculate Asset ID for rendered image
 	renderAssetPath := filepath.Join("_assets", job.Project.UUID, renderName)
-	renderAssetID := calculateAssetID("default", runtime.Cfg.Library.Path, renderAssetPath)
+	renderLocator := entities.LocatorForPath("default", runtime.Cfg.Library.Path, renderAssetPath)
 
 	// Check if rendered image asset already exists
-	_, err = database.GetAsset(renderAssetID, false)
+	existing, err := database.GetAssetByLocator(renderLocator)
 	if err == nil {
 		// Asset exists, just link it
 		if modelAsset.Thumbnail == nil {
-			modelAsset.Thumbnail = &renderAssetID
+			modelAsset.Thumbnail = &existing.ID
 			return database.SaveAsset(&modelAsset)
 		}
 		return nil
@@ -150,7 +149,8 @@ func (g *gcodeRenderer) createRenderedImageAsset(job types.ProcessableAsset, ren
 	kind := "image"
 
 	renderAsset := &entities.Asset{
-		ID:         renderAssetID,
+		ID:         uuid.New().String(),
+		Locator:    renderLocator,
 		Label:      &label,
 		Path:       &renderAssetPath,
 		Root:       runtime.Cfg.Library.Path,
@@ -172,19 +172,13 @@ func (g *gcodeRenderer) createRenderedImageAsset(job types.ProcessableAsset, ren
 
 	// Link to model
 	if modelAsset.Thumbnail == nil {
-		modelAsset.Thumbnail = &renderAssetID
+		modelAsset.Thumbnail = &renderAsset.ID
 		return database.SaveAsset(&modelAsset)
 	}
 
 	return nil
 }
 
-func calculateAssetID(fsName, root, path string) string {
-	data := []byte(filepath.Join(fsName, root, path))
-	md5Hash := md5.Sum(data)
-	return hex.EncodeToString(md5Hash[:])
-}
-
 func (g *gcodeRenderer) parseThumbnail(scanner *bufio.Scanner, size string, length int) (*tmpImg, error) {
 	sb := strings.Builder{}
 	for scanner.Scan() {
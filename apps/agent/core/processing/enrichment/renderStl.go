@@ -18,6 +18,7 @@ import (
 	"github.com/eduardooliveira/stLib/core/runtime"
 	"github.com/eduardooliveira/stLib/core/system"
 	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/google/uuid"
 	"github.com/nfnt/resize"
 )
 
@@ -112,24 +113,24 @@ func (s *stlRenderer) Render(job types.ProcessableAsset) (string, error) {
 func (s *stlRenderer) createRenderedImageAsset(job types.ProcessableAsset, renderName, renderPath string) error {
 	projectPath := filepath.Join(job.Project.Path, job.Project.Name)
 	assetPath := filepath.Join(projectPath, job.Asset.Name)
-	modelAssetID := calculateAssetID("default", runtime.Cfg.Library.Path, assetPath)
+	modelLocator := entities.LocatorForPath("default", runtime.Cfg.Library.Path, assetPath)
 
-	modelAsset, err := database.GetAsset(modelAssetID, false)
+	modelAsset, err := database.GetAssetByLocator(modelLocator)
 	if err != nil {
-		logger.GetLogger().Debug("model asset not found, skipping thumbnail link", zap.String("model_id", modelAssetID), zap.Error(err))
+		logger.GetLogger().Debug("model asset not found, skipping thumbnail link", zap.String("model_locator", modelLocator), zap.Error(err))
 		return nil
 	}
 
 	// Calculate Asset ID for rendered image
 	// Rendered images are stored in assets folder, use a special path format
 	renderAssetPath := filepath.Join("_assets", job.Project.UUID, renderName)
-	renderAssetID := calculateAssetID("default", runtime.Cfg.Library.Path, renderAssetPath)
+	renderLocator := entities.LocatorForPath("default", runtime.Cfg.Library.Path, renderAssetPath)
 
-	_, err = database.GetAsset(renderAssetID, false)
+	existing, err := database.GetAssetByLocator(renderLocator)
 	if err == nil {
 		// Asset exists, just link it
 		if modelAsset.Thumbnail == nil {
-			modelAsset.Thumbnail = &renderAssetID
+			modelAsset.Thumbnail = &existing.ID
 			return database.SaveAsset(&modelAsset)
 		}
 		return nil
@@ -141,7 +142,8 @@ func (s *stlRenderer) createRenderedImageAsset(job types.ProcessableAsset, rende
 	kind := "image"
 
 	renderAsset := &entities.Asset{
-		ID:         renderAssetID,
+		ID:         uuid.New().String(),
+		Locator:    renderLocator,
 		Label:      &label,
 		Path:       &renderAssetPath,
 		Root:       runtime.Cfg.Library.Path,
@@ -164,7 +166,7 @@ func (s *stlRenderer) createRenderedImageAsset(job types.ProcessableAsset, rende
 
 	// Link to model
 	if modelAsset.Thumbnail == nil {
-		modelAsset.Thumbnail = &renderAssetID
+		modelAsset.Thumbnail = &renderAsset.ID
 		return database.SaveAsset(&modelAsset)
 	}
 
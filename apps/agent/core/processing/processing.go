@@ -2,6 +2,7 @@ package processing
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,7 +11,6 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/eduardooliveira/stLib/core/entities"
-	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/processing/discovery"
 	"github.com/eduardooliveira/stLib/core/runtime"
 )
@@ -23,42 +23,86 @@ func (pw *ProcessorWrapper) Process(ctx context.Context, asset *entities.Asset)
 	pw.p.Process(ctx, asset)
 }
 
-func ScanFS(ctx context.Context, logger *zap.Logger) error {
+// ScanFS discovers every filesystem and waits for it to finish.
+func ScanFS(ctx context.Context, logger *zap.Logger) (discovery.ScanStats, error) {
+	s, err := newScan(ctx, ScanScope{})
+	if err != nil {
+		return discovery.ScanStats{}, err
+	}
+	return s.run(logger)
+}
+
+func (s *Scan) run(logger *zap.Logger) (discovery.ScanStats, error) {
+	defer s.release()
+
 	tempPath := filepath.Clean(filepath.Join(runtime.GetDataPath(), "assets"))
 	if _, err := os.Stat(tempPath); os.IsNotExist(err) {
 		err := os.MkdirAll(tempPath, os.ModePerm)
 		if err != nil {
-			return fmt.Errorf("failed to create assets directory: %w", err)
+			return discovery.ScanStats{}, fmt.Errorf("failed to create assets directory: %w", err)
 		}
 	}
 
-	proc, err := NewProcessor()
+	proc, err := NewProcessor(s.ctx)
 	if err != nil {
-		return fmt.Errorf("failed to initialize processor: %w", err)
+		return discovery.ScanStats{}, fmt.Errorf("failed to initialize processor: %w", err)
 	}
 
-	discoverer := discovery.NewAssetDiscoverer(ctx, logger, &ProcessorWrapper{p: proc})
+	// The reporter outlives the scan to follow the jobs it queued
+	reporter := newScanReporter(s.ID)
+	proc.onEnqueue = reporter.enqueued
+	go reporter.run()
 
-	eg, _ := errgroup.WithContext(ctx)
-	for _, ffs := range libfs.GetFSs() {
-		f := ffs
+	// One discoverer per filesystem so progress can be reported for each of them
+	discoverers := make([]*discovery.RecursiveAssetDiscoverer, 0)
+	eg, _ := errgroup.WithContext(s.ctx)
+	for _, t := range s.targets {
+		t := t
+		discoverer := discovery.NewAssetDiscoverer(s.ctx, logger, &ProcessorWrapper{p: proc})
+		discoverers = append(discoverers, discoverer)
+		progress := reporter.track(t.fs.GetName(), discoverer)
 		eg.Go(func() error {
-			if err := discoverer.DiscoverFS(f); err != nil {
-				logger.Error("failed to discover assets", zap.String("fs", f.GetName()), zap.Error(err))
-				return err
+			unlock := lockFS(t.fs.GetName())
+			defer unlock()
+			var err error
+			if t.root != nil {
+				err = discoverer.DiscoverSubtree(t.fs, t.root)
+			} else {
+				err = discoverer.DiscoverFS(t.fs)
 			}
-			return nil
+			reporter.walked(progress, err)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("failed to discover assets", zap.String("fs", t.fs.GetName()), zap.Error(err))
+			}
+			return err
 		})
 	}
 
-	if err := eg.Wait(); err != nil {
-		return fmt.Errorf("discovery cycle finished with errors: %w", err)
+	err = eg.Wait()
+	if err == nil {
+		err = s.ctx.Err()
 	}
+	reporter.finish(err)
 
-	if err := proc.Wait(); err != nil {
-		logger.Error("processing errors", zap.Error(err))
+	stats := discovery.ScanStats{}
+	for _, d := range discoverers {
+		stats.Add(d.Stats())
+	}
+	if errors.Is(err, context.Canceled) {
+		logger.Info("asset discovery cancelled", zap.String("scan_id", s.ID))
+		return stats, err
+	}
+	if err != nil {
+		return stats, fmt.Errorf("discovery cycle finished with errors: %w", err)
 	}
 
-	logger.Info("asset discovery finished")
-	return nil
+	logger.Info("asset discovery finished",
+		zap.String("scan_id", s.ID),
+		zap.Int64("new", stats.New),
+		zap.Int64("changed", stats.Changed),
+		zap.Int64("unchanged", stats.Unchanged),
+		zap.Int64("removed", stats.Removed),
+		zap.Int64("moved", stats.Moved),
+	)
+	return stats, nil
 }
@@ -2,125 +2,202 @@ package processing
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
 
 	"github.com/eduardooliveira/stLib/core/data/database"
 	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
 	"github.com/eduardooliveira/stLib/core/logger"
 	"github.com/eduardooliveira/stLib/core/processing/enrichers"
 	"github.com/eduardooliveira/stLib/core/processing/renderers"
+	"github.com/eduardooliveira/stLib/core/queue"
 	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/utils"
 )
 
+// Init registers renderers, enrichers and the job handlers that run them.
+func Init() {
+	renderers.Init()
+	enrichers.Init()
+
+	queue.RegisterHandler(entities.JobKindRender, renderJob)
+	queue.RegisterHandler(entities.JobKindEnrich, enrichJob)
+}
+
+// Start runs the persistent job queue until the context is cancelled.
+func Start(ctx context.Context) error {
+	if err := RefreshRenders(); err != nil {
+		logger.GetLogger().Error("failed to refresh renders", zap.Error(err))
+	}
+	return queue.Start(ctx, runtime.Cfg.Render.MaxWorkers)
+}
+
+// RefreshRenders queues renders for the assets rendered with presets that
+// changed since, assets whose renders are current are left alone.
+func RefreshRenders() error {
+	genFS, err := libfs.GetLibFS("generated")
+	if err != nil {
+		return err
+	}
+	ids, err := database.GetOutdatedRenderParents(genFS.GetName(), renderers.PresetFingerprints())
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := queue.Enqueue(entities.JobKindRender, id); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		logger.GetLogger().Info("render presets changed", zap.Int("assets", len(ids)))
+	}
+	return nil
+}
+
 type Processor struct {
-	eg *errgroup.Group
+	ctx context.Context
+	// onEnqueue is told about every job queued, scans follow their own jobs
+	// with it
+	onEnqueue func(id, kind string)
 }
 
-func NewProcessor() (*Processor, error) {
-	eg := &errgroup.Group{}
-	eg.SetLimit(10)
-	renderers.Init()
-	enrichers.Init()
-	return &Processor{
-		eg: eg,
-	}, nil
+func NewProcessor(ctx context.Context) (*Processor, error) {
+	return &Processor{ctx: ctx}, nil
 }
 
+// Wait blocks until the job queue is drained.
 func (p *Processor) Wait() error {
-	return p.eg.Wait()
+	return queue.WaitIdle(p.ctx)
 }
 
-func (p *Processor) Process(ctx context.Context, asset *entities.Asset) *Process {
-	proc := &Process{
-		ctx:   ctx,
-		p:     p,
-		Asset: asset,
-		done:  make(chan error),
+// Process queues the render and enrich jobs that apply to the asset.
+func (p *Processor) Process(ctx context.Context, asset *entities.Asset) {
+	l := logger.GetLogger().With(zap.String("module", "process"), zap.String("asset", assetLabel(asset)))
+
+	if _, ok := renderers.Get(asset); ok {
+		p.enqueue(l, entities.JobKindRender, asset.ID)
 	}
-	// TODO: Handle bundle rendering config
-	if asset.NodeKind == entities.NodeKindBundle && !runtime.Cfg.Library.RenderBundles {
-		proc.renderState = "skipped"
-	} else if r, ok := renderers.Get(asset); ok {
-		proc.renderer = r
-		proc.renderState = "pending"
-	} else {
-		proc.renderState = "skipped"
+
+	if _, ok := enrichers.Get(asset); ok {
+		p.enqueue(l, entities.JobKindEnrich, asset.ID)
 	}
+}
 
-	if r, ok := enrichers.Get(asset); ok {
-		proc.enricher = r
-		proc.enrichState = "pending"
-	} else {
-		proc.enrichState = "skipped"
+func (p *Processor) enqueue(l *zap.Logger, kind, assetID string) {
+	id, err := queue.Enqueue(kind, assetID)
+	if err != nil {
+		l.Error("failed to enqueue job", zap.String("job_kind", kind), zap.Error(err))
+		return
 	}
+	if p.onEnqueue != nil {
+		p.onEnqueue(id, kind)
+	}
+}
 
-	p.eg.Go(proc.Run)
+func renderJob(ctx context.Context, job *entities.Job) error {
+	asset, err := loadJobAsset(job)
+	if err != nil || asset == nil {
+		return err
+	}
 
-	return proc
-}
+	r, ok := renderers.Get(asset)
+	if !ok {
+		return nil
+	}
 
-type Process struct {
-	ctx         context.Context
-	p           *Processor
-	done        chan error
-	Asset       *entities.Asset
-	renderer    renderers.Renderer
-	renderState string
-	renderError error
-	enricher    enrichers.Enricher
-	enrichState string
-	enrichError error
+	imgs, err := r.Render(ctx, asset)
+	if err != nil {
+		return fmt.Errorf("failed to render asset: %w", err)
+	}
+	kept := make(map[string]bool, len(imgs))
+	for _, img := range imgs {
+		if err := database.SaveAsset(img); err != nil {
+			return fmt.Errorf("failed to save render: %w", err)
+		}
+		kept[img.ID] = true
+	}
+	if len(imgs) > 0 {
+		if err := database.UpdateAssetThumbnail(asset, imgs[0].ID); err != nil {
+			return fmt.Errorf("failed to save asset: %w", err)
+		}
+	}
+	return removeStaleRenders(asset, kept)
 }
 
-func (p *Process) Wait() error {
-	return <-p.done
+// removeStaleRenders deletes the views of render presets that aren't
+// configured anymore.
+func removeStaleRenders(asset *entities.Asset, kept map[string]bool) error {
+	genFS, err := libfs.GetLibFS("generated")
+	if err != nil {
+		return err
+	}
+	children, err := database.GetChildrenInFS(asset.ID, genFS.GetName())
+	if err != nil {
+		return fmt.Errorf("failed to list renders: %w", err)
+	}
+	for _, c := range children {
+		if _, ok := c.Properties["render"]; !ok || kept[c.ID] {
+			continue
+		}
+		if err := genFS.Remove(utils.VoZ(c.Path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove render: %w", err)
+		}
+		if err := database.DeleteAsset(c.ID); err != nil {
+			return fmt.Errorf("failed to delete render: %w", err)
+		}
+	}
+	return nil
 }
 
-func (p *Process) Run() error {
-	defer close(p.done)
-	l := logger.GetLogger().With(zap.String("module", "process"), zap.String("asset", assetLabel(p.Asset)))
-
-	if p.renderer != nil {
-		if img, err := p.renderer.Render(p.ctx, p.Asset); err != nil {
-			p.renderError = err
-			p.renderState = "failed"
-			l.Error("failed to render asset", zap.Error(err))
-		} else {
-			p.renderState = "done"
-			if err := database.SaveAsset(img); err != nil {
-				l.Error("failed to save render", zap.Error(err))
-			}
-			p.Asset.Thumbnail = &img.ID
-		}
+func enrichJob(ctx context.Context, job *entities.Job) error {
+	asset, err := loadJobAsset(job)
+	if err != nil || asset == nil {
+		return err
 	}
 
-	if p.enricher != nil {
-		if err := p.enricher.Enrich(p.ctx, p.Asset); err != nil {
-			p.enrichError = err
-			p.enrichState = "failed"
-			l.Error("failed to enrich asset", zap.Error(err))
-		} else {
-			p.enrichState = "done"
+	es, ok := enrichers.Get(asset)
+	if !ok {
+		return nil
+	}
+
+	// What the other enrichers found is kept when one of them fails
+	var errs []error
+	for _, e := range es {
+		if err := e.Enrich(ctx, asset); err != nil {
+			errs = append(errs, fmt.Errorf("failed to enrich asset: %w", err))
+		}
+	}
+	if len(errs) < len(es) {
+		if err := database.UpdateAssetProperties(asset, asset.Properties); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save asset: %w", err))
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	if p.renderState == "done" || p.enrichState == "done" {
-		if err := database.SaveAsset(p.Asset); err != nil {
-			l.Error("failed to save asset", zap.Error(err))
-			p.done <- err // Avoid blocking?
-			// In agent v2, it does p.done <- err. But check channel capacity?
-			// p.done is unbuffered. So receiver must be waiting.
-			// But Run is called in goroutine. Wait() reads p.done.
-			// If nobody calls Wait(), this blocks?
-			// Agent v2 logic is strict.
-			// Ideally we don't block.
-			return err
+// loadJobAsset reads the asset a job refers to, with the parent chain bundle
+// resolution needs. Jobs for assets deleted in the meantime have nothing to do.
+func loadJobAsset(job *entities.Job) (*entities.Asset, error) {
+	asset, err := database.GetAsset(job.AssetID, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.GetLogger().Debug("job asset no longer exists", zap.String("asset_id", job.AssetID))
+			return nil, nil
 		}
+		return nil, err
 	}
 
-	return nil
+	if asset.ParentID != nil {
+		if err := database.LoadParents(&asset, 10); err != nil {
+			return nil, fmt.Errorf("failed to load parents: %w", err)
+		}
+	}
+	return &asset, nil
 }
 
 func assetLabel(a *entities.Asset) string {
@@ -0,0 +1,283 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/processing/discovery"
+	"github.com/eduardooliveira/stLib/core/system"
+)
+
+const progressInterval = time.Second
+
+const (
+	ScanPhaseDiscovering = "discovering"
+	ScanPhaseProcessing  = "processing"
+	ScanPhaseFinished    = "finished"
+	ScanPhaseFailed      = "failed"
+	ScanPhaseCancelled   = "cancelled"
+)
+
+// ScanProgress is published on the system.scan topic once per second for every
+// filesystem being scanned, followed by a single summary when the scan and the
+// jobs it queued are done.
+type ScanProgress struct {
+	Type            string                         `json:"type"`
+	ScanID          string                         `json:"scan_id"`
+	FS              string                         `json:"fs,omitempty"`
+	Phase           string                         `json:"phase"`
+	DirsWalked      int64                          `json:"dirs_walked"`
+	AssetsFound     int64                          `json:"assets_found"`
+	RenderQueue     int64                          `json:"render_queue"`
+	EnrichQueue     int64                          `json:"enrich_queue"`
+	AssetsPerSecond float64                        `json:"assets_per_second"`
+	JobsPerSecond   float64                        `json:"jobs_per_second"`
+	ETA             *float64                       `json:"eta_seconds"`
+	Elapsed         float64                        `json:"elapsed_seconds"`
+	Stats           *discovery.ScanStats           `json:"stats,omitempty"`
+	Filesystems     map[string]discovery.ScanStats `json:"filesystems,omitempty"`
+	Error           string                         `json:"error,omitempty"`
+}
+
+type fsProgress struct {
+	name       string
+	discoverer *discovery.RecursiveAssetDiscoverer
+	// expected is how many assets the filesystem had on the previous scan
+	expected int64
+	walked   time.Duration
+	done     bool
+	err      error
+}
+
+type scanReporter struct {
+	mu      sync.Mutex
+	scanID  string
+	started time.Time
+	// jobs are the kinds of the jobs this scan queued that are still to run,
+	// by ID. Other scans and retries of older jobs don't hold this one up.
+	jobs     map[string]string
+	jobsDone int64
+	fss      []*fsProgress
+	finished chan error
+}
+
+func newScanReporter(scanID string) *scanReporter {
+	return &scanReporter{
+		scanID:   scanID,
+		started:  time.Now(),
+		jobs:     make(map[string]string),
+		finished: make(chan error, 1),
+	}
+}
+
+// enqueued follows a job the scan queued, or found already waiting.
+func (r *scanReporter) enqueued(id, kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[id] = kind
+}
+
+// pending drops the jobs that ran since the last call and counts the others.
+func (r *scanReporter) pending() (render, enrich int64) {
+	r.mu.Lock()
+	ids := make([]string, 0, len(r.jobs))
+	for id := range r.jobs {
+		ids = append(ids, id)
+	}
+	r.mu.Unlock()
+
+	active, err := database.ActiveJobs(ids)
+	if err != nil {
+		logger.GetLogger().Warn("failed to count scan jobs", zap.String("scan_id", r.scanID), zap.Error(err))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		running := make(map[string]bool, len(active))
+		for _, j := range active {
+			running[j.ID] = true
+		}
+		// Jobs queued while the states were read aren't in ids and stay
+		for _, id := range ids {
+			if !running[id] {
+				delete(r.jobs, id)
+				r.jobsDone++
+			}
+		}
+	}
+	for _, kind := range r.jobs {
+		switch kind {
+		case entities.JobKindRender:
+			render++
+		case entities.JobKindEnrich:
+			enrich++
+		}
+	}
+	return render, enrich
+}
+
+func (r *scanReporter) track(name string, d *discovery.RecursiveAssetDiscoverer) *fsProgress {
+	expected, err := database.CountAssetsInFS(name)
+	if err != nil {
+		logger.GetLogger().Warn("failed to count assets", zap.String("fs", name), zap.Error(err))
+	}
+
+	p := &fsProgress{name: name, discoverer: d, expected: expected}
+	r.mu.Lock()
+	r.fss = append(r.fss, p)
+	r.mu.Unlock()
+	return p
+}
+
+func (r *scanReporter) walked(p *fsProgress, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.done = true
+	p.walked = time.Since(r.started)
+	p.err = err
+}
+
+// finish signals the end of discovery, the reporter keeps going until the
+// queued jobs are drained.
+func (r *scanReporter) finish(err error) {
+	r.finished <- err
+}
+
+func (r *scanReporter) run() {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	var scanErr error
+	discovering := true
+	for {
+		select {
+		case scanErr = <-r.finished:
+			discovering = false
+			// A cancelled scan doesn't wait for the jobs queued so far
+			if errors.Is(scanErr, context.Canceled) {
+				r.summary(scanErr)
+				return
+			}
+		case <-ticker.C:
+		}
+
+		render, enrich := r.pending()
+		r.publish(render, enrich)
+		if !discovering && render+enrich == 0 {
+			r.summary(scanErr)
+			return
+		}
+	}
+}
+
+func (r *scanReporter) publish(render, enrich int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := time.Since(r.started)
+	jobRate := rate(r.jobsDone, elapsed)
+	for _, p := range r.fss {
+		stats := p.discoverer.Stats()
+		walkTime := elapsed
+		phase := ScanPhaseDiscovering
+		if p.done {
+			walkTime = p.walked
+			phase = ScanPhaseProcessing
+		}
+		assetRate := rate(stats.Found, walkTime)
+
+		msg := ScanProgress{
+			Type:            "progress",
+			ScanID:          r.scanID,
+			FS:              p.name,
+			Phase:           phase,
+			DirsWalked:      stats.Dirs,
+			AssetsFound:     stats.Found,
+			RenderQueue:     render,
+			EnrichQueue:     enrich,
+			AssetsPerSecond: assetRate,
+			JobsPerSecond:   jobRate,
+			Elapsed:         elapsed.Seconds(),
+		}
+		if errors.Is(p.err, context.Canceled) {
+			msg.Phase = ScanPhaseCancelled
+		} else if p.err != nil {
+			msg.Phase = ScanPhaseFailed
+			msg.Error = p.err.Error()
+		}
+		msg.ETA = eta(p, stats.Found, assetRate, render+enrich, jobRate)
+		system.PublishScan(msg)
+	}
+}
+
+func (r *scanReporter) summary(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := discovery.ScanStats{}
+	perFS := make(map[string]discovery.ScanStats, len(r.fss))
+	for _, p := range r.fss {
+		stats := p.discoverer.Stats()
+		perFS[p.name] = stats
+		total.Add(stats)
+	}
+
+	elapsed := time.Since(r.started)
+	var done float64
+	msg := ScanProgress{
+		Type:            "summary",
+		ScanID:          r.scanID,
+		Phase:           ScanPhaseFinished,
+		DirsWalked:      total.Dirs,
+		AssetsFound:     total.Found,
+		AssetsPerSecond: rate(total.Found, elapsed),
+		JobsPerSecond:   rate(r.jobsDone, elapsed),
+		ETA:             &done,
+		Elapsed:         elapsed.Seconds(),
+		Stats:           &total,
+		Filesystems:     perFS,
+	}
+	if errors.Is(err, context.Canceled) {
+		msg.Phase = ScanPhaseCancelled
+	} else if err != nil {
+		msg.Phase = ScanPhaseFailed
+		msg.Error = err.Error()
+	}
+	system.PublishScan(msg)
+}
+
+// eta estimates the remaining time from the asset count of the previous scan
+// and the rate jobs are completed at, it's unknown until both rates are known.
+func eta(p *fsProgress, found int64, assetRate float64, queued int64, jobRate float64) *float64 {
+	var remaining float64
+	if !p.done {
+		if assetRate == 0 {
+			return nil
+		}
+		if p.expected > found {
+			remaining += float64(p.expected-found) / assetRate
+		}
+	}
+	if queued > 0 {
+		if jobRate == 0 {
+			return nil
+		}
+		remaining += float64(queued) / jobRate
+	}
+	return &remaining
+}
+
+func rate(count int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(count) / d.Seconds()
+}
@@ -0,0 +1,166 @@
+package renderers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// frameFunc renders the i-th frame of an animation when the encoder gets to it,
+// so the full color frames are never all held at once.
+type frameFunc func(i int) (image.Image, error)
+
+// encodeGIF writes n frames as a looping GIF, delay is in milliseconds. The
+// encoder needs them all, only their paletted copies are kept.
+func encodeGIF(w io.Writer, n int, frame frameFunc, delay int) error {
+	palette := renderPalette()
+	anim := &gif.GIF{}
+	for i := 0; i < n; i++ {
+		f, err := frame(i)
+		if err != nil {
+			return err
+		}
+		b := f.Bounds()
+		p := image.NewPaletted(b, palette)
+		draw.FloydSteinberg.Draw(p, b, f, b.Min)
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, max(delay/10, 1))
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// renderPalette holds the colors the phong shader produces: the background,
+// the model color from dark to fully lit, and its highlights up to white.
+func renderPalette() color.Palette {
+	model := fauxgl.HexColor(runtime.Cfg.Render.ModelColor)
+	palette := color.Palette{fauxgl.HexColor(runtime.Cfg.Render.BackgroundColor).NRGBA()}
+	for i := 0; i < 191; i++ {
+		palette = append(palette, model.MulScalar(float64(i)/190).Opaque().NRGBA())
+	}
+	for i := 1; i <= 64; i++ {
+		palette = append(palette, model.Lerp(fauxgl.White, float64(i)/64).Opaque().NRGBA())
+	}
+	return palette
+}
+
+// encodeAPNG writes n frames as a looping animated PNG, delay is in
+// milliseconds. Every frame is encoded as a PNG of its own and its image data
+// moved into the animation chunks, one frame at a time.
+func encodeAPNG(w io.Writer, n int, frame frameFunc, delay int) error {
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+
+	var seq uint32
+	for i := 0; i < n; i++ {
+		f, err := frame(i)
+		if err != nil {
+			return err
+		}
+		// Drawing into the same image type keeps the color type of all frames
+		// the one IHDR declares
+		b := f.Bounds()
+		rgba := image.NewRGBA(b)
+		draw.Draw(rgba, b, f, b.Min, draw.Src)
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, rgba); err != nil {
+			return err
+		}
+		chunks, err := pngChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:], uint32(n))
+			binary.BigEndian.PutUint32(actl[4:], 0) // loop forever
+			if err := writeChunk(w, "IHDR", chunks["IHDR"][0]); err != nil {
+				return err
+			}
+			if err := writeChunk(w, "acTL", actl); err != nil {
+				return err
+			}
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+		binary.BigEndian.PutUint16(fctl[20:], uint16(min(delay, 65535)))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		seq++
+		if err := writeChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+
+		for _, data := range chunks["IDAT"] {
+			if i == 0 {
+				err = writeChunk(w, "IDAT", data)
+			} else {
+				fdat := binary.BigEndian.AppendUint32(make([]byte, 0, len(data)+4), seq)
+				seq++
+				err = writeChunk(w, "fdAT", append(fdat, data...))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return writeChunk(w, "IEND", nil)
+}
+
+// pngChunks splits an encoded PNG into the data of its chunks by type.
+func pngChunks(b []byte) (map[string][][]byte, error) {
+	if !bytes.HasPrefix(b, pngSignature) {
+		return nil, errors.New("not a png")
+	}
+	b = b[len(pngSignature):]
+
+	chunks := make(map[string][][]byte)
+	for len(b) >= 12 {
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n)+12 > uint64(len(b)) {
+			return nil, errors.New("truncated png chunk")
+		}
+		kind := string(b[4:8])
+		chunks[kind] = append(chunks[kind], b[8:8+n])
+		b = b[12+n:]
+	}
+	if len(chunks["IHDR"]) == 0 || len(chunks["IDAT"]) == 0 {
+		return nil, errors.New("png without image data")
+	}
+	return chunks, nil
+}
+
+func writeChunk(w io.Writer, kind string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], kind)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+	return err
+}
@@ -10,7 +10,6 @@ import (
 	"image"
 	_ "image/jpeg"
 	"image/png"
-	"io/fs"
 	"strconv"
 	"strings"
 
@@ -31,26 +30,21 @@ type tmpImg struct {
 	data   []byte
 }
 
-func (r *gCodeRenderer) Render(ctx context.Context, asset *entities.Asset) (*entities.Asset, error) {
+func (r *gCodeRenderer) Render(ctx context.Context, asset *entities.Asset) ([]*entities.Asset, error) {
 	genFS, err := libfs.GetLibFS("generated")
 	if err != nil {
 		return nil, fmt.Errorf("render error getting fs: %w", err)
 	}
 	imgName := fmt.Sprintf("%s.r.png", asset.ID)
 
-	if _, err := fs.Stat(genFS.GetFS(), imgName); err == nil {
-		return entities.NewAsset(genFS.GetName(), genFS.GetRoot(), imgName, false, asset), nil
+	if upToDate(genFS, imgName, asset) {
+		return []*entities.Asset{entities.NewAsset(genFS.GetName(), genFS.GetRoot(), imgName, false, asset)}, nil
 	}
 
 	pathStr := utils.VoZ(asset.Path)
 	logger.GetLogger().Info("Rendering", zap.String("asset", pathStr), zap.String("img", imgName))
 
-	objFs, err := libfs.GetAssetFS(ctx, *asset)
-	if err != nil {
-		return nil, fmt.Errorf("error getting fs: %w", err)
-	}
-
-	f, err := objFs.Open(pathStr)
+	f, err := libfs.OpenAsset(ctx, *asset)
 	if err != nil {
 		return nil, err
 	}
@@ -87,13 +81,13 @@ func (r *gCodeRenderer) Render(ctx context.Context, asset *entities.Asset) (*ent
 		return nil, errors.Join(err, errors.New("error reading gcode"))
 	}
 
-	if img.data == nil {
-		return nil, errors.New("no thumbnail found")
-	}
-
-	// Double check decoding works
-	decodedImg, _, err := image.Decode(bytes.NewReader(img.data))
-	if err != nil {
+	var decodedImg image.Image
+	if img.data != nil {
+		// Double check decoding works
+		if decodedImg, _, err = image.Decode(bytes.NewReader(img.data)); err != nil {
+			return nil, err
+		}
+	} else if decodedImg, err = r.renderToolpath(ctx, asset); err != nil {
 		return nil, err
 	}
 
@@ -107,7 +101,18 @@ func (r *gCodeRenderer) Render(ctx context.Context, asset *entities.Asset) (*ent
 		return nil, err
 	}
 
-	return entities.NewAsset(genFS.GetName(), genFS.GetRoot(), imgName, false, asset), nil
+	return []*entities.Asset{entities.NewAsset(genFS.GetName(), genFS.GetRoot(), imgName, false, asset)}, nil
+}
+
+// renderToolpath draws the extrusions of files sliced without a thumbnail,
+// as seen by the first render preset.
+func (r *gCodeRenderer) renderToolpath(ctx context.Context, asset *entities.Asset) (image.Image, error) {
+	logger.GetLogger().Info("No embedded thumbnail, rendering toolpath", zap.String("asset", utils.VoZ(asset.Path)))
+	t, err := loadToolpath(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+	return t.draw(ctx, configuredPresets()[0])
 }
 
 func (r *gCodeRenderer) parseThumbnail(scanner *bufio.Scanner, size string, length int) (*tmpImg, error) {
@@ -0,0 +1,85 @@
+package renderers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"io/fs"
+
+	"github.com/eduardooliveira/stLib/core/cache"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/gcode"
+)
+
+var layerCache = cache.NewStore("layers")
+
+// toolpathIndex is what loadToolpath learns of a file, cached next to its
+// layers so drawing one reads the file once instead of twice.
+type toolpathIndex struct {
+	Min      gcode.Point `json:"min"`
+	Max      gcode.Point `json:"max"`
+	Layers   []int64     `json:"layers"`
+	Features bool        `json:"features"`
+}
+
+// Layer returns a top view of a layer of a G-code asset, numbered from 1, as
+// a size by size PNG. Layers are cached until the file changes.
+func Layer(ctx context.Context, asset *entities.Asset, n, size int) (fs.File, error) {
+	var modTime int64
+	if asset.ModTime != nil {
+		modTime = asset.ModTime.UnixNano()
+	}
+	version := cache.Version(modTime, asset.Size)
+
+	return layerCache.Get(asset.ID, fmt.Sprintf("%d-%d", n, size), version, ".png", func(w io.Writer) error {
+		t, err := indexedToolpath(ctx, asset, version)
+		if err != nil {
+			return err
+		}
+		img, err := t.drawLayer(ctx, n, size)
+		if err != nil {
+			return err
+		}
+		return png.Encode(w, img)
+	})
+}
+
+// InvalidateLayers drops the cached layers of an asset.
+func InvalidateLayers(id string) error {
+	return layerCache.Invalidate(id)
+}
+
+// indexedToolpath loads the toolpath of a file from its cached index, reading
+// the file for it only the first time.
+func indexedToolpath(ctx context.Context, asset *entities.Asset, version string) (*toolpath, error) {
+	f, err := layerCache.Get(asset.ID, "toolpath", version, ".json", func(w io.Writer) error {
+		t, err := loadToolpath(ctx, asset)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(toolpathIndex{Min: t.min, Max: t.max, Layers: t.layers, Features: t.features})
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var idx toolpathIndex
+	if err := json.NewDecoder(f).Decode(&idx); err != nil {
+		return nil, err
+	}
+	t := &toolpath{
+		asset:    asset,
+		min:      idx.Min,
+		max:      idx.Max,
+		layers:   idx.Layers,
+		index:    make(map[int64]int, len(idx.Layers)),
+		features: idx.Features,
+	}
+	for i, z := range t.layers {
+		t.index[z] = i
+	}
+	return t, nil
+}
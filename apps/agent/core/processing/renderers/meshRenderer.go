@@ -0,0 +1,132 @@
+package renderers
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+
+	"go.uber.org/zap"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/mesh"
+	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/nfnt/resize"
+)
+
+// meshRenderer renders any model the mesh package can load, one image for
+// every configured render preset.
+type meshRenderer struct {
+	scale int
+}
+
+func NewMeshRenderer() *meshRenderer {
+	return &meshRenderer{
+		scale: 1, // optional supersampling
+	}
+}
+
+func (s *meshRenderer) Render(ctx context.Context, asset *entities.Asset) ([]*entities.Asset, error) {
+	genFS, err := libfs.GetLibFS("generated")
+	if err != nil {
+		return nil, fmt.Errorf("render error getting fs: %w", err)
+	}
+
+	var model *mesh.Model
+	presets := renderPresets()
+	rtn := make([]*entities.Asset, 0, len(presets))
+	for i, p := range presets {
+		imgName := renderName(asset, i, p)
+		img := newRenderAsset(genFS, imgName, asset, p)
+
+		// Check if already exists
+		if upToDate(genFS, imgName, asset) && renderedWith(img) {
+			rtn = append(rtn, img)
+			continue
+		}
+
+		if model == nil {
+			model, err = mesh.Load(ctx, asset)
+			if err != nil {
+				logger.GetLogger().Error("failed to load mesh", zap.String("asset", utils.VoZ(asset.Path)), zap.Error(err))
+				return nil, err
+			}
+
+			// fit mesh in a bi-unit cube centered at the origin
+			model.Mesh.BiUnitCube()
+
+			// smooth the normals
+			model.Mesh.SmoothNormalsThreshold(fauxgl.Radians(30))
+		}
+
+		logger.GetLogger().Info("Rendering", zap.String("asset", utils.VoZ(asset.Path)), zap.String("preset", p.Name), zap.String("img", imgName))
+		if err := s.renderPreset(ctx, genFS, imgName, model.Mesh, p); err != nil {
+			return nil, err
+		}
+		rtn = append(rtn, img)
+	}
+
+	return rtn, nil
+}
+
+// renderPreset draws the frames of a preset and writes them as a still image
+// or an animation.
+func (s *meshRenderer) renderPreset(ctx context.Context, genFS libfs.LibFS, imgName string, m *fauxgl.Mesh, p runtime.RenderPreset) error {
+	n := max(p.Frames, 1)
+	frame := func(i int) (image.Image, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return s.draw(m, p, 2*math.Pi*float64(i)/float64(n)), nil
+	}
+
+	writer, err := genFS.Create(imgName)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	switch {
+	case p.Frames == 0:
+		var img image.Image
+		if img, err = frame(0); err == nil {
+			err = png.Encode(writer, img)
+		}
+	case p.Format == "apng":
+		err = encodeAPNG(writer, n, frame, p.Delay)
+	default:
+		err = encodeGIF(writer, n, frame, p.Delay)
+	}
+	if err != nil {
+		writer.Close()
+		genFS.Remove(imgName)
+		return err
+	}
+	return nil
+}
+
+// draw renders one frame of a preset, turntables orbit the camera and the
+// light around the vertical axis by angle.
+func (s *meshRenderer) draw(m *fauxgl.Mesh, p runtime.RenderPreset, angle float64) image.Image {
+	matrix, eye, light := presetView(p, angle)
+
+	// create a rendering context
+	context := fauxgl.NewContext(p.Width*s.scale, p.Height*s.scale)
+	context.ClearColorBufferWith(fauxgl.HexColor(runtime.Cfg.Render.BackgroundColor))
+
+	// use builtin phong shader
+	shader := fauxgl.NewPhongShader(matrix, light, eye)
+	shader.ObjectColor = fauxgl.HexColor(runtime.Cfg.Render.ModelColor)
+	context.Shader = shader
+
+	// render
+	context.DrawMesh(m)
+
+	// downsample image for antialiasing
+	return resize.Resize(uint(p.Width), uint(p.Height), context.Image(), resize.Bilinear)
+}
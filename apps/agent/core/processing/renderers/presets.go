@@ -0,0 +1,208 @@
+package renderers
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+const (
+	// orthoMargin is the half height of orthographic views, a bit more than
+	// the bi-unit cube the model is fit in
+	orthoMargin = 1.15
+	// near clipping plane
+	near = 1
+	// maxAnimationPixels bounds the frames of a turntable times their size,
+	// 128 frames of 512x512
+	maxAnimationPixels = 32 << 20
+)
+
+var (
+	perspectiveCenter = fauxgl.V(0, -0.07, 0)                 // view center position
+	perspectiveLight  = fauxgl.V(-0.75, -5, 0.25).Normalize() // light direction of perspective views
+)
+
+type camera struct {
+	eye   fauxgl.Vector
+	up    fauxgl.Vector
+	light fauxgl.Vector
+}
+
+var orthoViews = map[string]camera{
+	"front": {eye: fauxgl.V(0, -3, 0), up: fauxgl.V(0, 0, 1), light: fauxgl.V(-0.75, -5, 0.25).Normalize()},
+	"top":   {eye: fauxgl.V(0, 0, 3), up: fauxgl.V(0, 1, 0), light: fauxgl.V(-0.25, -0.75, 5).Normalize()},
+	"side":  {eye: fauxgl.V(3, 0, 0), up: fauxgl.V(0, 0, 1), light: fauxgl.V(5, -0.75, 0.25).Normalize()},
+}
+
+var defaultPreset = runtime.RenderPreset{
+	Name:   "default",
+	Width:  1920,
+	Height: 1080,
+	View:   "perspective",
+	Eye:    []float64{-3, -3, -0.75},
+	Fovy:   30,
+}
+
+// presetView returns the transformation of a preset view of the bi-unit cube
+// with the camera position and light direction. Turntables orbit the camera
+// and the light around the vertical axis by angle.
+func presetView(p runtime.RenderPreset, angle float64) (fauxgl.Matrix, fauxgl.Vector, fauxgl.Vector) {
+	cam, ortho := orthoViews[p.View]
+	center := fauxgl.Vector{}
+	if !ortho {
+		cam = camera{eye: fauxgl.V(p.Eye[0], p.Eye[1], p.Eye[2]), up: fauxgl.V(0, 0, 1), light: perspectiveLight}
+		center = perspectiveCenter
+	}
+	orbit := fauxgl.Rotate(fauxgl.V(0, 0, 1), angle)
+	eye := orbit.MulPosition(cam.eye)
+	light := orbit.MulDirection(cam.light)
+	center = orbit.MulPosition(center)
+
+	// the model never reaches past the far corner of its cube
+	aspect := float64(p.Width) / float64(p.Height)
+	far := eye.Length() + 2
+	matrix := fauxgl.LookAt(eye, center, cam.up)
+	if ortho {
+		w, h := orthoMargin*aspect, orthoMargin
+		if aspect < 1 {
+			w, h = orthoMargin, orthoMargin/aspect
+		}
+		matrix = matrix.Orthographic(-w, w, -h, h, near, far)
+	} else {
+		matrix = matrix.Perspective(p.Fovy, aspect, near, far)
+	}
+	return matrix, eye, light
+}
+
+// configuredPresets returns the render presets with the blanks filled in.
+func configuredPresets() []runtime.RenderPreset {
+	presets := runtime.Cfg.Render.Presets
+	if len(presets) == 0 {
+		presets = []runtime.RenderPreset{defaultPreset}
+	}
+
+	rtn := make([]runtime.RenderPreset, 0, len(presets))
+	for _, p := range presets {
+		p.View = strings.ToLower(p.View)
+		if _, ok := orthoViews[p.View]; !ok {
+			p.View = defaultPreset.View
+		}
+		if p.Name == "" {
+			p.Name = p.View
+		}
+		if p.Width <= 0 || p.Height <= 0 {
+			p.Width, p.Height = defaultPreset.Width, defaultPreset.Height
+		}
+		if len(p.Eye) != 3 {
+			p.Eye = defaultPreset.Eye
+		}
+		if p.Fovy <= 0 {
+			p.Fovy = defaultPreset.Fovy
+		}
+		p.Frames = min(max(p.Frames, 0), 360)
+		if p.Frames > 0 {
+			// GIFs keep every frame until they're encoded
+			p.Frames = max(min(p.Frames, maxAnimationPixels/(p.Width*p.Height)), 1)
+		}
+		p.Format = strings.ToLower(p.Format)
+		if p.Format != "apng" {
+			p.Format = "gif"
+		}
+		if p.Delay <= 0 {
+			p.Delay = 100
+		}
+		rtn = append(rtn, p)
+	}
+	return rtn
+}
+
+// renderPresets returns what is rendered of every model. The first image is
+// the thumbnail and has to be a still, an animated first preset is preceded
+// by a still of its first frame.
+func renderPresets() []runtime.RenderPreset {
+	presets := configuredPresets()
+	if presets[0].Frames > 0 {
+		still := presets[0]
+		still.Frames = 0
+		presets = append([]runtime.RenderPreset{still}, presets...)
+	}
+	return presets
+}
+
+// renderName names the image of a preset. The first one is the thumbnail and
+// keeps the name renders have always had, <id>.r.png.
+func renderName(asset *entities.Asset, i int, p runtime.RenderPreset) string {
+	if i == 0 {
+		return fmt.Sprintf("%s.r.png", asset.ID)
+	}
+	ext := ".png"
+	if p.Frames > 0 && p.Format == "gif" {
+		ext = ".gif"
+	}
+	return fmt.Sprintf("%s.r.%s%s", asset.ID, presetSlug(p.Name), ext)
+}
+
+func presetSlug(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name)
+}
+
+// newRenderAsset creates the generated asset of a preset view. It records the
+// preset and a fingerprint of everything that changes the picture.
+func newRenderAsset(genFS libfs.LibFS, imgName string, asset *entities.Asset, p runtime.RenderPreset) *entities.Asset {
+	img := entities.NewAsset(genFS.GetName(), genFS.GetRoot(), imgName, false, asset)
+	img.Properties["render"] = map[string]any{
+		"preset":      p.Name,
+		"view":        p.View,
+		"animated":    p.Frames > 0,
+		"fingerprint": presetFingerprint(p),
+	}
+	return img
+}
+
+// PresetFingerprints returns the fingerprints of the rendered presets.
+func PresetFingerprints() []string {
+	presets := renderPresets()
+	rtn := make([]string, 0, len(presets))
+	for _, p := range presets {
+		rtn = append(rtn, presetFingerprint(p))
+	}
+	return rtn
+}
+
+func presetFingerprint(p runtime.RenderPreset) string {
+	b, _ := json.Marshal(struct {
+		Preset          runtime.RenderPreset
+		ModelColor      string
+		BackgroundColor string
+	}{p, runtime.Cfg.Render.ModelColor, runtime.Cfg.Render.BackgroundColor})
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// renderedWith reports whether the stored render was made with the same
+// preset as img.
+func renderedWith(img *entities.Asset) bool {
+	prev, err := database.GetAssetByLocator(img.Locator)
+	if err != nil {
+		return false
+	}
+	stored, _ := prev.Properties["render"].(map[string]any)
+	current, _ := img.Properties["render"].(map[string]any)
+	return stored != nil && stored["fingerprint"] == current["fingerprint"]
+}
@@ -2,32 +2,65 @@ package renderers
 
 import (
 	"context"
+	"io/fs"
 	"strings"
 
 	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/runtime"
 )
 
+// Renderer generates the images of an asset, the first one is its thumbnail.
 type Renderer interface {
-	Render(ctx context.Context, asset *entities.Asset) (*entities.Asset, error)
+	Render(ctx context.Context, asset *entities.Asset) ([]*entities.Asset, error)
 }
 
 var (
-	renderers = make(map[string]Renderer)
+	renderers       = make(map[string]Renderer)
+	bundleRenderers = make(map[string]Renderer)
 )
 
 func Register(ext string, r Renderer) {
 	renderers[ext] = r
 }
 
+// RegisterBundle registers a renderer that reads a bundle as a whole, like the
+// mesh of a 3MF package. Those apply whether bundles render or not.
+func RegisterBundle(ext string, r Renderer) {
+	bundleRenderers[ext] = r
+}
+
 func Get(asset *entities.Asset) (Renderer, bool) {
 	if asset.Extension == nil {
 		return nil, false
 	}
-	r, ok := renderers[strings.ToLower(*asset.Extension)]
+	ext := strings.ToLower(*asset.Extension)
+	if asset.NodeKind == entities.NodeKindBundle {
+		if r, ok := bundleRenderers[ext]; ok {
+			return r, true
+		}
+		if !runtime.Cfg.Library.RenderBundles {
+			return nil, false
+		}
+	}
+	r, ok := renderers[ext]
 	return r, ok
 }
 
 func Init() {
-	Register(".stl", NewSTLRenderer())
+	meshRenderer := NewMeshRenderer()
+	Register(".stl", meshRenderer)
+	Register(".obj", meshRenderer)
+	Register(".ply", meshRenderer)
+	RegisterBundle(".3mf", meshRenderer)
 	Register(".gcode", &gCodeRenderer{})
 }
+
+// upToDate reports whether a render exists and is newer than the asset content.
+func upToDate(genFS libfs.LibFS, imgName string, asset *entities.Asset) bool {
+	st, err := fs.Stat(genFS.GetFS(), imgName)
+	if err != nil {
+		return false
+	}
+	return asset.ModTime == nil || !st.ModTime().Before(*asset.ModTime)
+}
@@ -0,0 +1,331 @@
+package renderers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/Maker-Management-Platform/fauxgl"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/gcode"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/nfnt/resize"
+)
+
+const (
+	// toolpathWidth is how wide extrusions are drawn, in mm, about what a
+	// 0.4mm nozzle lays down
+	toolpathWidth = 0.45
+	// toolpathScale supersamples toolpaths, lines alias more than meshes
+	toolpathScale = 2
+)
+
+var (
+	ErrNoToolpath = errors.New("gcode has no extrusions")
+	ErrNoLayer    = errors.New("no such layer")
+)
+
+// feature is the part of a print an extrusion belongs to, as slicers name
+// them in their comments.
+type feature int
+
+const (
+	featureOther feature = iota
+	featureOuterWall
+	featureWall
+	featureInfill
+	featureSolidInfill
+	featureBridge
+	featureSupport
+	featureSkirt
+	featureTower
+)
+
+var featureColors = map[feature]fauxgl.Color{
+	featureOther:       fauxgl.HexColor("#B0B0B0"),
+	featureOuterWall:   fauxgl.HexColor("#FF7D38"),
+	featureWall:        fauxgl.HexColor("#FFE64D"),
+	featureInfill:      fauxgl.HexColor("#B03029"),
+	featureSolidInfill: fauxgl.HexColor("#9654CC"),
+	featureBridge:      fauxgl.HexColor("#4D80BA"),
+	featureSupport:     fauxgl.HexColor("#00C000"),
+	featureSkirt:       fauxgl.HexColor("#00876E"),
+	featureTower:       fauxgl.HexColor("#B3E3AB"),
+}
+
+// featureOf maps the feature names of PrusaSlicer, Cura, Orca and Bambu
+// Studio, like "External perimeter", "WALL-OUTER" or "Sparse infill".
+func featureOf(name string) feature {
+	name = strings.ToLower(name)
+	has := func(words ...string) bool {
+		return slices.ContainsFunc(words, func(w string) bool { return strings.Contains(name, w) })
+	}
+	switch {
+	case has("support"):
+		return featureSupport
+	case has("skirt", "brim"):
+		return featureSkirt
+	case has("tower", "wipe"):
+		return featureTower
+	case has("bridge"):
+		return featureBridge
+	case has("outer", "external", "overhang"):
+		return featureOuterWall
+	case has("perimeter", "wall"):
+		return featureWall
+	case has("solid", "top", "bottom", "skin", "ironing"):
+		return featureSolidInfill
+	case has("infill", "fill"):
+		return featureInfill
+	}
+	return featureOther
+}
+
+// segment is an extrusion of a toolpath.
+type segment struct {
+	from, to gcode.Point
+	feature  feature
+	layer    int
+}
+
+// toolpath is what is known of a G-code file before drawing it, the bounds
+// of its extrusions and the heights of its layers. Files can be too big to
+// hold their moves, drawing reads them again.
+type toolpath struct {
+	asset    *entities.Asset
+	min, max gcode.Point
+	// layers are the heights extrusions happen at, in µm
+	layers   []int64
+	index    map[int64]int
+	features bool
+}
+
+func layerKey(z float64) int64 {
+	return int64(math.Round(z * 1000))
+}
+
+func loadToolpath(ctx context.Context, asset *entities.Asset) (*toolpath, error) {
+	t := &toolpath{
+		asset: asset,
+		min:   gcode.Point{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)},
+		max:   gcode.Point{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)},
+		index: make(map[int64]int),
+	}
+	err := t.run(ctx, func(c *gcode.Command) {
+		if _, ok := featureComment(c); ok {
+			t.features = true
+		}
+	}, func(m gcode.Move) {
+		t.index[layerKey(m.To.Z)] = 0
+		for _, p := range []gcode.Point{m.From, m.To} {
+			t.min = gcode.Point{X: math.Min(t.min.X, p.X), Y: math.Min(t.min.Y, p.Y), Z: math.Min(t.min.Z, p.Z)}
+			t.max = gcode.Point{X: math.Max(t.max.X, p.X), Y: math.Max(t.max.Y, p.Y), Z: math.Max(t.max.Z, p.Z)}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.index) == 0 {
+		return nil, ErrNoToolpath
+	}
+
+	for z := range t.index {
+		t.layers = append(t.layers, z)
+	}
+	slices.Sort(t.layers)
+	for i, z := range t.layers {
+		t.index[z] = i
+	}
+	return t, nil
+}
+
+// walk reads the extrusions of the file in order.
+func (t *toolpath) walk(ctx context.Context, fn func(s segment)) error {
+	current := featureOther
+	return t.run(ctx, func(c *gcode.Command) {
+		if name, ok := featureComment(c); ok {
+			current = featureOf(name)
+		}
+	}, func(m gcode.Move) {
+		fn(segment{from: m.From, to: m.To, feature: current, layer: t.index[layerKey(m.To.Z)]})
+	})
+}
+
+func (t *toolpath) run(ctx context.Context, onCommand func(c *gcode.Command), onExtrusion func(m gcode.Move)) error {
+	f, err := libfs.OpenAsset(ctx, *t.asset)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	in := &gcode.Interpreter{
+		OnCommand: func(c *gcode.Command) error {
+			onCommand(c)
+			return nil
+		},
+		OnMove: func(m gcode.Move) error {
+			if m.Extruding() {
+				onExtrusion(m)
+			}
+			return nil
+		},
+	}
+	return in.Run(ctx, f)
+}
+
+// featureComment reads the feature comments slicers write before each part,
+// ";TYPE:" in most of them and "; FEATURE:" in Bambu Studio and Orca.
+func featureComment(c *gcode.Command) (string, bool) {
+	if c.Letter != 0 || c.Name != "" {
+		return "", false
+	}
+	key, value, ok := strings.Cut(c.Comment, ":")
+	if !ok {
+		return "", false
+	}
+	switch strings.ToUpper(strings.TrimSpace(key)) {
+	case "TYPE", "FEATURE":
+		return strings.TrimSpace(value), true
+	}
+	return "", false
+}
+
+// fit moves the print in a bi-unit cube centered at the origin, like meshes
+// are, and returns the scale it was shrunk by.
+func (t *toolpath) fit() (fauxgl.Matrix, float64) {
+	size := fauxgl.V(t.max.X-t.min.X, t.max.Y-t.min.Y, t.max.Z-t.min.Z)
+	center := fauxgl.V(t.min.X, t.min.Y, t.min.Z).Add(size.MulScalar(0.5))
+	scale := 2 / math.Max(size.MaxComponent(), toolpathWidth)
+	return fauxgl.Scale(fauxgl.V(scale, scale, scale)).Mul(fauxgl.Translate(center.Negate())), scale
+}
+
+// color is how a segment is drawn. Prints with feature comments are colored
+// by feature and shaded darker at the bottom, others get a gradient from blue
+// on the first layer to red on the last.
+func (t *toolpath) color(s segment) fauxgl.Color {
+	height := 1.0
+	if len(t.layers) > 1 {
+		height = float64(s.layer) / float64(len(t.layers)-1)
+	}
+	if t.features {
+		return featureColors[s.feature].MulScalar(0.55 + 0.45*height).Alpha(1)
+	}
+	return hsv(240*(1-height), 0.85, 0.95)
+}
+
+// draw renders the whole print as seen by a preset.
+func (t *toolpath) draw(ctx context.Context, p runtime.RenderPreset) (image.Image, error) {
+	fit, scale := t.fit()
+	matrix, _, _ := presetView(p, 0)
+	context := t.context(p, matrix, scale*pixelsPerUnit(p))
+
+	err := t.walk(ctx, func(s segment) {
+		drawSegment(context, fit, s.from, s.to, t.color(s))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resize.Resize(uint(p.Width), uint(p.Height), context.Image(), resize.Bilinear), nil
+}
+
+// drawLayer renders a layer, numbered from 1, from the top with the layer
+// below it faded. Every layer is framed on the whole print so they line up.
+func (t *toolpath) drawLayer(ctx context.Context, n, size int) (image.Image, error) {
+	if n < 1 || n > len(t.layers) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNoLayer, n, len(t.layers))
+	}
+
+	// Layers are flattened, the scale only has to fit them sideways
+	span := math.Max(math.Max(t.max.X-t.min.X, t.max.Y-t.min.Y), toolpathWidth)
+	scale := 2 / span
+	center := fauxgl.V(t.min.X+(t.max.X-t.min.X)/2, t.min.Y+(t.max.Y-t.min.Y)/2, 0)
+	fit := fauxgl.Scale(fauxgl.V(scale, scale, 0)).Mul(fauxgl.Translate(center.Negate()))
+
+	p := runtime.RenderPreset{View: "top", Width: size, Height: size}
+	matrix, _, _ := presetView(p, 0)
+	context := t.context(p, matrix, scale*pixelsPerUnit(p))
+
+	background := fauxgl.HexColor(runtime.Cfg.Render.BackgroundColor)
+	below := background.Lerp(fauxgl.HexColor(runtime.Cfg.Render.ModelColor), 0.35)
+	// Layers are raised a bit so the current one covers the one below
+	lift := fauxgl.Translate(fauxgl.V(0, 0, 0.01))
+	err := t.walk(ctx, func(s segment) {
+		switch s.layer {
+		case n - 1:
+			drawSegment(context, lift.Mul(fit), s.from, s.to, t.color(s))
+		case n - 2:
+			drawSegment(context, fit, s.from, s.to, below)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resize.Resize(uint(size), uint(size), context.Image(), resize.Bilinear), nil
+}
+
+func (t *toolpath) context(p runtime.RenderPreset, matrix fauxgl.Matrix, unit float64) *fauxgl.Context {
+	context := fauxgl.NewContext(p.Width*toolpathScale, p.Height*toolpathScale)
+	context.ClearColorBufferWith(fauxgl.HexColor(runtime.Cfg.Render.BackgroundColor))
+	context.LineWidth = min(max(toolpathWidth*unit*toolpathScale, 1), 32)
+	context.Shader = &toolpathShader{matrix: matrix}
+	return context
+}
+
+func drawSegment(context *fauxgl.Context, fit fauxgl.Matrix, from, to gcode.Point, color fauxgl.Color) {
+	a := fit.MulPosition(fauxgl.V(from.X, from.Y, from.Z))
+	b := fit.MulPosition(fauxgl.V(to.X, to.Y, to.Z))
+	context.DrawLine(fauxgl.NewLine(fauxgl.Vertex{Position: a, Color: color}, fauxgl.Vertex{Position: b, Color: color}))
+}
+
+// pixelsPerUnit is about how many pixels a unit of the bi-unit cube covers
+// around its center, exactly for orthographic views.
+func pixelsPerUnit(p runtime.RenderPreset) float64 {
+	aspect := float64(p.Width) / float64(p.Height)
+	if _, ortho := orthoViews[p.View]; ortho {
+		return float64(p.Height) / (2 * orthoMargin / math.Min(aspect, 1))
+	}
+	eye := fauxgl.V(p.Eye[0], p.Eye[1], p.Eye[2])
+	return float64(p.Height) / (2 * eye.Sub(perspectiveCenter).Length() * math.Tan(fauxgl.Radians(p.Fovy)/2))
+}
+
+// toolpathShader draws lines in the color of their vertices, unlit.
+type toolpathShader struct {
+	matrix fauxgl.Matrix
+}
+
+func (s *toolpathShader) Vertex(v fauxgl.Vertex) fauxgl.Vertex {
+	v.Output = s.matrix.MulPositionW(v.Position)
+	return v
+}
+
+func (s *toolpathShader) Fragment(v fauxgl.Vertex) fauxgl.Color {
+	return v.Color
+}
+
+// hsv makes a color from a hue in degrees, a saturation and a value.
+func hsv(h, s, v float64) fauxgl.Color {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return fauxgl.Color{R: r + m, G: g + m, B: b + m, A: 1}
+}
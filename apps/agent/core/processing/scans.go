@@ -0,0 +1,175 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+)
+
+var (
+	ErrScanRunning  = errors.New("a scan is already running on this filesystem")
+	ErrScanNotFound = errors.New("scan not found")
+	ErrScanScope    = errors.New("invalid scan scope")
+)
+
+// ScanScope limits a scan to a single filesystem or to the subtree under an
+// asset. An empty scope scans every discoverable filesystem.
+type ScanScope struct {
+	FS      string `json:"fs,omitempty" query:"fs"`
+	AssetID string `json:"asset_id,omitempty" query:"asset_id"`
+}
+
+type Scan struct {
+	ID          string    `json:"id"`
+	Scope       ScanScope `json:"scope"`
+	Filesystems []string  `json:"filesystems"`
+	StartedAt   time.Time `json:"started_at"`
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	targets []scanTarget
+}
+
+type scanTarget struct {
+	fs libfs.LibFS
+	// root is nil when the whole filesystem is scanned
+	root *entities.Asset
+}
+
+var scans = struct {
+	sync.Mutex
+	byID map[string]*Scan
+	byFS map[string]string
+}{
+	byID: make(map[string]*Scan),
+	byFS: make(map[string]string),
+}
+
+// fsLocks keeps discovery on a filesystem to one walk at a time. Scans hold
+// the lock of a filesystem while they walk it, changes wait for them.
+var fsLocks sync.Map
+
+// lockFS takes the discovery lock of the named filesystem, the returned func
+// releases it.
+func lockFS(name string) func() {
+	l, _ := fsLocks.LoadOrStore(name, &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// StartScan runs a scan in the background and returns it right away, it can be
+// stopped with CancelScan.
+func StartScan(scope ScanScope, logger *zap.Logger) (*Scan, error) {
+	s, err := newScan(context.Background(), scope)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if _, err := s.run(logger); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("discovery error", zap.String("scan_id", s.ID), zap.Error(err))
+		}
+	}()
+	return s, nil
+}
+
+func CancelScan(id string) error {
+	scans.Lock()
+	s, ok := scans.byID[id]
+	scans.Unlock()
+	if !ok {
+		return ErrScanNotFound
+	}
+	s.cancel()
+	return nil
+}
+
+func newScan(ctx context.Context, scope ScanScope) (*Scan, error) {
+	targets, err := resolveScope(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scan{
+		ID:          uuid.New().String(),
+		Scope:       scope,
+		Filesystems: make([]string, 0, len(targets)),
+		StartedAt:   time.Now(),
+		targets:     targets,
+	}
+	for _, t := range targets {
+		s.Filesystems = append(s.Filesystems, t.fs.GetName())
+	}
+	sort.Strings(s.Filesystems)
+
+	scans.Lock()
+	defer scans.Unlock()
+	for _, name := range s.Filesystems {
+		if _, ok := scans.byFS[name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrScanRunning, name)
+		}
+	}
+	for _, name := range s.Filesystems {
+		scans.byFS[name] = s.ID
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	scans.byID[s.ID] = s
+
+	return s, nil
+}
+
+func (s *Scan) release() {
+	s.cancel()
+	scans.Lock()
+	defer scans.Unlock()
+	delete(scans.byID, s.ID)
+	for _, name := range s.Filesystems {
+		if scans.byFS[name] == s.ID {
+			delete(scans.byFS, name)
+		}
+	}
+}
+
+func resolveScope(scope ScanScope) ([]scanTarget, error) {
+	fss := libfs.GetFSs()
+
+	if scope.AssetID != "" {
+		asset, err := database.GetAsset(scope.AssetID, false)
+		if err != nil {
+			return nil, err
+		}
+		f, ok := fss[asset.FSName]
+		if !ok || asset.NodeKind == entities.NodeKindBundled {
+			return nil, fmt.Errorf("%w: asset %s is not on a discoverable filesystem", ErrScanScope, asset.ID)
+		}
+		if scope.FS != "" && scope.FS != asset.FSName {
+			return nil, fmt.Errorf("%w: asset %s is not on filesystem %s", ErrScanScope, asset.ID, scope.FS)
+		}
+		return []scanTarget{{fs: f, root: &asset}}, nil
+	}
+
+	if scope.FS != "" {
+		f, ok := fss[scope.FS]
+		if !ok {
+			return nil, fmt.Errorf("%w: filesystem %s not found", ErrScanScope, scope.FS)
+		}
+		return []scanTarget{{fs: f}}, nil
+	}
+
+	targets := make([]scanTarget, 0, len(fss))
+	for _, f := range fss {
+		targets = append(targets, scanTarget{fs: f})
+	}
+	return targets, nil
+}
@@ -0,0 +1,96 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/system"
+)
+
+const syncEvent = "fs.sync"
+
+var (
+	ErrFSNotFound       = errors.New("filesystem not found")
+	ErrSyncNotSupported = errors.New("filesystem does not sync with a remote")
+)
+
+// SyncFS periodically syncs every filesystem that follows a remote and
+// discovers what the syncs changed, until the context is cancelled.
+func SyncFS(ctx context.Context, logger *zap.Logger) error {
+	eg, egCtx := errgroup.WithContext(ctx)
+	for _, f := range libfs.GetFSs() {
+		s, ok := f.(libfs.Syncer)
+		if !ok || s.SyncInterval() <= 0 {
+			continue
+		}
+		f := f
+		logger.Info("syncing filesystem", zap.String("fs", f.GetName()), zap.Duration("interval", s.SyncInterval()))
+
+		eg.Go(func() error {
+			ticker := time.NewTicker(s.SyncInterval())
+			defer ticker.Stop()
+			for {
+				if _, err := syncFS(egCtx, f, s, logger); err != nil && egCtx.Err() == nil {
+					logger.Warn("filesystem sync failed", zap.String("fs", f.GetName()), zap.Error(err))
+				}
+				select {
+				case <-egCtx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+	return eg.Wait()
+}
+
+// SyncNow syncs a filesystem right away and returns its sync status.
+func SyncNow(ctx context.Context, name string, logger *zap.Logger) (libfs.SyncStatus, error) {
+	f, ok := libfs.GetFSs()[name]
+	if !ok {
+		return libfs.SyncStatus{}, fmt.Errorf("%w: %s", ErrFSNotFound, name)
+	}
+	s, ok := f.(libfs.Syncer)
+	if !ok || s.SyncInterval() <= 0 {
+		return libfs.SyncStatus{}, fmt.Errorf("%w: %s", ErrSyncNotSupported, name)
+	}
+	return syncFS(ctx, f, s, logger)
+}
+
+// SyncStatuses returns the sync status of every filesystem following a remote.
+func SyncStatuses() []libfs.SyncStatus {
+	rtn := make([]libfs.SyncStatus, 0)
+	for _, f := range libfs.GetFSs() {
+		if s, ok := f.(libfs.Syncer); ok && s.SyncInterval() > 0 {
+			rtn = append(rtn, s.SyncStatus())
+		}
+	}
+	sort.Slice(rtn, func(i, j int) bool { return rtn[i].FS < rtn[j].FS })
+	return rtn
+}
+
+func syncFS(ctx context.Context, f libfs.LibFS, s libfs.Syncer, logger *zap.Logger) (libfs.SyncStatus, error) {
+	previous := s.SyncStatus()
+	changes, err := s.Sync(ctx)
+	status := s.SyncStatus()
+
+	if len(changes) > 0 {
+		logger.Info("filesystem synced", zap.String("fs", f.GetName()), zap.Int("changes", len(changes)))
+		if err := ApplyChanges(f, changes); err != nil {
+			logger.Warn("failed to apply synced changes", zap.String("fs", f.GetName()), zap.Error(err))
+		}
+	}
+
+	// Divergence and conflicts need someone to act, report every change of state
+	if err != nil || len(changes) > 0 || status.State != previous.State || status.Head != previous.Head {
+		system.Publish(syncEvent, status)
+	}
+	return status, err
+}
@@ -0,0 +1,113 @@
+package processing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/processing/discovery"
+	"github.com/eduardooliveira/stLib/core/runtime"
+)
+
+const defaultWatchDebounce = 2 * time.Second
+
+// WatchFS follows every watchable filesystem and feeds the changes into the
+// discoverer and processor until the context is cancelled.
+func WatchFS(ctx context.Context, logger *zap.Logger) error {
+	if !runtime.Cfg.Library.Watch {
+		logger.Info("filesystem watching disabled")
+		return nil
+	}
+
+	debounce := defaultWatchDebounce
+	if runtime.Cfg.Library.WatchDebounce > 0 {
+		debounce = time.Duration(runtime.Cfg.Library.WatchDebounce) * time.Millisecond
+	}
+
+	proc, err := NewProcessor(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize processor: %w", err)
+	}
+
+	discoverer := discovery.NewAssetDiscoverer(ctx, logger, &ProcessorWrapper{p: proc})
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for _, ffs := range libfs.GetFSs() {
+		f := ffs
+		if !libfs.IsWatchable(f) {
+			continue
+		}
+
+		changes, err := libfs.Watch(egCtx, f, debounce, discovery.ShouldSkipFile)
+		if err != nil {
+			logger.Error("failed to watch filesystem", zap.String("fs", f.GetName()), zap.Error(err))
+			continue
+		}
+		logger.Info("watching filesystem", zap.String("fs", f.GetName()))
+
+		eg.Go(func() error {
+			for batch := range changes {
+				logger.Debug("filesystem changes", zap.String("fs", f.GetName()), zap.Int("count", len(batch)))
+				// Changes made during a scan wait for it to finish
+				unlock := lockFS(f.GetName())
+				err := discoverer.DiscoverChanges(f, batch)
+				unlock()
+				if err != nil {
+					logger.Warn("failed to apply filesystem changes", zap.String("fs", f.GetName()), zap.Error(err))
+				}
+			}
+			return nil
+		})
+	}
+
+	return eg.Wait()
+}
+
+// ApplyChanges updates the asset tree after changes made to a filesystem from
+// within the agent, the same way the watcher does for outside ones.
+func ApplyChanges(f libfs.LibFS, changes []libfs.Change) error {
+	proc, err := NewProcessor(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to initialize processor: %w", err)
+	}
+
+	l := logger.GetLogger().With(zap.String("module", "changes"), zap.String("fs", f.GetName()))
+	discoverer := discovery.NewAssetDiscoverer(context.Background(), l, &ProcessorWrapper{p: proc})
+
+	container, bundlePath, ok := libfs.BundleContainer(f)
+	if !ok {
+		unlock := lockFS(f.GetName())
+		defer unlock()
+		return discoverer.DiscoverChanges(f, changes)
+	}
+
+	// Entries of a bundle hang off the bundle asset, rediscovering the rewritten
+	// archive in the filesystem holding it picks up new and changed ones. Only
+	// what's gone has to be removed here.
+	removed := make([]libfs.Change, 0)
+	for _, c := range changes {
+		switch c.Op {
+		case libfs.ChangeRemove:
+			removed = append(removed, c)
+		case libfs.ChangeMove:
+			removed = append(removed, libfs.Change{Op: libfs.ChangeRemove, Path: c.OldPath})
+		}
+	}
+	// Bundles are scanned with the filesystem holding them
+	top := container
+	for outer, _, ok := libfs.BundleContainer(top); ok; outer, _, ok = libfs.BundleContainer(top) {
+		top = outer
+	}
+	unlock := lockFS(top.GetName())
+	err = discoverer.DiscoverChanges(f, removed)
+	unlock()
+	if err != nil {
+		return err
+	}
+	return ApplyChanges(container, []libfs.Change{{Op: libfs.ChangeWrite, Path: bundlePath}})
+}
@@ -1,34 +1,227 @@
 package queue
 
 import (
-	"github.com/eduardooliveira/stLib/core/logger"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/logger"
+)
+
+// Handler runs a job. Returning an error schedules a retry with exponential
+// backoff until the job runs out of attempts.
+type Handler func(ctx context.Context, job *entities.Job) error
+
+const (
+	DefaultMaxAttempts = 3
+	pollInterval       = time.Second
+	baseBackoff        = 5 * time.Second
+	maxBackoff         = 10 * time.Minute
+	doneRetention      = 7 * 24 * time.Hour
 )
 
-type Job interface {
-	JobName() string
-	JobAction()
+var (
+	mu       sync.Mutex
+	handlers = make(map[string]Handler)
+	running  = make(map[string]context.CancelFunc)
+	wake     = make(chan struct{}, 1)
+)
+
+func RegisterHandler(kind string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[kind] = h
 }
 
-var queue = make(chan Job, 9999999)
+// Enqueue persists a job for the asset, unless one is already pending. It
+// returns the ID of the job that will do the work.
+func Enqueue(kind, assetID string) (string, error) {
+	job := entities.NewJob(kind, assetID, DefaultMaxAttempts)
+	created, err := database.EnqueueJob(job)
+	if err != nil {
+		return "", err
+	}
+	if created {
+		logger.GetLogger().Debug("job enqueued",
+			zap.String("job_kind", kind),
+			zap.String("asset_id", assetID),
+		)
+		notify()
+	}
+	return job.ID, nil
+}
 
-func init() {
-	go func() {
-		for {
-			job := <-queue
-			job.JobAction()
-			logger.GetLogger().Debug("job completed",
-				zap.Int("queue_size", len(queue)),
-				zap.String("job_name", job.JobName()),
-			)
+// Start resumes jobs interrupted by a previous shutdown and runs the workers
+// until the context is cancelled.
+func Start(ctx context.Context, workers int) error {
+	resumed, err := database.ResetRunningJobs()
+	if err != nil {
+		return fmt.Errorf("failed to reset running jobs: %w", err)
+	}
+	if resumed > 0 {
+		logger.GetLogger().Info("resuming interrupted jobs", zap.Int64("count", resumed))
+	}
+	if _, err := database.PruneJobs(time.Now().Add(-doneRetention)); err != nil {
+		logger.GetLogger().Warn("failed to prune finished jobs", zap.Error(err))
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Cancel stops a job, interrupting it if it's currently running.
+func Cancel(id string) (*entities.Job, error) {
+	job, err := database.CancelJob(id)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	if cancel, ok := running[id]; ok {
+		cancel()
+	}
+	mu.Unlock()
+	return job, nil
+}
+
+func Retry(id string) (*entities.Job, error) {
+	job, err := database.RetryJob(id)
+	if err != nil {
+		return nil, err
+	}
+	notify()
+	return job, nil
+}
+
+// Depth returns the number of jobs of a kind waiting or running.
+func Depth(kind string) int64 {
+	var total int64
+	for _, state := range []entities.JobState{entities.JobPending, entities.JobRunning} {
+		count, err := database.CountJobs(database.JobFilter{State: string(state), Kind: kind})
+		if err != nil {
+			logger.GetLogger().Warn("failed to count jobs", zap.String("job_kind", kind), zap.Error(err))
+			continue
 		}
-	}()
+		total += count
+	}
+	return total
 }
 
-func Enqueue(job Job) {
-	queue <- job
-	logger.GetLogger().Debug("job enqueued",
-		zap.Int("queue_size", len(queue)),
-		zap.String("job_name", job.JobName()),
+// WaitIdle blocks until there are no pending or running jobs left.
+func WaitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if Depth("") == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := database.ClaimJob()
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.GetLogger().Error("failed to claim job", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case <-ticker.C:
+			}
+			continue
+		}
+		// Let other idle workers pick up the rest of the queue
+		notify()
+		run(ctx, job)
+	}
+}
+
+func run(ctx context.Context, job *entities.Job) {
+	l := logger.GetLogger().With(
+		zap.String("job_id", job.ID),
+		zap.String("job_kind", job.Kind),
+		zap.String("asset_id", job.AssetID),
 	)
+
+	mu.Lock()
+	h, ok := handlers[job.Kind]
+	jobCtx, cancel := context.WithCancel(ctx)
+	running[job.ID] = cancel
+	mu.Unlock()
+
+	defer func() {
+		mu.Lock()
+		delete(running, job.ID)
+		mu.Unlock()
+		cancel()
+	}()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler for job kind %s", job.Kind)
+	} else {
+		err = h(jobCtx, job)
+	}
+
+	if ctx.Err() != nil {
+		// Shutting down, the job stays running and is resumed on the next start
+		return
+	}
+
+	if current, getErr := database.GetJob(job.ID); getErr == nil && current.State == entities.JobCancelled {
+		l.Debug("job cancelled")
+		return
+	}
+
+	if err == nil {
+		if err := database.CompleteJob(job.ID); err != nil {
+			l.Error("failed to complete job", zap.Error(err))
+		}
+		return
+	}
+
+	backoff := baseBackoff << (job.Attempts - 1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	l.Warn("job failed", zap.Int("attempt", job.Attempts), zap.Duration("retry_in", backoff), zap.Error(err))
+	if err := database.FailJob(job, err, backoff); err != nil {
+		l.Error("failed to record job failure", zap.Error(err))
+	}
 }
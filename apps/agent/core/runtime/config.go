@@ -32,11 +32,17 @@ type Config struct {
 		Blacklist      []string    `json:"blacklist" mapstructure:"blacklist"`
 		IgnoreDotFiles bool        `json:"ignore_dot_files" mapstructure:"ignore_dot_files"`
 		RenderBundles  bool        `json:"render_bundles" mapstructure:"render_bundles"`
+		HashFiles      bool        `json:"hash_files" mapstructure:"hash_files"`
+		Watch          bool        `json:"watch" mapstructure:"watch"`
+		WatchDebounce  int         `json:"watch_debounce" mapstructure:"watch_debounce"`   // milliseconds
+		TrashRetention int         `json:"trash_retention" mapstructure:"trash_retention"` // days, 0 keeps deleted assets forever
 	} `json:"library" mapstructure:"library"`
 	Render struct {
 		MaxWorkers      int    `json:"max_workers" mapstructure:"max_workers"`
 		ModelColor      string `json:"model_color" mapstructure:"model_color"`
 		BackgroundColor string `json:"background_color" mapstructure:"background_color"`
+		// Presets are the views rendered for every model, the first one is its thumbnail
+		Presets []RenderPreset `json:"presets" mapstructure:"presets"`
 	} `json:"render" mapstructure:"render"`
 	Integrations struct {
 		Thingiverse struct {
@@ -55,6 +61,20 @@ type FileSystem struct {
 
 type FileSystems []FileSystem
 
+// RenderPreset describes one generated view of a model. The model is fit in a
+// cube two units wide centered at the origin, Eye is in those units.
+type RenderPreset struct {
+	Name   string    `json:"name" mapstructure:"name"`
+	Width  int       `json:"width" mapstructure:"width"`
+	Height int       `json:"height" mapstructure:"height"`
+	View   string    `json:"view" mapstructure:"view"`     // perspective, front, top or side
+	Eye    []float64 `json:"eye" mapstructure:"eye"`       // camera position of perspective views
+	Fovy   float64   `json:"fovy" mapstructure:"fovy"`     // degrees, perspective views only
+	Frames int       `json:"frames" mapstructure:"frames"` // turntable frames, a still image when 0
+	Format string    `json:"format" mapstructure:"format"` // gif or apng, for turntables
+	Delay  int       `json:"delay" mapstructure:"delay"`   // milliseconds per turntable frame
+}
+
 var Cfg *Config
 
 var dataPath = "/data"
@@ -127,10 +147,17 @@ func init() {
 	viper.SetDefault("library.blacklist", []string{})
 	viper.SetDefault("library.ignore_dot_files", true)
 	viper.SetDefault("library.render_bundles", false)
+	viper.SetDefault("library.hash_files", false)
+	viper.SetDefault("library.watch", true)
+	viper.SetDefault("library.watch_debounce", 2000)
+	viper.SetDefault("library.trash_retention", 30)
 	viper.SetDefault("library.file_systems", []map[string]any{
 		{"name": "default", "path": libDefault, "kind": "local", "default": true},
 	})
 	viper.SetDefault("render.max_workers", 5)
+	viper.SetDefault("render.presets", []map[string]any{
+		{"name": "default", "width": 1920, "height": 1080, "view": "perspective", "eye": []float64{-3, -3, -0.75}, "fovy": 30},
+	})
 	viper.SetDefault("core.log.enable_file", false)
 
 	viper.SetDefault("server.hostname", "localhost")
@@ -152,7 +179,7 @@ func init() {
 		cfg.Library.Path = viper.GetString("LIBRARY_PATH")
 	}
 
-	cfg.Library.Blacklist = append(cfg.Library.Blacklist, ".project.stlib", ".thumb.png", ".render.png")
+	cfg.Library.Blacklist = append(cfg.Library.Blacklist, ".project.stlib", ".thumb.png", ".render.png", ".mmp.json")
 
 	configExists := true
 	if _, err := os.Stat(path.Join(dataPath, "config.toml")); os.IsNotExist(err) {
@@ -0,0 +1,266 @@
+package slicer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+)
+
+const modelSettings = "Metadata/model_settings.config" // Bambu Studio and Orca
+
+type rootModel struct {
+	Objects []struct {
+		ID   string `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	} `xml:"resources>object"`
+	Items []struct {
+		ObjectID string `xml:"objectid,attr"`
+	} `xml:"build>item"`
+}
+
+type modelSettingsConfig struct {
+	Objects []struct {
+		ID       string            `xml:"id,attr"`
+		Metadata []keyValue        `xml:"metadata"`
+		Parts    []metadataElement `xml:"part"`
+	} `xml:"object"`
+	Plates []struct {
+		Metadata  []keyValue        `xml:"metadata"`
+		Instances []metadataElement `xml:"model_instance"`
+	} `xml:"plate"`
+}
+
+type metadataElement struct {
+	Metadata []keyValue `xml:"metadata"`
+}
+
+// plateJSON is the plate_<n>.json Bambu Studio and Orca write when slicing,
+// filament ids are slots numbered from 0.
+type plateJSON struct {
+	FilamentIDs    []int    `json:"filament_ids"`
+	FilamentColors []string `json:"filament_colors"`
+}
+
+// project is what the files of a 3MF project say about its objects and
+// filament slots.
+type project struct {
+	fsys    fs.FS
+	names   map[string]string
+	slots   map[string][]int
+	types   []string
+	colors  []string
+	resolve func(path string) string
+}
+
+// Plates3MF reads the plates of a 3MF project, with the objects they hold and
+// the filaments they use. Projects without plates, like those of PrusaSlicer,
+// get one plate holding the build. resolve maps the thumbnail and G-code files
+// of plates to what the plates record, files it maps to "" are left out.
+func Plates3MF(fsys fs.FS, resolve func(path string) string) ([]entities.SlicePlate, error) {
+	var model rootModel
+	b, err := fs.ReadFile(fsys, modelFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := xml.Unmarshal(b, &model); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", modelFile, err)
+	}
+
+	settings := &modelSettingsConfig{}
+	if b, err := fs.ReadFile(fsys, modelSettings); err == nil {
+		if err := xml.Unmarshal(b, settings); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", modelSettings, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	info, err := loadSliceInfo(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewParser()
+	if err := readProjectSettings(fsys, p); err != nil {
+		return nil, err
+	}
+	pr := &project{
+		fsys:    fsys,
+		names:   make(map[string]string),
+		slots:   make(map[string][]int),
+		types:   p.list("filament_type"),
+		colors:  p.list("filament_colour"),
+		resolve: resolve,
+	}
+	for _, o := range model.Objects {
+		pr.names[o.ID] = o.Name
+	}
+	for _, o := range settings.Objects {
+		if name := value(o.Metadata, "name"); name != "" {
+			pr.names[o.ID] = name
+		}
+		// Parts may print with another filament than their object
+		for _, e := range append([]metadataElement{{Metadata: o.Metadata}}, o.Parts...) {
+			if slot, err := strconv.Atoi(value(e.Metadata, "extruder")); err == nil && slot > 0 && !slices.Contains(pr.slots[o.ID], slot) {
+				pr.slots[o.ID] = append(pr.slots[o.ID], slot)
+			}
+		}
+	}
+
+	var rtn []entities.SlicePlate
+	for i, sp := range settings.Plates {
+		index, err := strconv.Atoi(value(sp.Metadata, "plater_id"))
+		if err != nil {
+			index = i + 1
+		}
+		plate := entities.SlicePlate{
+			Index:     index,
+			Name:      value(sp.Metadata, "plater_name"),
+			Thumbnail: pr.file(value(sp.Metadata, "thumbnail_file"), fmt.Sprintf("Metadata/plate_%d.png", index)),
+			GCode:     pr.file(value(sp.Metadata, "gcode_file"), fmt.Sprintf("Metadata/plate_%d.gcode", index)),
+		}
+		for _, inst := range sp.Instances {
+			plate.Objects = pr.addObject(plate.Objects, value(inst.Metadata, "object_id"))
+		}
+		rtn = append(rtn, plate)
+	}
+	if len(rtn) == 0 {
+		plate := entities.SlicePlate{
+			Index:     1,
+			Thumbnail: pr.file("Metadata/plate_1.png", "Metadata/thumbnail.png"),
+			GCode:     pr.file("Metadata/plate_1.gcode"),
+		}
+		for _, item := range model.Items {
+			plate.Objects = pr.addObject(plate.Objects, item.ObjectID)
+		}
+		rtn = append(rtn, plate)
+	}
+
+	for i := range rtn {
+		if err := pr.filaments(&rtn[i], info); err != nil {
+			return nil, err
+		}
+	}
+	return rtn, nil
+}
+
+func (pr *project) addObject(objects []entities.SlicePlateObject, id string) []entities.SlicePlateObject {
+	if id == "" {
+		return objects
+	}
+	if i := slices.IndexFunc(objects, func(o entities.SlicePlateObject) bool { return o.ID == id }); i >= 0 {
+		objects[i].Instances++
+		return objects
+	}
+	name := pr.names[id]
+	if name == "" {
+		name = "object " + id
+	}
+	return append(objects, entities.SlicePlateObject{ID: id, Name: name, Instances: 1})
+}
+
+// file resolves the first of the paths found in the project.
+func (pr *project) file(paths ...string) string {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := fs.Stat(pr.fsys, path); err == nil {
+			return pr.resolve(path)
+		}
+	}
+	return ""
+}
+
+// filaments sets the filaments of a plate from the best source there is: the
+// slice info of sliced plates, their plate_<n>.json, or else the filaments
+// assigned to their objects.
+func (pr *project) filaments(plate *entities.SlicePlate, info *sliceInfoConfig) error {
+	if info != nil {
+		for _, ip := range info.Plates {
+			if value(ip.Metadata, "index") != strconv.Itoa(plate.Index) {
+				continue
+			}
+			plate.EstimatedTime, _ = strconv.ParseFloat(value(ip.Metadata, "prediction"), 64)
+			plate.Weight, _ = strconv.ParseFloat(value(ip.Metadata, "weight"), 64)
+			for _, f := range ip.Filaments {
+				slot, _ := strconv.Atoi(f.ID)
+				weight, _ := strconv.ParseFloat(f.UsedG, 64)
+				plate.Filaments = append(plate.Filaments, entities.SlicePlateFilament{Slot: slot, Type: f.Type, Color: f.Color, Weight: weight})
+			}
+		}
+		if len(plate.Filaments) > 0 {
+			return nil
+		}
+	}
+
+	var slots []int
+	var colors []string
+	b, err := fs.ReadFile(pr.fsys, fmt.Sprintf("Metadata/plate_%d.json", plate.Index))
+	switch {
+	case err == nil:
+		var pj plateJSON
+		if err := json.Unmarshal(b, &pj); err != nil {
+			return fmt.Errorf("failed to read plate %d: %w", plate.Index, err)
+		}
+		for _, id := range pj.FilamentIDs {
+			slots = append(slots, id+1)
+		}
+		colors = pj.FilamentColors
+	case errors.Is(err, fs.ErrNotExist):
+		for _, o := range plate.Objects {
+			objSlots := pr.slots[o.ID]
+			if len(objSlots) == 0 {
+				objSlots = []int{1}
+			}
+			for _, s := range objSlots {
+				if !slices.Contains(slots, s) {
+					slots = append(slots, s)
+				}
+			}
+		}
+		slices.Sort(slots)
+	default:
+		return err
+	}
+
+	for i, s := range slots {
+		// Malformed ids would index before the first slot
+		if s < 1 {
+			continue
+		}
+		f := entities.SlicePlateFilament{Slot: s}
+		if s <= len(pr.types) {
+			f.Type = pr.types[s-1]
+		}
+		switch {
+		case i < len(colors):
+			f.Color = colors[i]
+		case s <= len(pr.colors):
+			f.Color = pr.colors[s-1]
+		}
+		// Projects without filament settings only tell slots apart
+		if f.Type != "" || f.Color != "" {
+			plate.Filaments = append(plate.Filaments, f)
+		}
+	}
+	return nil
+}
+
+func value(kvs []keyValue, key string) string {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package slicer
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+)
+
+func TestPlatesSkipInvalidFilamentIDs(t *testing.T) {
+	fsys := fstest.MapFS{
+		modelFile: {Data: []byte(`<model><resources><object id="1" name="cube"/></resources><build><item objectid="1"/></build></model>`)},
+		modelSettings: {Data: []byte(`<config>
+  <plate>
+    <metadata key="plater_id" value="1"/>
+    <model_instance><metadata key="object_id" value="1"/></model_instance>
+  </plate>
+</config>`)},
+		projectSettings:         {Data: []byte(`{"filament_type": ["PLA", "PETG"], "filament_colour": ["#FF0000", "#00FF00"]}`)},
+		"Metadata/plate_1.json": {Data: []byte(`{"filament_ids": [-1, 1], "filament_colors": ["#000000", "#00FF00"]}`)},
+	}
+
+	plates, err := Plates3MF(fsys, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plates) != 1 {
+		t.Fatalf("got %d plates, want 1", len(plates))
+	}
+	want := []entities.SlicePlateFilament{{Slot: 2, Type: "PETG", Color: "#00FF00"}}
+	if !reflect.DeepEqual(plates[0].Filaments, want) {
+		t.Errorf("filaments = %+v, want %+v", plates[0].Filaments, want)
+	}
+}
@@ -0,0 +1,283 @@
+package slicer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+)
+
+var durationPart = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*([dhms])`)
+
+// curaUnescape undoes the escaping Cura adds on top of the JSON of its
+// settings so they fit in comments.
+var curaUnescape = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+// Parser collects the settings slicers write in G-code comments and in their
+// config files, Metadata maps them to the same keys for every slicer.
+type Parser struct {
+	slicer   string
+	version  string
+	settings map[string]string
+	// Cura writes its settings at the end of the file, JSON split across
+	// ;SETTING_3 comments
+	cura strings.Builder
+}
+
+func NewParser() *Parser {
+	return &Parser{settings: make(map[string]string)}
+}
+
+// Comment reads a G-code comment without its leading ';' and otherwise as it
+// is in the file. Settings are written as "key = value" by PrusaSlicer and its
+// forks and as "key:value" by Cura, Bambu Studio and Orca put several of the
+// latter on a line.
+func (p *Parser) Comment(line string) {
+	// Cura splits its settings in fixed width chunks, spaces at their edges
+	// are part of them
+	if rest, ok := strings.CutPrefix(line, "SETTING_3 "); ok {
+		p.cura.WriteString(rest)
+		return
+	}
+	line = strings.TrimSpace(line)
+	if p.identify(line) {
+		return
+	}
+
+	if key, value, ok := strings.Cut(line, " = "); ok {
+		p.Set(key, value)
+		return
+	}
+	for _, part := range strings.Split(line, "; ") {
+		if key, value, ok := strings.Cut(part, ":"); ok {
+			p.Set(key, value)
+		}
+	}
+}
+
+// identify picks the slicer from the lines naming it.
+func (p *Parser) identify(line string) bool {
+	lower := strings.ToLower(line)
+	switch {
+	case strings.HasPrefix(lower, "generated by "), strings.HasPrefix(lower, "generated with "):
+		// generated by PrusaSlicer 2.6.0+win64 on 2023-08-01 at 10:00:00 UTC
+		if f := strings.Fields(line); len(f) >= 4 {
+			p.SetSlicer(f[2], f[3])
+		}
+		return true
+	case strings.HasPrefix(line, "BambuStudio "):
+		if f := strings.Fields(line); len(f) == 2 {
+			p.SetSlicer(f[0], f[1])
+			return true
+		}
+	case strings.HasPrefix(line, "SuperSlicer_config"):
+		p.SetSlicer("SuperSlicer", "")
+		return true
+	}
+	return false
+}
+
+// SetSlicer records the slicer, the first one found is kept.
+func (p *Parser) SetSlicer(name, version string) {
+	if p.slicer != "" {
+		return
+	}
+	if name == "Cura_SteamEngine" {
+		name = "Cura"
+	}
+	p.slicer, p.version = name, version
+}
+
+// Set records a setting, keys are matched ignoring case.
+func (p *Parser) Set(key, value string) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return
+	}
+	p.settings[key] = strings.Trim(strings.TrimSpace(value), `"`)
+}
+
+// SetList records a setting with a value per extruder.
+func (p *Parser) SetList(key string, values []string) {
+	p.Set(key, strings.Join(values, ";"))
+}
+
+// Metadata maps the settings read so far, it returns nil when none of them
+// is known.
+func (p *Parser) Metadata() (*entities.SliceMetadata, error) {
+	if err := p.readCura(); err != nil {
+		return nil, err
+	}
+
+	m := &entities.SliceMetadata{
+		Slicer:        p.slicer,
+		SlicerVersion: p.version,
+		PrinterModel:  p.first("printer_model", "target_machine.name", "printer_settings_id"),
+		LayerHeight:   p.float("layer_height", "layer height"),
+		InfillDensity: p.percent("fill_density", "sparse_infill_density", "infill_sparse_density"),
+		Supports:      p.bool("support_material", "enable_support", "support_enable"),
+		EstimatedTime: p.duration("estimated printing time (normal mode)", "total estimated time", "estimated printing time", "time", "print.time", "prediction"),
+		Weight:        p.float("total filament used [g]", "total filament weight [g]", "weight"),
+	}
+	if m.Weight == 0 {
+		for _, w := range p.floats("filament used [g]") {
+			m.Weight += w
+		}
+	}
+
+	nozzles := p.floats("nozzle_diameter", "machine_nozzle_size")
+	for i := 0; ; i++ {
+		d := p.float(fmt.Sprintf("extruder_train.%d.nozzle.diameter", i))
+		if d == 0 {
+			break
+		}
+		if i < len(nozzles) {
+			nozzles[i] = d
+		} else {
+			nozzles = append(nozzles, d)
+		}
+	}
+	types := p.list("filament_type")
+	colors := p.list("filament_colour", "filament_color", "extruder_colour")
+	for i := 0; i < max(len(nozzles), len(types), len(colors)); i++ {
+		var e entities.SliceExtruder
+		switch {
+		case i < len(nozzles):
+			e.NozzleDiameter = nozzles[i]
+		case len(nozzles) == 1:
+			// Filament slots sharing a nozzle
+			e.NozzleDiameter = nozzles[0]
+		}
+		if i < len(types) {
+			e.FilamentType = types[i]
+		}
+		if i < len(colors) {
+			e.FilamentColor = strings.ToUpper(colors[i])
+		}
+		m.Extruders = append(m.Extruders, e)
+	}
+	if len(nozzles) > 0 {
+		m.NozzleDiameter = nozzles[0]
+	}
+
+	if reflect.ValueOf(*m).IsZero() {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// readCura reads the profiles Cura embeds, INI files in a JSON object. The
+// values of the first extruder override the global ones.
+func (p *Parser) readCura() error {
+	if p.cura.Len() == 0 {
+		return nil
+	}
+	var profiles struct {
+		Global    string   `json:"global_quality"`
+		Extruders []string `json:"extruder_quality"`
+	}
+	if err := json.Unmarshal([]byte(curaUnescape.Replace(p.cura.String())), &profiles); err != nil {
+		return fmt.Errorf("failed to read cura settings: %w", err)
+	}
+	p.cura.Reset()
+
+	blocks := []string{profiles.Global}
+	if len(profiles.Extruders) > 0 {
+		blocks = append(blocks, profiles.Extruders[0])
+	}
+	for _, block := range blocks {
+		section := ""
+		scanner := bufio.NewScanner(strings.NewReader(block))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "[") {
+				section = strings.Trim(line, "[]")
+				continue
+			}
+			key, value, ok := strings.Cut(line, " = ")
+			// Values starting with = are formulas
+			if ok && section == "values" && !strings.HasPrefix(value, "=") {
+				p.Set(key, value)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Parser) first(keys ...string) string {
+	for _, k := range keys {
+		if v := p.settings[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (p *Parser) float(keys ...string) float64 {
+	v, _ := strconv.ParseFloat(p.first(keys...), 64)
+	return v
+}
+
+func (p *Parser) list(keys ...string) []string {
+	v := p.first(keys...)
+	if v == "" {
+		return nil
+	}
+	return strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == ',' })
+}
+
+func (p *Parser) floats(keys ...string) []float64 {
+	var rtn []float64
+	for _, s := range p.list(keys...) {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil
+		}
+		rtn = append(rtn, v)
+	}
+	return rtn
+}
+
+func (p *Parser) percent(keys ...string) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(p.first(keys...), "%"), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func (p *Parser) bool(keys ...string) *bool {
+	v, err := strconv.ParseBool(p.first(keys...))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// duration reads seconds or times like "1d 2h 3m 4s".
+func (p *Parser) duration(keys ...string) float64 {
+	s := p.first(keys...)
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v
+	}
+	var rtn float64
+	for _, m := range durationPart.FindAllStringSubmatch(s, -1) {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "d":
+			rtn += v * 86400
+		case "h":
+			rtn += v * 3600
+		case "m":
+			rtn += v * 60
+		case "s":
+			rtn += v
+		}
+	}
+	return rtn
+}
@@ -0,0 +1,167 @@
+package slicer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+)
+
+const prusaGCode = `; generated by PrusaSlicer 2.6.1+linux-x64-GTK3 on 2023-10-02 at 18:11:04 UTC
+
+; external perimeters extrusion width = 0.45mm
+; perimeters extrusion width = 0.45mm
+
+M73 P0 R62
+G1 Z.2 F720
+G1 X10 Y10 E.5
+; filament used [mm] = 4012.51
+; filament used [cm3] = 9.65
+; total filament used [g] = 12.17
+; estimated printing time (normal mode) = 1h 2m 3s
+
+; prusaslicer_config = begin
+; fill_density = 15%
+; filament_colour = #FF8000
+; filament_type = PETG
+; layer_height = 0.2
+; nozzle_diameter = 0.4
+; printer_model = MK4
+; support_material = 0
+; prusaslicer_config = end
+`
+
+const bambuGCode = `; HEADER_BLOCK_START
+; BambuStudio 01.08.04.51
+; model printing time: 1h 6m 21s; total estimated time: 1h 12m 37s
+; total layer number: 75
+; total filament length [mm] : 3017.99
+; total filament volume [cm^3] : 7259.27
+; total filament weight [g] : 9.00
+; filament_density: 1.24,1.24
+; filament_diameter: 1.75,1.75
+; max_z_height: 15.00
+; HEADER_BLOCK_END
+
+; CONFIG_BLOCK_START
+; enable_support = 1
+; filament_colour = #00ae42;#FFFFFF
+; filament_type = PLA;PETG
+; layer_height = 0.2
+; nozzle_diameter = 0.4
+; printer_model = Bambu Lab X1 Carbon
+; sparse_infill_density = 15%
+; CONFIG_BLOCK_END
+`
+
+// Cura escapes the JSON of its settings again and cuts it in chunks of 69
+// characters, one of them starting with the " = " of infill_sparse_density.
+const curaGCode = `;FLAVOR:Marlin
+;TIME:6666
+;Filament used: 1.23456m
+;Layer height: 0.16
+;MINX:95.2
+;Generated with Cura_SteamEngine 5.4.0
+M140 S60
+;LAYER_COUNT:120
+;LAYER:0
+G0 F6000 X100 Y100 Z0.3
+;End of Gcode
+;SETTING_3 {"global_quality": "[general]\\nversion = 4\\nname = Low Quality #1\\
+;SETTING_3 ndefinition = creality_ender3\\n\\n[metadata]\\ntype = quality_change
+;SETTING_3 s\\nquality_type = standard\\nsetting_version = 22\\n\\n[values]\\nad
+;SETTING_3 hesion_type = skirt\\nlayer_height = 0.16\\nsupport_enable = True\\n\
+;SETTING_3 \n", "extruder_quality": ["[general]\\nversion = 4\\nname = Low Quali
+;SETTING_3 ty #1\\ndefinition = creality_ender3\\n\\n[metadata]\\ntype = quality
+;SETTING_3 _changes\\nquality_type = standard\\nintent_category = default\\nposi
+;SETTING_3 tion = 0\\nsetting_version = 22\\n\\n[values]\\ninfill_sparse_density
+;SETTING_3  = 20\\nmachine_nozzle_size = 0.6\\nwall_thickness = =line_width * 2\
+;SETTING_3 \n\\n"]}
+`
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// readGCode feeds the comments of a file to a parser the way the G-code
+// enricher does.
+func readGCode(t *testing.T, gcode string) *entities.SliceMetadata {
+	t.Helper()
+	p := NewParser()
+	for _, line := range strings.Split(gcode, "\n") {
+		if comment, ok := strings.CutPrefix(line, ";"); ok {
+			p.Comment(comment)
+		}
+	}
+	m, err := p.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMetadataFromGCode(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		gcode string
+		want  *entities.SliceMetadata
+	}{
+		{
+			name:  "PrusaSlicer",
+			gcode: prusaGCode,
+			want: &entities.SliceMetadata{
+				Slicer:         "PrusaSlicer",
+				SlicerVersion:  "2.6.1+linux-x64-GTK3",
+				PrinterModel:   "MK4",
+				NozzleDiameter: 0.4,
+				Extruders:      []entities.SliceExtruder{{NozzleDiameter: 0.4, FilamentType: "PETG", FilamentColor: "#FF8000"}},
+				LayerHeight:    0.2,
+				InfillDensity:  ptr(15.0),
+				Supports:       ptr(false),
+				EstimatedTime:  3723,
+				Weight:         12.17,
+			},
+		},
+		{
+			name:  "BambuStudio",
+			gcode: bambuGCode,
+			want: &entities.SliceMetadata{
+				Slicer:         "BambuStudio",
+				SlicerVersion:  "01.08.04.51",
+				PrinterModel:   "Bambu Lab X1 Carbon",
+				NozzleDiameter: 0.4,
+				Extruders: []entities.SliceExtruder{
+					{NozzleDiameter: 0.4, FilamentType: "PLA", FilamentColor: "#00AE42"},
+					{NozzleDiameter: 0.4, FilamentType: "PETG", FilamentColor: "#FFFFFF"},
+				},
+				LayerHeight:   0.2,
+				InfillDensity: ptr(15.0),
+				Supports:      ptr(true),
+				EstimatedTime: 4357,
+				Weight:        9,
+			},
+		},
+		{
+			name:  "Cura",
+			gcode: curaGCode,
+			want: &entities.SliceMetadata{
+				Slicer:         "Cura",
+				SlicerVersion:  "5.4.0",
+				NozzleDiameter: 0.6,
+				Extruders:      []entities.SliceExtruder{{NozzleDiameter: 0.6}},
+				LayerHeight:    0.16,
+				InfillDensity:  ptr(20.0),
+				Supports:       ptr(true),
+				EstimatedTime:  6666,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := readGCode(t, tc.gcode)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("metadata = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package slicer
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/eduardooliveira/stLib/core/entities"
+)
+
+const (
+	modelFile       = "3D/3dmodel.model"
+	projectSettings = "Metadata/project_settings.config" // Bambu Studio and Orca
+	sliceInfo       = "Metadata/slice_info.config"       // Bambu Studio and Orca, once sliced
+	prusaConfig     = "Metadata/Slic3r_PE.config"        // PrusaSlicer and its forks
+)
+
+type sliceInfoConfig struct {
+	Header []keyValue `xml:"header>header_item"`
+	Plates []struct {
+		Metadata  []keyValue `xml:"metadata"`
+		Filaments []struct {
+			ID    string `xml:"id,attr"`
+			Type  string `xml:"type,attr"`
+			Color string `xml:"color,attr"`
+			UsedG string `xml:"used_g,attr"`
+		} `xml:"filament"`
+	} `xml:"plate"`
+}
+
+type keyValue struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// From3MF reads the settings slicers save in 3MF projects. It returns nil for
+// projects no slicer saved settings in.
+func From3MF(fsys fs.FS) (*entities.SliceMetadata, error) {
+	p := NewParser()
+	if err := readApplication(fsys, p); err != nil {
+		return nil, err
+	}
+	if err := readProjectSettings(fsys, p); err != nil {
+		return nil, err
+	}
+	if err := readPrusaConfig(fsys, p); err != nil {
+		return nil, err
+	}
+	if err := readSliceInfo(fsys, p); err != nil {
+		return nil, err
+	}
+	return p.Metadata()
+}
+
+// readApplication reads the slicer from the model metadata, written like
+// "BambuStudio-01.08.04.51" before the resources of the model.
+func readApplication(fsys fs.FS, p *Parser) error {
+	f, err := fsys.Open(modelFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := xml.NewDecoder(f)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", modelFile, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "resources":
+			return nil
+		case "metadata":
+			if attr(start, "name") != "Application" {
+				continue
+			}
+			var app string
+			if err := d.DecodeElement(&app, &start); err != nil {
+				return fmt.Errorf("failed to read %s: %w", modelFile, err)
+			}
+			name, version, _ := strings.Cut(strings.TrimSpace(app), "-")
+			p.SetSlicer(name, version)
+		}
+	}
+}
+
+func readProjectSettings(fsys fs.FS, p *Parser) error {
+	b, err := fs.ReadFile(fsys, projectSettings)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var settings map[string]any
+	if err := json.Unmarshal(b, &settings); err != nil {
+		return fmt.Errorf("failed to read %s: %w", projectSettings, err)
+	}
+	for k, v := range settings {
+		switch v := v.(type) {
+		case string:
+			p.Set(k, v)
+		case []any:
+			values := make([]string, 0, len(v))
+			for _, e := range v {
+				values = append(values, fmt.Sprint(e))
+			}
+			p.SetList(k, values)
+		}
+	}
+	return nil
+}
+
+// readPrusaConfig reads the config PrusaSlicer saves with projects, lines
+// like those at the end of its G-code.
+func readPrusaConfig(fsys fs.FS, p *Parser) error {
+	f, err := fsys.Open(prusaConfig)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		p.Comment(strings.TrimPrefix(scanner.Text(), ";"))
+	}
+	return scanner.Err()
+}
+
+// readSliceInfo reads the results of the last slicing, the time and weight
+// add up over the plates.
+func readSliceInfo(fsys fs.FS, p *Parser) error {
+	info, err := loadSliceInfo(fsys)
+	if err != nil || info == nil {
+		return err
+	}
+
+	for _, h := range info.Header {
+		if h.Key == "X-BBL-Client-Version" && p.version == "" {
+			p.version = h.Value
+			if p.slicer == "" {
+				p.slicer = "BambuStudio"
+			}
+		}
+	}
+
+	var prediction, weight float64
+	var types, colors []string
+	used := make(map[string]bool)
+	for _, plate := range info.Plates {
+		for _, m := range plate.Metadata {
+			v, _ := strconv.ParseFloat(m.Value, 64)
+			switch m.Key {
+			case "prediction":
+				prediction += v
+			case "weight":
+				weight += v
+			}
+		}
+		for _, f := range plate.Filaments {
+			if used[f.ID] {
+				continue
+			}
+			used[f.ID] = true
+			types = append(types, f.Type)
+			colors = append(colors, f.Color)
+		}
+	}
+	if prediction > 0 {
+		p.Set("prediction", strconv.FormatFloat(prediction, 'f', -1, 64))
+	}
+	if weight > 0 {
+		p.Set("weight", strconv.FormatFloat(weight, 'f', -1, 64))
+	}
+	// The filaments the plates use win over the project settings, those list
+	// every slot
+	if len(types) > 0 {
+		p.SetList("filament_type", types)
+		p.SetList("filament_colour", colors)
+	}
+	return nil
+}
+
+// loadSliceInfo reads the slice info of a project, nil when it has none.
+func loadSliceInfo(fsys fs.FS) (*sliceInfoConfig, error) {
+	b, err := fs.ReadFile(fsys, sliceInfo)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info sliceInfoConfig
+	if err := xml.Unmarshal(b, &info); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sliceInfo, err)
+	}
+	return &info, nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
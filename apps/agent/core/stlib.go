@@ -9,9 +9,12 @@ import (
 
 	assettypes "github.com/eduardooliveira/stLib/core/api/assetTypes"
 	"github.com/eduardooliveira/stLib/core/api/assets"
+	"github.com/eduardooliveira/stLib/core/api/dav"
+	"github.com/eduardooliveira/stLib/core/api/jobs"
 	"github.com/eduardooliveira/stLib/core/api/system"
 	"github.com/eduardooliveira/stLib/core/api/tags"
 	"github.com/eduardooliveira/stLib/core/api/tempfiles"
+	apitrash "github.com/eduardooliveira/stLib/core/api/trash"
 	"github.com/eduardooliveira/stLib/core/downloader"
 	"github.com/eduardooliveira/stLib/core/events"
 	"github.com/eduardooliveira/stLib/core/integrations/printers"
@@ -24,6 +27,7 @@ import (
 	"github.com/eduardooliveira/stLib/core/processing"
 	"github.com/eduardooliveira/stLib/core/runtime"
 	"github.com/eduardooliveira/stLib/core/state"
+	"github.com/eduardooliveira/stLib/core/trash"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -45,12 +49,15 @@ func Run(ctx context.Context, logger *zap.Logger) error {
 		return fmt.Errorf("failed to load printers: %w", err)
 	}
 
+	processing.Init()
+
 	e := echo.New()
 	e.Use(middleware.CORS())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
 	slicer.Register(e.Group(""))
+	dav.Register(e.Group("/dav"))
 
 	api := e.Group("/api")
 	events.Register(api.Group("/events"))
@@ -61,6 +68,8 @@ func Run(ctx context.Context, logger *zap.Logger) error {
 	downloader.Register(api.Group("/downloader"))
 	system.Register(api.Group("/system"))
 	assettypes.Register(api.Group("/assettypes"))
+	jobs.Register(api.Group("/jobs"))
+	apitrash.Register(api.Group("/trash"))
 
 	serverAddr := fmt.Sprintf(":%d", runtime.Cfg.Server.Port)
 	server := &http.Server{
@@ -70,15 +79,47 @@ func Run(ctx context.Context, logger *zap.Logger) error {
 
 	g, gCtx := errgroup.WithContext(ctx)
 
+	g.Go(func() error {
+		logger.Info("starting job queue")
+		if err := processing.Start(gCtx); err != nil {
+			return fmt.Errorf("job queue failed: %w", err)
+		}
+		return nil
+	})
+
 	g.Go(func() error {
 		logger.Info("starting filesystem discovery")
-		if err := processing.ScanFS(gCtx, logger); err != nil {
+		if _, err := processing.ScanFS(gCtx, logger); err != nil {
 			return fmt.Errorf("filesystem discovery failed: %w", err)
 		}
 		logger.Info("filesystem discovery completed")
 		return nil
 	})
 
+	g.Go(func() error {
+		logger.Info("starting filesystem watcher")
+		if err := processing.WatchFS(gCtx, logger); err != nil {
+			return fmt.Errorf("filesystem watcher failed: %w", err)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		logger.Info("starting filesystem sync")
+		if err := processing.SyncFS(gCtx, logger); err != nil {
+			return fmt.Errorf("filesystem sync failed: %w", err)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		logger.Info("starting trash purge")
+		if err := trash.Run(gCtx, logger); err != nil {
+			return fmt.Errorf("trash purge failed: %w", err)
+		}
+		return nil
+	})
+
 	g.Go(func() error {
 		logger.Info("starting temp file discovery")
 		if err := processing.RunTempDiscovery(logger); err != nil {
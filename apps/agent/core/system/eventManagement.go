@@ -18,8 +18,17 @@ type systemEvent struct {
 }
 
 type eventManagement struct {
-	mu  sync.Mutex
-	out chan *events.Message
+	mu     sync.Mutex
+	out    chan *events.Message
+	name   string
+	source chan any
+}
+
+func newEventManagement(name string, buffer int) *eventManagement {
+	return &eventManagement{
+		name:   name,
+		source: make(chan any, buffer),
+	}
 }
 
 func (em *eventManagement) Start(ctx context.Context) error {
@@ -32,7 +41,7 @@ func (em *eventManagement) Start(ctx context.Context) error {
 	em.out = out
 	em.mu.Unlock()
 
-	eventName := "system.state"
+	eventName := em.name
 	go func() {
 		defer func() {
 			em.mu.Lock()
@@ -47,7 +56,7 @@ func (em *eventManagement) Start(ctx context.Context) error {
 			select {
 			case <-ctx.Done():
 				return
-			case m := <-systemEvents:
+			case m := <-em.source:
 				select {
 				case out <- &events.Message{
 					Event: eventName,
@@ -77,29 +86,47 @@ func (em *eventManagement) Messages() <-chan *events.Message {
 }
 
 var (
-	eventManager *eventManagement
-	systemEvents chan *systemEvent
-	droppedCount int64
-	lastLogTime  int64
-	logInterval  = int64(5 * time.Second) // Log at most once every 5 seconds
+	eventManager     *eventManagement
+	scanEventManager *eventManagement
+	droppedCount     int64
+	lastLogTime      int64
+	logInterval      = int64(5 * time.Second) // Log at most once every 5 seconds
 )
 
 func GetEventPublisher() *eventManagement {
 	return eventManager
 }
 
+// GetScanEventPublisher returns the publisher of the system.scan topic.
+func GetScanEventPublisher() *eventManagement {
+	return scanEventManager
+}
+
 func init() {
-	eventManager = &eventManagement{}
-	systemEvents = make(chan *systemEvent, 1000) // Increased buffer size
+	eventManager = newEventManagement("system.state", 1000) // Increased buffer size
+	scanEventManager = newEventManagement("system.scan", 100)
 	lastLogTime = time.Now().UnixNano()
 }
 
 func Publish(name string, data any) {
-	select {
-	case systemEvents <- &systemEvent{
+	eventManager.publish(name, &systemEvent{
 		Name:  name,
 		State: data,
-	}:
+	})
+}
+
+// PublishScan sends a scan progress message to system.scan subscribers.
+// Progress is only interesting live, it's dropped while nobody listens.
+func PublishScan(data any) {
+	if scanEventManager.Messages() == nil {
+		return
+	}
+	scanEventManager.publish(scanEventManager.name, data)
+}
+
+func (em *eventManagement) publish(name string, data any) {
+	select {
+	case em.source <- data:
 	default:
 		atomic.AddInt64(&droppedCount, 1)
 		now := time.Now().UnixNano()
@@ -0,0 +1,160 @@
+package thumbnails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/eduardooliveira/stLib/core/cache"
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/utils"
+	"github.com/nfnt/resize"
+)
+
+var (
+	ErrNoImage           = errors.New("asset has no image")
+	ErrUnsupportedFormat = errors.New("unsupported thumbnail format")
+	ErrUnsupportedImage  = errors.New("image can't be decoded")
+)
+
+// Sizes are the bounding boxes variants are made for, requested sizes are
+// rounded up to one of them so the cache stays small.
+var Sizes = []int{64, 128, 256, 512, 1024}
+
+// Formats maps the formats variants can be encoded in to their extension.
+var Formats = map[string]string{
+	"webp": ".webp",
+	"png":  ".png",
+	"jpeg": ".jpg",
+	"jpg":  ".jpg",
+}
+
+var variants = cache.NewStore("thumbnails")
+
+// Variant is a cached thumbnail, Name is what it is served as.
+type Variant struct {
+	File fs.File
+	Name string
+}
+
+// Get returns the variant of the image of an asset, made and cached when it is
+// missing or its source changed. Image assets are their own source, other
+// assets use their thumbnail.
+func Get(ctx context.Context, asset entities.Asset, size int, format string) (*Variant, error) {
+	ext, ok := Formats[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	size = fitSize(size)
+
+	src, err := source(asset)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openSource(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// The version covers the source asset and its content, a new render or an
+	// edited image get new variants
+	version := cache.Version(src.ID, info.ModTime().UnixNano(), info.Size())
+	cached, err := variants.Get(asset.ID, strconv.Itoa(size), version, ext, func(w io.Writer) error {
+		return generate(w, f, size, ext)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Variant{File: cached, Name: utils.VoZ(asset.Label) + ext}, nil
+}
+
+// Invalidate drops the cached variants of an asset.
+func Invalidate(id string) error {
+	return variants.Invalidate(id)
+}
+
+func fitSize(size int) int {
+	for _, s := range Sizes {
+		if size <= s {
+			return s
+		}
+	}
+	return Sizes[len(Sizes)-1]
+}
+
+func source(asset entities.Asset) (entities.Asset, error) {
+	if asset.Kind != nil && *asset.Kind == "image" {
+		return asset, nil
+	}
+	id := utils.VoZ(asset.Thumbnail)
+	if id == "" {
+		// Listings show directories with an image from their content
+		id, _ = database.FindThumbnail(asset.ID)
+	}
+	if id == "" || id == asset.ID {
+		return entities.Asset{}, ErrNoImage
+	}
+	src, err := database.GetAsset(id, false)
+	if err != nil {
+		return entities.Asset{}, fmt.Errorf("%w: %w", ErrNoImage, err)
+	}
+	return src, nil
+}
+
+// openSource opens the file of an image asset, wherever it is stored.
+func openSource(ctx context.Context, asset entities.Asset) (fs.File, error) {
+	if renderPath, ok := asset.Properties["render_path"].(string); ok && renderPath != "" {
+		return os.Open(renderPath)
+	}
+	if asset.Path == nil {
+		return nil, ErrNoImage
+	}
+	if asset.ParentID != nil && asset.Parent == nil && (asset.FSKind == "bundle" || asset.NodeKind == entities.NodeKindBundled) {
+		if err := database.LoadParents(&asset, 10); err != nil {
+			return nil, fmt.Errorf("failed to load parents: %w", err)
+		}
+	}
+	return libfs.OpenAsset(ctx, asset)
+}
+
+// generate decodes the source, shrinks it to fit in a size by size box and
+// encodes it to w. Images that already fit are only re-encoded.
+func generate(w io.Writer, r io.Reader, size int, ext string) error {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnsupportedImage, err)
+	}
+	if b := img.Bounds(); b.Dx() > size || b.Dy() > size {
+		img = resize.Thumbnail(uint(size), uint(size), img, resize.Lanczos3)
+	}
+
+	switch ext {
+	case ".webp":
+		err = nativewebp.Encode(w, img, nil)
+	case ".jpg":
+		err = jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	default:
+		err = png.Encode(w, img)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/eduardooliveira/stLib/core/data/database"
+	"github.com/eduardooliveira/stLib/core/entities"
+	"github.com/eduardooliveira/stLib/core/libfs"
+	"github.com/eduardooliveira/stLib/core/logger"
+	"github.com/eduardooliveira/stLib/core/processing"
+	"github.com/eduardooliveira/stLib/core/processing/renderers"
+	"github.com/eduardooliveira/stLib/core/runtime"
+	"github.com/eduardooliveira/stLib/core/thumbnails"
+)
+
+const purgeInterval = time.Hour
+
+var (
+	ErrRestoreConflict  = errors.New("something already exists at the restore path")
+	ErrNothingToRestore = errors.New("the files of this entry are gone, put them back before restoring")
+	ErrFSNotFound       = errors.New("filesystem of the entry is not available")
+	ErrReadOnly         = errors.New("filesystem is read only")
+)
+
+// Put moves a file or directory of a filesystem into the trash together with
+// the rows of its assets. A path that's already gone only keeps the rows.
+func Put(f libfs.LibFS, name string) (*entities.TrashEntry, error) {
+	e := entities.NewTrashEntry(f.GetName(), f.GetRoot(), name)
+
+	size, moved, err := moveOut(f, name, e)
+	if err == nil && !moved {
+		size, err = copyOut(f, name, e)
+	}
+	switch {
+	case err == nil:
+		e.HasContent, e.Size = true, size
+	case errors.Is(err, fs.ErrNotExist):
+	default:
+		os.RemoveAll(entryPath(e.ID))
+		return nil, err
+	}
+
+	// The entry goes in before the original is removed so what's in the trash
+	// can always be restored
+	if err := database.TrashAsset(e); err != nil {
+		if moved {
+			if err := moveBack(f, name, e); err != nil {
+				logger.GetLogger().Error("failed to move trashed path back", zap.String("fs", f.GetName()), zap.String("path", name), zap.String("trash", entryPath(e.ID)), zap.Error(err))
+				return nil, err
+			}
+		}
+		os.RemoveAll(entryPath(e.ID))
+		return nil, err
+	}
+	if e.HasContent && !moved {
+		if err := f.Remove(name); err != nil {
+			if uerr := unput(e); uerr != nil {
+				logger.GetLogger().Error("failed to take back trash entry", zap.String("id", e.ID), zap.Error(uerr))
+			}
+			return nil, err
+		}
+	}
+
+	// Bundles rewrite their archive, the filesystem holding it has to follow
+	if err := processing.ApplyChanges(f, []libfs.Change{{Op: libfs.ChangeRemove, Path: name}}); err != nil {
+		logger.GetLogger().Warn("failed to apply trashed path", zap.String("fs", f.GetName()), zap.String("path", name), zap.Error(err))
+	}
+	return e, nil
+}
+
+// unput takes back an entry whose files couldn't be removed, the rows of its
+// assets go back in.
+func unput(e *entities.TrashEntry) error {
+	if err := database.RestoreAssets(e.Assets); err != nil {
+		return err
+	}
+	return remove(e)
+}
+
+// Restore puts the files of an entry back where they were, rediscovers them
+// and gives the assets their labels, descriptions, properties and tags back.
+func Restore(ctx context.Context, id string) (*entities.TrashEntry, error) {
+	e, err := database.GetTrashEntry(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := entryFS(ctx, &e)
+	if err != nil {
+		return nil, err
+	}
+	defer libfs.Close(f)
+
+	_, statErr := fs.Stat(f, e.Path)
+	if e.HasContent {
+		if statErr == nil {
+			return nil, fmt.Errorf("%w: %s", ErrRestoreConflict, e.Path)
+		}
+		if !errors.Is(statErr, fs.ErrNotExist) {
+			return nil, statErr
+		}
+		if !f.Writable() {
+			return nil, fmt.Errorf("%w: %s", ErrReadOnly, f.GetName())
+		}
+		if err := copyIn(f, &e); err != nil {
+			return nil, err
+		}
+	} else if statErr != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNothingToRestore, e.Path)
+	}
+
+	// The rows go back first so rediscovery finds the assets under their IDs
+	if err := database.RestoreAssets(e.Assets); err != nil {
+		return nil, err
+	}
+	// The filesystem may have moved to another root since they were trashed
+	if _, err := database.RerootFS(f.GetName(), f.GetRoot()); err != nil {
+		return nil, err
+	}
+	if err := processing.ApplyChanges(f, []libfs.Change{{Op: libfs.ChangeCreate, Path: e.Path}}); err != nil {
+		return nil, err
+	}
+	if err := database.RestoreAssetMetadata(e.Assets); err != nil {
+		return nil, err
+	}
+	return &e, remove(&e)
+}
+
+// Delete drops an entry and its files for good.
+func Delete(id string) error {
+	e, err := database.GetTrashEntry(id)
+	if err != nil {
+		return err
+	}
+	if err := remove(&e); err != nil {
+		return err
+	}
+	forget(&e)
+	return nil
+}
+
+// Purge drops the entries deleted before the given time.
+func Purge(before time.Time) (int, error) {
+	expired, err := database.GetExpiredTrashEntries(before)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	purged := 0
+	for _, e := range expired {
+		if err := remove(e); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		forget(e)
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// Run purges what's past library.trash_retention until the context is cancelled.
+func Run(ctx context.Context, logger *zap.Logger) error {
+	days := runtime.Cfg.Library.TrashRetention
+	if days <= 0 {
+		logger.Info("trash retention disabled, deleted assets are kept")
+		return nil
+	}
+	retention := time.Duration(days) * 24 * time.Hour
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := Purge(time.Now().Add(-retention))
+		if err != nil {
+			logger.Warn("failed to purge trash", zap.Error(err))
+		}
+		if purged > 0 {
+			logger.Info("purged trash", zap.Int("entries", purged))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func remove(e *entities.TrashEntry) error {
+	if err := os.RemoveAll(entryPath(e.ID)); err != nil {
+		return err
+	}
+	return database.DeleteTrashEntry(e.ID)
+}
+
+// forget drops what was derived from the assets of an entry deleted for good.
+func forget(e *entities.TrashEntry) {
+	genFS, err := libfs.GetLibFS("generated")
+	if err != nil {
+		logger.GetLogger().Warn("failed to get generated fs", zap.Error(err))
+	}
+	for _, a := range e.Assets {
+		if genFS != nil {
+			if err := removeGenerated(genFS, a.ID); err != nil {
+				logger.GetLogger().Warn("failed to drop generated files", zap.String("asset_id", a.ID), zap.Error(err))
+			}
+		}
+		if err := thumbnails.Invalidate(a.ID); err != nil {
+			logger.GetLogger().Warn("failed to drop cached thumbnails", zap.String("asset_id", a.ID), zap.Error(err))
+		}
+		if err := renderers.InvalidateLayers(a.ID); err != nil {
+			logger.GetLogger().Warn("failed to drop cached layers", zap.String("asset_id", a.ID), zap.Error(err))
+		}
+	}
+}
+
+// removeGenerated deletes the renders, <id>.r*, and extracted images, <id>.e*,
+// of an asset.
+func removeGenerated(genFS libfs.LibFS, id string) error {
+	names, err := fs.Glob(genFS.GetFS(), id+".[re]*")
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range names {
+		if err := genFS.Remove(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func entryPath(id string) string {
+	return filepath.Join(runtime.GetDataPath(), "trash", id)
+}
+
+// entryFS resolves the filesystem an entry is restored into, bundled assets go
+// back into their bundle which has to still be there.
+func entryFS(ctx context.Context, e *entities.TrashEntry) (libfs.LibFS, error) {
+	if e.NodeKind != entities.NodeKindBundled {
+		f, err := libfs.GetLibFS(e.FSName)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrFSNotFound, e.FSName)
+		}
+		return f, nil
+	}
+
+	bundle, err := database.GetAsset(e.Root, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bundle %s: %w", ErrFSNotFound, e.FSName, err)
+	}
+	if bundle.FSKind == "bundle" && bundle.ParentID != nil {
+		if err := database.LoadParents(&bundle, 10); err != nil {
+			return nil, err
+		}
+	}
+	return libfs.OpenBundleFS(ctx, bundle)
+}
+
+// moveOut renames a path of a local filesystem into the trash and returns its
+// size, false when it has to be copied instead like across devices.
+func moveOut(f libfs.LibFS, name string, e *entities.TrashEntry) (int64, bool, error) {
+	if f.Kind() != "local" {
+		return 0, false, nil
+	}
+	src := filepath.Join(f.GetLocation(), name)
+	var size int64
+	err := filepath.WalkDir(src, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := os.MkdirAll(entryPath(e.ID), 0755); err != nil {
+		return 0, false, err
+	}
+	if err := os.Rename(src, filepath.Join(entryPath(e.ID), filepath.Base(e.Path))); err != nil {
+		return 0, false, nil
+	}
+	return size, true, nil
+}
+
+// moveBack undoes moveOut.
+func moveBack(f libfs.LibFS, name string, e *entities.TrashEntry) error {
+	return os.Rename(filepath.Join(entryPath(e.ID), filepath.Base(e.Path)), filepath.Join(f.GetLocation(), name))
+}
+
+// copyOut copies a path of a filesystem into the trash and returns its size.
+func copyOut(f libfs.LibFS, name string, e *entities.TrashEntry) (int64, error) {
+	return libfs.CopyTree(f, name, libfs.NewLocalFS("trash", entryPath(e.ID)), filepath.Base(e.Path))
+}
+
+// copyIn writes what copyOut kept back into a filesystem.
+func copyIn(f libfs.LibFS, e *entities.TrashEntry) error {
+	_, err := libfs.CopyTree(os.DirFS(entryPath(e.ID)), filepath.Base(e.Path), f, e.Path)
+	return err
+}
@@ -25,8 +25,12 @@ func GetFileSha1(path string) (string, error) {
 	}
 	defer f.Close()
 
+	return GetReaderSha1(f)
+}
+
+func GetReaderSha1(r io.Reader) (string, error) {
 	h := sha1.New()
-	if _, err := io.Copy(h, f); err != nil {
+	if _, err := io.Copy(h, r); err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil